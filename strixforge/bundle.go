@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/daveweinstein1/strixforge/pkg/core"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+)
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (s *stringList) String() string     { return strings.Join(*s, ",") }
+func (s *stringList) Set(v string) error { *s = append(*s, v); return nil }
+
+// runBundle collects every package the host stages need, plus dependencies,
+// into a local repository next to a copy of the binary and configs
+func runBundle(args []string) int {
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	out := fs.String("out", "strixforge-bundle", "Output directory")
+	var sources stringList
+	fs.Var(&sources, "from", "Directory with package files (local repo or cache); repeatable. Defaults to "+system.DefaultPackageCache)
+	fs.Parse(args)
+
+	if len(sources) == 0 {
+		sources = stringList{system.DefaultPackageCache}
+	}
+
+	fmt.Println(titleStyle.Render("Strixforge Offline Bundle"))
+
+	// Collect packages from every stage that installs host packages
+	var packages []string
+	for _, stage := range platform.Stages() {
+		if provider, ok := stage.(core.PackageProvider); ok {
+			packages = append(packages, provider.Packages()...)
+		}
+	}
+
+	ctx := context.Background()
+	pacman := system.NewPacman()
+
+	fmt.Printf("Resolving %d packages and their dependencies...\n", len(packages))
+	files, err := pacman.ResolveDependencies(ctx, packages...)
+	if err != nil {
+		fmt.Println(errorStyle.Render(err.Error()))
+		return 1
+	}
+	fmt.Printf("  %d package files required\n", len(files))
+
+	repoDir := filepath.Join(*out, "repo")
+	fmt.Printf("Copying packages to %s...\n", repoDir)
+	missing, err := system.BuildOfflineRepo(ctx, repoDir, files, sources)
+	if err != nil {
+		fmt.Println(errorStyle.Render(err.Error()))
+		return 1
+	}
+	if len(missing) > 0 {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("✗ %d packages not found in %s:", len(missing), strings.Join(sources, ", "))))
+		names := make([]string, len(missing))
+		for i, f := range missing {
+			fmt.Printf("  %s (%s)\n", f.Filename, f.Repo)
+			names[i] = f.Name
+		}
+		fmt.Println("Download them into the cache while online with:")
+		fmt.Printf("  sudo pacman -Sw --noconfirm %s\n", strings.Join(names, " "))
+		return 1
+	}
+
+	// Ship the binary and configs alongside the repository
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("Cannot locate strixforge binary: %v", err)))
+		return 1
+	}
+	if err := system.CopyFile(exe, filepath.Join(*out, "strixforge")); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("Failed to copy binary: %v", err)))
+		return 1
+	}
+	if dir, err := configsDir(exe); err != nil {
+		fmt.Println(warnStyle.Render(fmt.Sprintf("⚠ Could not copy configs: %v", err)))
+	} else if err := system.CopyDir(dir, filepath.Join(*out, "configs")); err != nil {
+		fmt.Println(warnStyle.Render(fmt.Sprintf("⚠ Could not copy configs: %v", err)))
+	}
+
+	fmt.Println(successStyle.Render(fmt.Sprintf("✓ Bundle ready in %s", *out)))
+	fmt.Println("On the offline machine, run from the bundle directory:")
+	fmt.Println("  ./strixforge --offline-repo ./repo")
+	return 0
+}
+
+// configsDir finds the configs directory to bundle without depending on
+// the working directory: next to the binary (a source checkout or an
+// earlier bundle), then the directory holding --config, then ./configs
+func configsDir(exe string) (string, error) {
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	candidates := []string{filepath.Join(filepath.Dir(exe), "configs")}
+	if abs, err := filepath.Abs(*configPath); err == nil {
+		candidates = append(candidates, filepath.Dir(abs))
+	}
+	candidates = append(candidates, "configs")
+	for _, dir := range candidates {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() && filepath.Base(dir) == "configs" {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("no configs directory next to %s or in the working directory", exe)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// subcommand is a named command with its own flag set
+type subcommand struct {
+	summary string
+	run     func(args []string) int
+}
+
+// subcommands lists everything reachable as `strixforge <name>`
+var subcommands = map[string]subcommand{
+	"bundle":     {"Collect packages into an offline repository", runBundle},
+	"containers": {"List, snapshot, restore, recreate, fork, publish and back up containers", runContainers},
+	"services":   {"List container services and their URLs", runServices},
+	"snapshots":  {"Take and prune container snapshots per policy", runSnapshots},
+}
+
+// runSubcommand dispatches to a subcommand and returns the exit code
+func runSubcommand(name string, args []string) int {
+	if name == "help" {
+		printSubcommands()
+		return 0
+	}
+
+	cmd, ok := subcommands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", name)
+		printSubcommands()
+		return 2
+	}
+	return cmd.run(args)
+}
+
+func printSubcommands() {
+	fmt.Println("Usage: strixforge [flags]")
+	fmt.Println("       strixforge <command> [flags]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %-12s %s\n", name, subcommands[name].summary)
+	}
+	fmt.Println()
+	fmt.Println("Run 'strixforge <command> -h' for command flags, 'strixforge -h' for installer flags.")
+}
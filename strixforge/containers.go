@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/lxd"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+	"github.com/daveweinstein1/strixforge/pkg/workspace"
+)
+
+// containerActions are the `strixforge containers` subcommands
+var containerActions = map[string]subcommand{
+	"list":     {"List containers with their state and snapshots", runContainersList},
+	"status":   {"Show a container's state, services and snapshots", runContainersStatus},
+	"start":    {"Start a container", runContainersStart},
+	"stop":     {"Stop a container", runContainersStop},
+	"shell":    {"Open a login shell in a container", runContainersShell},
+	"snapshot": {"Take a snapshot that is never pruned", runContainersSnapshot},
+	"restore":  {"Roll a container back to a snapshot", runContainersRestore},
+	"recreate": {"Delete a container and create it again from its spec", runContainersRecreate},
+	"update":   {"Upgrade packages, rolling back if validation fails", runContainersUpdate},
+	"fork":     {"Copy a container for experiments", runContainersFork},
+	"promote":  {"Swap a fork into its original's name", runContainersPromote},
+	"discard":  {"Delete a fork", runContainersDiscard},
+	"publish":  {"Save a provisioned container as a golden image", runContainersPublish},
+	"images":   {"List golden images", runContainersImages},
+	"export":   {"Back a container up to a directory", runContainersExport},
+	"import":   {"Restore a container from an export manifest", runContainersImport},
+}
+
+// containerActionOrder is the order actions are listed in usage
+var containerActionOrder = []string{"list", "status", "start", "stop", "shell", "snapshot", "restore", "recreate", "update", "fork", "promote", "discard", "publish", "images", "export", "import"}
+
+// runContainers dispatches `strixforge containers <action>`
+func runContainers(args []string) int {
+	if len(args) > 0 {
+		if action, ok := containerActions[args[0]]; ok {
+			return action.run(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Usage: strixforge containers <action> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Actions:")
+	for _, name := range containerActionOrder {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, containerActions[name].summary)
+	}
+	return 2
+}
+
+// connectLXD applies --lxd-socket and connects, printing any error
+func connectLXD(socket string) (*lxd.Client, bool) {
+	lxd.DefaultSocket = socket
+	client, err := lxd.Connect("")
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("Cannot reach LXD: %v", err)))
+		return nil, false
+	}
+	return client, true
+}
+
+// connectRuntime applies --lxd-socket and connects to the runtime the
+// config selects, printing any error
+func connectRuntime(cfg *config.Config, socket string) (system.ContainerRuntime, bool) {
+	lxd.DefaultSocket = socket
+	runtime, err := workspace.NewRuntime(cfg.Runtime)
+	if err == nil {
+		err = runtime.Ping(context.Background())
+	}
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("Cannot reach %s: %v", cfg.Runtime, err)))
+		return nil, false
+	}
+	return runtime, true
+}
+
+// requireLXD reports whether the config runs containers with LXD, printing
+// an error for commands that only work there
+func requireLXD(cfg *config.Config, command string) bool {
+	if cfg.OCI() {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("'%s' needs the lxd runtime; the config selects %s", command, cfg.Runtime)))
+		return false
+	}
+	return true
+}
+
+// printProgress shows LXD operation progress on a single line
+func printProgress(p lxd.Progress) {
+	fmt.Printf("\r  %-60s", p.Text)
+}
+
+func runContainersExport(args []string) int {
+	fs := flag.NewFlagSet("containers export", flag.ExitOnError)
+	to := fs.String("to", "", "Directory to write the backup and manifest to (required)")
+	cfgPath := fs.String("config", config.DefaultPath, "Platform configuration file")
+	socket := fs.String("lxd-socket", "", "Path to the LXD unix socket (default: auto-detect)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: strixforge containers export NAME --to DIR")
+		fs.PrintDefaults()
+	}
+	// Allow the container name before the flags
+	var name string
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		name, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if name == "" && fs.NArg() > 0 {
+		name = fs.Arg(0)
+	}
+	if name == "" || *to == "" {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := loadConfig(*cfgPath)
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("Invalid configuration: %v", err)))
+		return 1
+	}
+	var spec *config.ContainerSpec
+	if s, ok := cfg.Containers.Find(name); ok {
+		spec = &s
+	}
+	if !requireLXD(cfg, "containers export") {
+		return 1
+	}
+
+	client, ok := connectLXD(*socket)
+	if !ok {
+		return 1
+	}
+
+	fmt.Println(titleStyle.Render(fmt.Sprintf("Exporting %s", name)))
+	path, manifest, err := workspace.NewExporter(client).Export(context.Background(), name, *to, spec, printProgress)
+	fmt.Println()
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("✗ %v", err)))
+		return 1
+	}
+
+	format := "portable"
+	if manifest.OptimizedStorage {
+		format = "optimized " + manifest.StorageDriver
+	}
+	fmt.Println(successStyle.Render(fmt.Sprintf("✓ %s (%d MiB, %s)", manifest.Backup, manifest.Size>>20, format)))
+	fmt.Printf("  Manifest: %s\n", path)
+	fmt.Printf("  Restore with: strixforge containers import %s\n", path)
+	return 0
+}
+
+func runContainersImport(args []string) int {
+	fs := flag.NewFlagSet("containers import", flag.ExitOnError)
+	as := fs.String("as", "", "Name for the restored container (default: original name, or NAME-restored if it exists)")
+	noVerify := fs.Bool("no-verify", false, "Import even if the tarball's checksum is missing from the manifest or does not match")
+	socket := fs.String("lxd-socket", "", "Path to the LXD unix socket (default: auto-detect)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: strixforge containers import MANIFEST.yaml [--as NAME] [--no-verify]")
+		fs.PrintDefaults()
+	}
+	var manifestPath string
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		manifestPath, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if manifestPath == "" && fs.NArg() > 0 {
+		manifestPath = fs.Arg(0)
+	}
+	if manifestPath == "" {
+		fs.Usage()
+		return 2
+	}
+
+	client, ok := connectLXD(*socket)
+	if !ok {
+		return 1
+	}
+
+	fmt.Println(titleStyle.Render(fmt.Sprintf("Importing %s", manifestPath)))
+	name, err := workspace.NewExporter(client).Import(context.Background(), manifestPath, *as, !*noVerify, printProgress)
+	fmt.Println()
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("✗ %v", err)))
+		return 1
+	}
+	fmt.Println(successStyle.Render(fmt.Sprintf("✓ Restored as %s", name)))
+	fmt.Printf("  Start it with: lxc start %s\n", name)
+	return 0
+}
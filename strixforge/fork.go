@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/daveweinstein1/strixforge/pkg/workspace"
+)
+
+func runContainersFork(args []string) int {
+	c := newContainerCommand("fork", "fork NAME FORK [--json]", false)
+	if code := c.parse(args, 2); code >= 0 {
+		return code
+	}
+	fork := c.args[0]
+	client, err := c.lxdClient()
+	if err != nil {
+		return c.fail(err)
+	}
+	ctx := context.Background()
+	if _, err := c.requireExists(ctx); err != nil {
+		return c.fail(err)
+	}
+
+	result, err := workspace.Fork(ctx, c.ui(), client, c.name, fork)
+	if err != nil {
+		return c.fail(err)
+	}
+	if *c.asJSON {
+		printJSON(actionResult{Container: c.name, Action: "forked", Fork: fork, Snapshot: result.Snapshot, Ports: result.Ports})
+		return 0
+	}
+	fmt.Println(successStyle.Render(fmt.Sprintf("✓ %s forked from %s/%s", fork, c.name, result.Snapshot)))
+	for _, p := range result.Ports {
+		fmt.Printf("  localhost:%d → localhost:%d\n", p.From, p.To)
+	}
+	fmt.Printf("  Keep it with:    strixforge containers promote %s\n", fork)
+	fmt.Printf("  Throw it away:   strixforge containers discard %s\n", fork)
+	return 0
+}
+
+func runContainersPromote(args []string) int {
+	c := newContainerCommand("promote", "promote FORK [--yes] [--json]", true)
+	if code := c.parse(args, 1); code >= 0 {
+		return code
+	}
+	client, err := c.lxdClient()
+	if err != nil {
+		return c.fail(err)
+	}
+	ctx := context.Background()
+	instance, err := client.Instance(ctx, c.name)
+	if err != nil {
+		return c.fail(fmt.Errorf("container %s does not exist", c.name))
+	}
+	original := workspace.ForkOf(instance)
+	if original == "" {
+		return c.fail(fmt.Errorf("%s is not a fork", c.name))
+	}
+
+	if !c.confirm(fmt.Sprintf("Replace %s with %s? %s is kept, stopped, as %s.", original, c.name, original, workspace.PreviousName(original))) {
+		return 1
+	}
+	previous, err := workspace.Promote(ctx, c.ui(), client, c.name)
+	if err != nil {
+		return c.fail(err)
+	}
+	return c.done(actionResult{Container: original, Action: "promoted", Fork: c.name, Previous: previous},
+		fmt.Sprintf("%s is now %s; the old one is %s (strixforge containers discard %s)", c.name, original, previous, previous))
+}
+
+func runContainersDiscard(args []string) int {
+	c := newContainerCommand("discard", "discard FORK [--yes] [--json]", true)
+	if code := c.parse(args, 1); code >= 0 {
+		return code
+	}
+	client, err := c.lxdClient()
+	if err != nil {
+		return c.fail(err)
+	}
+	ctx := context.Background()
+	instance, err := client.Instance(ctx, c.name)
+	if err != nil {
+		return c.fail(fmt.Errorf("container %s does not exist", c.name))
+	}
+	if workspace.ForkOf(instance) == "" {
+		return c.fail(fmt.Errorf("%s is not a fork; only forks and replaced containers can be discarded", c.name))
+	}
+	if !c.confirm(fmt.Sprintf("Delete %s with everything in it?", c.name)) {
+		return 1
+	}
+	if err := workspace.Discard(ctx, client, c.name); err != nil {
+		return c.fail(err)
+	}
+	return c.done(actionResult{Container: c.name, Action: "discarded"}, c.name+" deleted")
+}
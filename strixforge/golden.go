@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/daveweinstein1/strixforge/pkg/workspace"
+)
+
+func runContainersPublish(args []string) int {
+	c := newContainerCommand("publish", "publish NAME --as REF [--description TEXT] [--yes] [--json]", true)
+	as := c.fs.String("as", "", "Image reference to publish as, e.g. strix/ai-lab:2026-10 (required)")
+	description := c.fs.String("description", "", "Description stored with the image")
+	if code := c.parse(args, 1); code >= 0 {
+		return code
+	}
+	if *as == "" {
+		c.fs.Usage()
+		return 2
+	}
+	client, err := c.lxdClient()
+	if err != nil {
+		return c.fail(err)
+	}
+
+	ctx := context.Background()
+	if _, err := c.requireExists(ctx); err != nil {
+		return c.fail(err)
+	}
+	existing, err := workspace.FindGolden(ctx, client, *as)
+	if err != nil {
+		return c.fail(err)
+	}
+	if existing != nil && !c.confirm(fmt.Sprintf("Replace %s, published from %s on %s?", *as, existing.Source, existing.Published.Format("2006-01-02"))) {
+		return 1
+	}
+
+	golden, err := workspace.Publish(ctx, c.ui(), client, c.name, *as, *description)
+	if err != nil {
+		return c.fail(err)
+	}
+	return c.done(actionResult{Container: c.name, Action: "published", Image: golden.Ref, Fingerprint: golden.Fingerprint},
+		fmt.Sprintf("%s published as %s (%d MiB); set 'golden: %s' in its spec to launch from it", c.name, golden.Ref, golden.Size>>20, golden.Ref))
+}
+
+func runContainersImages(args []string) int {
+	c := newContainerCommand("images", "images [--json]", false)
+	if code := c.parse(args, 0); code >= 0 {
+		return code
+	}
+	client, err := c.lxdClient()
+	if err != nil {
+		return c.fail(err)
+	}
+	goldens, err := workspace.GoldenImages(context.Background(), client)
+	if err != nil {
+		return c.fail(err)
+	}
+	if *c.asJSON {
+		if goldens == nil {
+			goldens = []workspace.GoldenImage{}
+		}
+		printJSON(goldens)
+		return 0
+	}
+
+	if len(goldens) == 0 {
+		fmt.Println("No golden images. Create one with: strixforge containers publish NAME --as REF")
+		return 0
+	}
+	fmt.Printf("%-28s %-16s %-12s %-10s %s\n", "IMAGE", "SOURCE", "PUBLISHED", "SIZE", "FINGERPRINT")
+	for _, g := range goldens {
+		fmt.Printf("%-28s %-16s %-12s %-10s %.12s\n", g.Ref, g.Source, g.Published.Format("2006-01-02"), fmt.Sprintf("%d MiB", g.Size>>20), g.Fingerprint)
+	}
+	return 0
+}
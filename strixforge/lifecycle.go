@@ -0,0 +1,484 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/core"
+	"github.com/daveweinstein1/strixforge/pkg/lxd"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+	"github.com/daveweinstein1/strixforge/pkg/workspace"
+)
+
+// containerCommand is the state shared by the lifecycle actions of
+// `strixforge containers`: a container name, the config and its runtime
+type containerCommand struct {
+	fs      *flag.FlagSet
+	action  string
+	name    string
+	cfgPath *string
+	socket  *string
+	asJSON  *bool
+	yes     *bool
+	args    []string // positional arguments after NAME
+
+	cfg     *config.Config
+	runtime system.ContainerRuntime
+}
+
+// newContainerCommand creates the flag set for an action. Destructive
+// actions get --yes to skip their confirmation prompt.
+func newContainerCommand(action, usage string, destructive bool) *containerCommand {
+	c := &containerCommand{fs: flag.NewFlagSet("containers "+action, flag.ExitOnError), action: action}
+	c.cfgPath = c.fs.String("config", config.DefaultPath, "Platform configuration file")
+	c.socket = c.fs.String("lxd-socket", "", "Path to the LXD unix socket (default: auto-detect)")
+	c.asJSON = c.fs.Bool("json", false, "Print JSON instead of text")
+	if destructive {
+		c.yes = c.fs.Bool("yes", false, "Do not ask for confirmation")
+	}
+	c.fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: strixforge containers "+usage)
+		c.fs.PrintDefaults()
+	}
+	return c
+}
+
+// parse reads NAME, any further arguments and the flags, then loads the
+// config and connects to its runtime. want is how many positional
+// arguments, NAME included, the action takes. It prints any error and
+// returns the exit code to use, or -1 to carry on.
+func (c *containerCommand) parse(args []string, want int) int {
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		c.name, args = args[0], args[1:]
+	}
+	c.fs.Parse(args)
+	c.args = c.fs.Args()
+	if c.name == "" && len(c.args) > 0 {
+		c.name, c.args = c.args[0], c.args[1:]
+	}
+	if want > 0 && (c.name == "" || len(c.args) != want-1) {
+		c.fs.Usage()
+		return 2
+	}
+
+	cfg, err := loadConfig(*c.cfgPath)
+	if err != nil {
+		return c.fail(fmt.Errorf("invalid configuration: %v", err))
+	}
+	c.cfg = cfg
+	lxd.DefaultSocket = *c.socket
+	if c.runtime, err = workspace.NewRuntime(cfg.Runtime); err != nil {
+		return c.fail(err)
+	}
+	if err := c.runtime.Ping(context.Background()); err != nil {
+		return c.fail(fmt.Errorf("cannot reach %s: %v", c.runtime.Name(), err))
+	}
+	return -1
+}
+
+// spec returns the container's spec, or nil if the config does not declare it
+func (c *containerCommand) spec() *config.ContainerSpec {
+	if spec, ok := c.cfg.Containers.Find(c.name); ok {
+		return &spec
+	}
+	return nil
+}
+
+// lxdClient returns the LXD client for actions that only work there
+func (c *containerCommand) lxdClient() (*lxd.Client, error) {
+	lxdRuntime, ok := c.runtime.(*workspace.LXDRuntime)
+	if !ok {
+		return nil, fmt.Errorf("'containers %s' needs the lxd runtime; the config selects %s", c.action, c.runtime.Name())
+	}
+	return lxdRuntime.Client()
+}
+
+// requireExists fails unless the container exists
+func (c *containerCommand) requireExists(ctx context.Context) (system.ContainerState, error) {
+	state, err := c.runtime.State(ctx, c.name)
+	if err != nil {
+		return "", err
+	}
+	if state == system.ContainerMissing {
+		return state, fmt.Errorf("container %s does not exist", c.name)
+	}
+	return state, nil
+}
+
+// confirm asks before a destructive action unless --yes was given. Without
+// a terminal to ask on, it refuses.
+func (c *containerCommand) confirm(question string) bool {
+	if c.yes != nil && *c.yes {
+		return true
+	}
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		fmt.Fprintln(os.Stderr, errorStyle.Render("Refusing without confirmation; pass --yes to run non-interactively"))
+		return false
+	}
+	fmt.Fprint(os.Stderr, warnStyle.Render(question+" [y/N] "))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// ui returns the reconciler UI: the console, or stderr when stdout is JSON
+func (c *containerCommand) ui() core.UI {
+	if *c.asJSON {
+		return &stderrUI{}
+	}
+	return &autoUIAdapter{}
+}
+
+// actionResult is the JSON output of a lifecycle action
+type actionResult struct {
+	Container      string `json:"container"`
+	Action         string `json:"action"`
+	Snapshot       string `json:"snapshot,omitempty"`
+	SafetySnapshot string `json:"safety-snapshot,omitempty"`
+	Image          string `json:"image,omitempty"`
+	Fingerprint    string `json:"fingerprint,omitempty"`
+	Fork           string `json:"fork,omitempty"`
+	Previous       string `json:"previous,omitempty"`
+
+	Ports   []workspace.PortRemap `json:"ports,omitempty"`
+	Command []string              `json:"command,omitempty"`
+	Error   string                `json:"error,omitempty"`
+}
+
+// done reports a completed action
+func (c *containerCommand) done(result actionResult, message string) int {
+	if *c.asJSON {
+		printJSON(result)
+	} else {
+		fmt.Println(successStyle.Render("✓ " + message))
+	}
+	return 0
+}
+
+// fail reports an error and returns exit code 1
+func (c *containerCommand) fail(err error) int {
+	if c.asJSON != nil && *c.asJSON {
+		printJSON(actionResult{Container: c.name, Action: "error", Error: err.Error()})
+	} else {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("✗ %v", err)))
+	}
+	return 1
+}
+
+// printJSON writes an indented JSON document to stdout
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// stderrUI logs reconciler progress to stderr, keeping stdout for JSON
+type stderrUI struct{ autoUIAdapter }
+
+func (u *stderrUI) Log(level core.LogLevel, message string) {
+	if level != core.LogDebug {
+		fmt.Fprintln(os.Stderr, message)
+	}
+}
+
+func (u *stderrUI) Progress(percent int, message string) {}
+
+func runContainersList(args []string) int {
+	c := newContainerCommand("list", "list [--json]", false)
+	if code := c.parse(args, 0); code >= 0 {
+		return code
+	}
+	statuses, err := workspace.ContainerStatuses(context.Background(), c.runtime, c.cfg.Containers)
+	if err != nil {
+		return c.fail(err)
+	}
+	if *c.asJSON {
+		printJSON(statuses)
+		return 0
+	}
+
+	if len(statuses) == 0 {
+		fmt.Println("No containers. Declare some under 'containers:' in the config and run the workspace stage.")
+		return 0
+	}
+	fmt.Printf("%-16s %-10s %-9s %-10s %s\n", "NAME", "STATE", "DECLARED", "SNAPSHOTS", "DESCRIPTION")
+	for _, s := range statuses {
+		declared := "no"
+		if s.Declared {
+			declared = "yes"
+		}
+		fmt.Printf("%-16s %s %-9s %-10d %s\n", s.Name, containerState(s.State, 10), declared, len(s.Snapshots), s.Description)
+	}
+	return 0
+}
+
+func runContainersStatus(args []string) int {
+	c := newContainerCommand("status", "status NAME [--json]", false)
+	if code := c.parse(args, 1); code >= 0 {
+		return code
+	}
+	statuses, err := workspace.ContainerStatuses(context.Background(), c.runtime, c.cfg.Containers)
+	if err != nil {
+		return c.fail(err)
+	}
+	var status *workspace.ContainerStatus
+	for i := range statuses {
+		if statuses[i].Name == c.name {
+			status = &statuses[i]
+		}
+	}
+	if status == nil {
+		return c.fail(fmt.Errorf("container %s does not exist and is not declared in the config", c.name))
+	}
+	if *c.asJSON {
+		printJSON(status)
+		return 0
+	}
+
+	fmt.Println(titleStyle.Render(status.Name))
+	fmt.Printf("  State:       %s\n", containerState(status.State, 0))
+	fmt.Printf("  Runtime:     %s\n", status.Runtime)
+	if status.Image != "" {
+		fmt.Printf("  Image:       %s\n", status.Image)
+	}
+	if status.Description != "" {
+		fmt.Printf("  Description: %s\n", status.Description)
+	}
+	if !status.Declared {
+		fmt.Println(warnStyle.Render("  Not declared in the config; recreate is unavailable"))
+	}
+	if len(status.Services) > 0 {
+		fmt.Println("  Services:")
+		names := make([]string, 0, len(status.Services))
+		for name := range status.Services {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("    %-12s %s\n", name, status.Services[name])
+		}
+	}
+	fmt.Printf("  Snapshots:   %d\n", len(status.Snapshots))
+	for _, snap := range status.Snapshots {
+		fmt.Printf("    %s\n", snap)
+	}
+	return 0
+}
+
+func runContainersStart(args []string) int {
+	c := newContainerCommand("start", "start NAME [--json]", false)
+	if code := c.parse(args, 1); code >= 0 {
+		return code
+	}
+	ctx := context.Background()
+	state, err := c.requireExists(ctx)
+	if err != nil {
+		return c.fail(err)
+	}
+	if state != system.ContainerRunning {
+		if err := c.runtime.Start(ctx, c.name); err != nil {
+			return c.fail(err)
+		}
+	}
+	return c.done(actionResult{Container: c.name, Action: "started"}, c.name+" is running")
+}
+
+func runContainersStop(args []string) int {
+	c := newContainerCommand("stop", "stop NAME [--json]", false)
+	if code := c.parse(args, 1); code >= 0 {
+		return code
+	}
+	ctx := context.Background()
+	state, err := c.requireExists(ctx)
+	if err != nil {
+		return c.fail(err)
+	}
+	if state == system.ContainerRunning {
+		if err := c.runtime.Stop(ctx, c.name); err != nil {
+			return c.fail(err)
+		}
+	}
+	return c.done(actionResult{Container: c.name, Action: "stopped"}, c.name+" is stopped")
+}
+
+func runContainersSnapshot(args []string) int {
+	c := newContainerCommand("snapshot", "snapshot NAME [--reason TEXT] [--json]", false)
+	reason := c.fs.String("reason", "manual snapshot", "Why the snapshot was taken, recorded with it on LXD")
+	if code := c.parse(args, 1); code >= 0 {
+		return code
+	}
+	ctx := context.Background()
+	if _, err := c.requireExists(ctx); err != nil {
+		return c.fail(err)
+	}
+	snapshot, err := workspace.NewSnapshotter(c.runtime).Take(ctx, c.name, workspace.SnapshotManual, *reason)
+	if err != nil {
+		return c.fail(err)
+	}
+	return c.done(actionResult{Container: c.name, Action: "snapshotted", Snapshot: snapshot},
+		fmt.Sprintf("Snapshot %s/%s taken; restore with: strixforge containers restore %s %s", c.name, snapshot, c.name, snapshot))
+}
+
+func runContainersRestore(args []string) int {
+	c := newContainerCommand("restore", "restore NAME SNAPSHOT [--yes] [--json]", true)
+	if code := c.parse(args, 2); code >= 0 {
+		return code
+	}
+	snapshot := c.args[0]
+
+	if !c.confirm(fmt.Sprintf("Roll %s back to %s? Changes since then are kept only in a safety snapshot.", c.name, snapshot)) {
+		return 1
+	}
+	safety, err := workspace.Restore(context.Background(), c.runtime, c.spec(), c.name, snapshot)
+	if err != nil {
+		return c.fail(err)
+	}
+	return c.done(actionResult{Container: c.name, Action: "restored", Snapshot: snapshot, SafetySnapshot: safety},
+		fmt.Sprintf("%s restored to %s (previous state saved as %s)", c.name, snapshot, safety))
+}
+
+func runContainersRecreate(args []string) int {
+	c := newContainerCommand("recreate", "recreate NAME [--yes] [--json]", true)
+	if code := c.parse(args, 1); code >= 0 {
+		return code
+	}
+	spec := c.spec()
+	if spec == nil {
+		return c.fail(fmt.Errorf("%s is not declared in the config, so it cannot be recreated", c.name))
+	}
+
+	ctx := context.Background()
+	state, err := c.runtime.State(ctx, c.name)
+	if err != nil {
+		return c.fail(err)
+	}
+	if state != system.ContainerMissing {
+		if !c.confirm(fmt.Sprintf("Delete %s with everything in it, including its snapshots, and recreate it from its spec?", c.name)) {
+			return 1
+		}
+		if err := c.runtime.Delete(ctx, c.name); err != nil {
+			return c.fail(err)
+		}
+	}
+
+	result, err := workspace.NewReconciler(c.runtime, c.cfg.Volumes).Reconcile(ctx, c.ui(), *spec)
+	if err != nil {
+		return c.fail(err)
+	}
+	return c.done(actionResult{Container: c.name, Action: string(result.Action)}, c.name+" recreated from its spec")
+}
+
+func runContainersUpdate(args []string) int {
+	c := newContainerCommand("update", "update [NAME...] [--json]", false)
+	if code := c.parse(args, 0); code >= 0 {
+		return code
+	}
+	ctx := context.Background()
+
+	// Every declared container that exists, unless names were given
+	var specs []config.ContainerSpec
+	if c.name != "" {
+		for _, name := range append([]string{c.name}, c.args...) {
+			spec, ok := c.cfg.Containers.Find(name)
+			if !ok {
+				return c.fail(fmt.Errorf("%s is not declared in the config, so it has no validate checks", name))
+			}
+			specs = append(specs, spec)
+		}
+	} else {
+		for _, spec := range c.cfg.Containers {
+			state, err := c.runtime.State(ctx, spec.Name)
+			if err != nil {
+				return c.fail(err)
+			}
+			if state != system.ContainerMissing {
+				specs = append(specs, spec)
+			}
+		}
+	}
+
+	results := []workspace.UpdateResult{}
+	code := 0
+	for _, spec := range specs {
+		if !*c.asJSON {
+			fmt.Println(titleStyle.Render("Updating " + spec.Name))
+		}
+		result, err := workspace.Update(ctx, c.ui(), c.runtime, spec)
+		if err != nil {
+			result.Error = err.Error()
+			code = 1
+			if !*c.asJSON {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("✗ %s: %v", spec.Name, err)))
+			}
+		} else if !*c.asJSON {
+			fmt.Println(successStyle.Render(fmt.Sprintf("✓ %s updated (restore with: strixforge containers restore %s %s)", spec.Name, spec.Name, result.Snapshot)))
+		}
+		results = append(results, *result)
+	}
+	if *c.asJSON {
+		printJSON(results)
+	} else if len(specs) == 0 {
+		fmt.Println("No declared containers exist yet; run the workspace stage first.")
+	}
+	return code
+}
+
+func runContainersShell(args []string) int {
+	c := newContainerCommand("shell", "shell NAME [--user USER] [--json]", false)
+	user := c.fs.String("user", "", "Log in as this user instead of root")
+	if code := c.parse(args, 1); code >= 0 {
+		return code
+	}
+	state, err := c.requireExists(context.Background())
+	if err != nil {
+		return c.fail(err)
+	}
+	// lxc must talk to the same daemon, which may be on --lxd-socket
+	var socket string
+	if c.runtime.Name() == config.RuntimeLXD {
+		socket = lxd.FindSocket()
+	}
+	command, err := workspace.ShellCommand(c.runtime.Name(), c.name, *user, socket)
+	if err != nil {
+		return c.fail(err)
+	}
+	if *c.asJSON {
+		// Print the command for scripts instead of starting an interactive shell
+		printJSON(actionResult{Container: c.name, Action: "shell", Command: command})
+		return 0
+	}
+	if state != system.ContainerRunning {
+		return c.fail(fmt.Errorf("%s is stopped; start it with: strixforge containers start %s", c.name, c.name))
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exit, ok := err.(*exec.ExitError); ok {
+			return exit.ExitCode()
+		}
+		return c.fail(err)
+	}
+	return 0
+}
+
+// containerState colours a state, padded to width before styling so table
+// columns line up
+func containerState(state system.ContainerState, width int) string {
+	text := fmt.Sprintf("%-*s", width, state)
+	switch state {
+	case system.ContainerRunning:
+		return successStyle.Render(text)
+	case system.ContainerMissing:
+		return warnStyle.Render(text)
+	}
+	return text
+}
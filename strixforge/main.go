@@ -8,6 +8,7 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
@@ -17,8 +18,10 @@ import (
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
 
+	"github.com/daveweinstein1/strixforge/pkg/config"
 	"github.com/daveweinstein1/strixforge/pkg/containerhub"
 	"github.com/daveweinstein1/strixforge/pkg/core"
+	"github.com/daveweinstein1/strixforge/pkg/lxd"
 	"github.com/daveweinstein1/strixforge/pkg/platform/strixhalo"
 	"github.com/daveweinstein1/strixforge/pkg/system"
 )
@@ -32,17 +35,51 @@ var guiAssets embed.FS
 const guiInstallReady = false
 
 var (
-	forceTUI        = flag.Bool("tui", false, "Force TUI mode")
-	forceGUI        = flag.Bool("gui", false, "Force GUI mode (native window)")
-	autoMode        = flag.Bool("auto", false, "Run all stages without prompts")
-	manualMode      = flag.Bool("manual", false, "Manually select stages to run")
-	marketplaceMode = flag.Bool("hub", false, "Browse Container Hub")
-	checkVersions   = flag.Bool("check-versions", false, "Check package versions and exit")
-	dryRun          = flag.Bool("dry-run", false, "Simulate installation without changes")
+	forceTUI         = flag.Bool("tui", false, "Force TUI mode")
+	forceGUI         = flag.Bool("gui", false, "Force GUI mode (native window)")
+	autoMode         = flag.Bool("auto", false, "Run all stages without prompts")
+	manualMode       = flag.Bool("manual", false, "Manually select stages to run")
+	marketplaceMode  = flag.Bool("hub", false, "Browse Container Hub")
+	checkVersions    = flag.Bool("check-versions", false, "Check package versions and exit")
+	dryRun           = flag.Bool("dry-run", false, "Simulate installation without changes")
+	lockTimeout      = flag.Duration("lock-timeout", 5*time.Minute, "How long to wait for another package manager to release the pacman lock")
+	offlineRepo      = flag.String("offline-repo", "", "Install from a local package repository created by 'strixforge bundle'")
+	configPath       = flag.String("config", config.DefaultPath, "Platform configuration file")
+	lxdSocket        = flag.String("lxd-socket", "", "Path to the LXD unix socket (default: auto-detect)")
+	updateContainers = flag.Bool("update-containers", false, "Upgrade packages in existing workspace containers, rolling back on failed validation")
 )
 
 func main() {
+	// Subcommands parse their own flags
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(runSubcommand(os.Args[1], os.Args[2:]))
+	}
+
 	flag.Parse()
+	defer runCleanups()
+	system.DefaultLockTimeout = *lockTimeout
+	lxd.DefaultSocket = *lxdSocket
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("Invalid configuration: %v", err)))
+		exit(1)
+	}
+	if *updateContainers {
+		cfg.UpdateContainers = true
+	}
+	platform.SetConfig(cfg)
+
+	// Offline mode: point pacman at the bundled repository
+	if *offlineRepo != "" {
+		repo, err := system.EnableOfflineRepo(*offlineRepo)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("Cannot use offline repository: %v", err)))
+			exit(1)
+		}
+		cleanups = append(cleanups, func() { repo.Close() })
+		fmt.Println(infoStyle.Render(fmt.Sprintf("ℹ Installing packages from offline repository %s", repo.Dir)))
+	}
 
 	// Version check mode
 	if *checkVersions {
@@ -92,6 +129,24 @@ func main() {
 	}
 }
 
+// cleanups undo temporary setup such as the offline repository's
+// pacman.conf. They run when main returns and from exit, since os.Exit
+// skips deferred calls.
+var cleanups []func()
+
+func runCleanups() {
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+	cleanups = nil
+}
+
+// exit runs the cleanups, then exits with code
+func exit(code int) {
+	runCleanups()
+	os.Exit(code)
+}
+
 // runVersionCheck displays version comparison and exits
 func runVersionCheck() {
 	fmt.Println(titleStyle.Render("Strix Halo Version Check"))
@@ -101,7 +156,7 @@ func runVersionCheck() {
 	checks, err := system.CheckAllVersions(ctx)
 	if err != nil {
 		fmt.Printf("Error checking versions: %v\n", err)
-		os.Exit(1)
+		exit(1)
 	}
 
 	fmt.Println(system.FormatVersionTable(checks))
@@ -111,7 +166,7 @@ func runVersionCheck() {
 	if system.HasCriticalMismatches(checks) {
 		fmt.Println(warnStyle.Render("⚠ Some packages have older versions than expected."))
 		fmt.Println("Run the installer to update, or use --auto to proceed anyway.")
-		os.Exit(1)
+		exit(1)
 	}
 
 	fmt.Println(successStyle.Render("✓ All versions look good!"))
@@ -147,7 +202,7 @@ func runAutoMode() {
 	err = engine.Run(ctx)
 	if err != nil {
 		fmt.Printf(errorStyle.Render("Installation failed: %v\n"), err)
-		os.Exit(1)
+		exit(1)
 	}
 
 	fmt.Println()
@@ -168,12 +223,12 @@ func runMarketplace() {
 	// Launch Bubble Tea program
 	p := tea.NewProgram(NewMarketplaceModel(mgr, 80, 24, func() {
 		// On Back, we exit for now
-		os.Exit(0)
+		exit(0)
 	}))
 
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Error running marketplace: %v\n", err)
-		os.Exit(1)
+		exit(1)
 	}
 }
 
@@ -443,7 +498,7 @@ func runTUI() {
 	program := tea.NewProgram(m, tea.WithAltScreen())
 	if _, err := program.Run(); err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		exit(1)
 	}
 }
 
@@ -602,3 +657,14 @@ func (t *tuiAdapter) Log(level core.LogLevel, message string)        {}
 func (t *tuiAdapter) Confirm(message string, defaultYes bool) bool   { return defaultYes }
 func (t *tuiAdapter) Select(message string, options []string) int    { return 0 }
 func (t *tuiAdapter) Input(message string, defaultVal string) string { return defaultVal }
+
+// loadConfig reads the platform configuration, falling back to the
+// defaults when the file does not exist
+func loadConfig(path string) (*config.Config, error) {
+	cfg, err := config.Load(path)
+	if os.IsNotExist(err) {
+		fmt.Println(warnStyle.Render(fmt.Sprintf("⚠ %s not found, using default settings", path)))
+		return config.Default(), nil
+	}
+	return cfg, err
+}
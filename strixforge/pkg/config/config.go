@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is the platform configuration shipped next to the binary
+const DefaultPath = "configs/strixhalo.yaml"
+
+// App sources understood by the apps policy
+const (
+	SourcePacman  = "pacman"
+	SourceAUR     = "aur"
+	SourceFlatpak = "flatpak"
+)
+
+// Config represents the strixhalo.yaml platform configuration. Only the
+// sections read by the installer are modelled here.
+type Config struct {
+	Apps       AppsConfig     `yaml:"apps"`
+	Containers ContainerSpecs `yaml:"containers"`
+
+	// Runtime runs the workspace containers: "lxd" (default), "podman" or
+	// "docker"
+	Runtime string `yaml:"runtime"`
+
+	// UpdateContainers makes the workspace stage upgrade the packages of
+	// containers that already exist, rolling back any that fail their
+	// validate checks
+	UpdateContainers bool `yaml:"update-containers"`
+
+	// Volumes configures shared custom volumes by name. Volumes used by a
+	// container but not listed here go in the default pool.
+	Volumes map[string]VolumeSpec `yaml:"volumes"`
+
+	// Path is the absolute path the config was loaded from, empty for the
+	// built-in defaults
+	Path string `yaml:"-"`
+}
+
+// AppsConfig is the user's policy for where desktop apps come from
+type AppsConfig struct {
+	// Prefer lists sources in order of preference
+	Prefer []string `yaml:"prefer"`
+
+	// Sources pins individual apps (by catalog ID) to a source
+	Sources map[string]string `yaml:"sources"`
+}
+
+// Default returns the configuration used when no file is present
+func Default() *Config {
+	return &Config{
+		Apps: AppsConfig{
+			Prefer: []string{SourcePacman, SourceAUR, SourceFlatpak},
+		},
+		Containers: defaultContainers(),
+		Runtime:    RuntimeLXD,
+	}
+}
+
+// defaultContainers mirrors the containers section of the shipped config
+func defaultContainers() ContainerSpecs {
+	return ContainerSpecs{
+		{
+			Name:        "ai-lab",
+			Description: "ROCm/PyTorch",
+			Image:       "images:archlinux/current/cloud",
+			Packages: []string{
+				"rocm-hip-sdk", "python-pytorch-rocm", "python-numpy", "python-pip",
+				"git", "base-devel", "fastfetch", "vim", "ollama",
+			},
+			GPU: true,
+			Setup: []string{
+				"test -d /opt/ComfyUI || git clone https://github.com/comfyanonymous/ComfyUI /opt/ComfyUI",
+				"pip install -r /opt/ComfyUI/requirements.txt",
+			},
+			Services: []ServiceSpec{
+				{Name: "comfyui", Description: "ComfyUI", Port: PortSpec{Host: 8188, Container: 8188, Protocol: "tcp"}},
+				{Name: "ollama", Description: "Ollama API", Port: PortSpec{Host: 11434, Container: 11434, Protocol: "tcp"}},
+			},
+			Notes: []string{
+				"Run 'ollama pull llama3.2' to download a model",
+				"Run 'python /opt/ComfyUI/main.py' to start ComfyUI",
+			},
+			Volumes: []VolumeMount{{Name: "models", Path: "/models"}},
+			Environment: map[string]string{
+				"OLLAMA_MODELS": "/models/ollama",
+				"HF_HOME":       "/models/huggingface",
+			},
+			Validation: []ProbeSpec{
+				{Name: "rocminfo", Command: "rocminfo | grep -q gfx1151"},
+				{Name: "torch GPU", Command: "python -c 'import torch; assert torch.cuda.is_available()'"},
+			},
+			Limits:    Limits{Memory: "25%", Processes: 10000},
+			Snapshots: DefaultSnapshotPolicy(),
+		},
+		{
+			Name:        "dev-lab",
+			Description: "Rust/Go",
+			Image:       "images:archlinux/current/cloud",
+			Packages: []string{
+				"base-devel", "git", "rust", "go", "nodejs", "npm",
+				"python", "python-pip", "vim", "neovim", "fastfetch",
+			},
+			Nesting:   true,
+			Limits:    Limits{Memory: "15%", Processes: 4000},
+			Snapshots: DefaultSnapshotPolicy(),
+		},
+	}
+}
+
+// Load reads a configuration file, filling unset sections with defaults
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if len(cfg.Apps.Prefer) == 0 {
+		cfg.Apps.Prefer = Default().Apps.Prefer
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if cfg.Path, err = filepath.Abs(path); err != nil {
+		cfg.Path = path
+	}
+	return cfg, nil
+}
+
+// Validate checks values that would otherwise fail late during a stage
+func (c *Config) Validate() error {
+	for _, source := range c.Apps.Prefer {
+		if !validSource(source) {
+			return fmt.Errorf("apps.prefer: unknown source %q", source)
+		}
+	}
+	for app, source := range c.Apps.Sources {
+		if !validSource(source) {
+			return fmt.Errorf("apps.sources.%s: unknown source %q", app, source)
+		}
+	}
+	if err := c.validateRuntime(); err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, spec := range c.Containers {
+		if seen[spec.Name] {
+			return fmt.Errorf("containers.%s: defined twice", spec.Name)
+		}
+		seen[spec.Name] = true
+		if err := spec.Validate(); err != nil {
+			return err
+		}
+	}
+	return c.Containers.validatePortConflicts()
+}
+
+func validSource(source string) bool {
+	switch source {
+	case SourcePacman, SourceAUR, SourceFlatpak:
+		return true
+	}
+	return false
+}
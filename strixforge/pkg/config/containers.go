@@ -0,0 +1,217 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ContainerSpec declares a workspace container. The reconciler creates the
+// container if it is missing and updates it when the spec changes.
+type ContainerSpec struct {
+	Name        string                       `yaml:"-"`
+	Description string                       `yaml:"description"`
+	Image       string                       `yaml:"image"`
+	Packages    []string                     `yaml:"packages"`
+	GPU         bool                         `yaml:"gpu"`
+	Nesting     bool                         `yaml:"nesting"`
+	Profiles    []string                     `yaml:"profiles"`
+	Devices     map[string]map[string]string `yaml:"devices"`
+	Environment map[string]string            `yaml:"environment"`
+
+	// Golden is a published image (`strixforge containers publish`) to
+	// launch from instead of Image when it exists locally, provisioning
+	// only what changed since it was published
+	Golden string `yaml:"golden"`
+
+	// OCIImage is the image used under podman or docker, which cannot run
+	// LXD images; DefaultOCIImage when empty
+	OCIImage string `yaml:"oci-image"`
+
+	// Provision is how packages, users, files and setup are applied:
+	// "cloud-init" at first boot, "exec" through LXD, or empty to use
+	// cloud-init for cloud images
+	Provision string `yaml:"provision"`
+
+	// Users are accounts created in the container
+	Users []UserSpec `yaml:"users"`
+
+	// Files are written into the container before setup commands run
+	Files []FileSpec `yaml:"files"`
+
+	// Setup commands run through sh after packages are installed, and again
+	// whenever the list changes, so they should be safe to repeat
+	Setup []string `yaml:"setup"`
+
+	// Ports are exposed on the host, as "PORT" or "HOST:CONTAINER[/udp]"
+	Ports []PortSpec `yaml:"ports"`
+
+	// Services are named ports, listed with their URL by `strixforge services`
+	Services []ServiceSpec `yaml:"services"`
+
+	// Probes are custom readiness checks run after provisioning
+	Probes []ProbeSpec `yaml:"probes"`
+
+	// Validation are health checks run after an in-place update, such as
+	// rocminfo; the update is rolled back if one fails
+	Validation []ProbeSpec `yaml:"validate"`
+
+	// Notes are shown once the container is ready
+	Notes []string `yaml:"notes"`
+
+	// Mounts map host directories into the container
+	Mounts []MountSpec `yaml:"mounts"`
+
+	// Volumes attach shared custom storage volumes, e.g. a models volume
+	// used by several containers
+	Volumes []VolumeMount `yaml:"volumes"`
+
+	// Home mounts the user's home directory at /mnt/host-home: "ro" or "rw"
+	Home string `yaml:"home"`
+
+	// Limits caps CPU, memory and process use
+	Limits Limits `yaml:"limits"`
+
+	// Snapshots is the snapshot schedule and retention for the container
+	Snapshots SnapshotPolicy `yaml:"snapshots"`
+}
+
+// PortSpec forwards a host port into a container
+type PortSpec struct {
+	Host      int
+	Container int
+	Protocol  string // "tcp" or "udp"
+}
+
+// String formats the port as written in the config
+func (p PortSpec) String() string {
+	s := strconv.Itoa(p.Container)
+	if p.Host != p.Container {
+		s = fmt.Sprintf("%d:%d", p.Host, p.Container)
+	}
+	if p.Protocol != "tcp" {
+		s += "/" + p.Protocol
+	}
+	return s
+}
+
+// MarshalYAML writes the port in the short form UnmarshalYAML reads
+func (p PortSpec) MarshalYAML() (interface{}, error) {
+	return p.String(), nil
+}
+
+// UnmarshalYAML parses "8188", "8080:80" or "5353/udp"
+func (p *PortSpec) UnmarshalYAML(node *yaml.Node) error {
+	var value string
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	parsed, err := ParsePort(value)
+	if err != nil {
+		return fmt.Errorf("line %d: %v", node.Line, err)
+	}
+	*p = parsed
+	return nil
+}
+
+// ParsePort parses a port mapping
+func ParsePort(value string) (PortSpec, error) {
+	spec := PortSpec{Protocol: "tcp"}
+	ports, proto, found := strings.Cut(value, "/")
+	if found {
+		if proto != "tcp" && proto != "udp" {
+			return spec, fmt.Errorf("invalid protocol in port %q", value)
+		}
+		spec.Protocol = proto
+	}
+
+	host, container, found := strings.Cut(ports, ":")
+	if !found {
+		container = host
+	}
+	var err error
+	if spec.Host, err = parsePortNumber(host); err != nil {
+		return spec, fmt.Errorf("invalid port %q", value)
+	}
+	if spec.Container, err = parsePortNumber(container); err != nil {
+		return spec, fmt.Errorf("invalid port %q", value)
+	}
+	return spec, nil
+}
+
+func parsePortNumber(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 1 || n > 65535 {
+		return 0, fmt.Errorf("out of range")
+	}
+	return n, nil
+}
+
+// ContainerSpecs keeps containers in the order they appear in the file
+type ContainerSpecs []ContainerSpec
+
+// UnmarshalYAML decodes the name-keyed mapping, preserving order
+func (c *ContainerSpecs) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: containers must be a mapping of name to spec", node.Line)
+	}
+
+	specs := make(ContainerSpecs, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		var spec ContainerSpec
+		if err := node.Content[i+1].Decode(&spec); err != nil {
+			return err
+		}
+		spec.Name = node.Content[i].Value
+		specs = append(specs, spec)
+	}
+	*c = specs
+	return nil
+}
+
+// Find returns the spec with the given name
+func (c ContainerSpecs) Find(name string) (ContainerSpec, bool) {
+	for _, spec := range c {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return ContainerSpec{}, false
+}
+
+// containerNameRe matches valid LXD instance names
+var containerNameRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]{0,62}$`)
+
+// Validate checks a spec before anything is created
+func (s ContainerSpec) Validate() error {
+	if !containerNameRe.MatchString(s.Name) || strings.HasSuffix(s.Name, "-") {
+		return fmt.Errorf("containers.%s: invalid container name", s.Name)
+	}
+	if s.Image == "" {
+		return fmt.Errorf("containers.%s: image is required", s.Name)
+	}
+	for name, device := range s.Devices {
+		if device["type"] == "" {
+			return fmt.Errorf("containers.%s.devices.%s: type is required", s.Name, name)
+		}
+	}
+	if err := s.validateMounts(); err != nil {
+		return err
+	}
+	if err := s.Limits.Validate(); err != nil {
+		return fmt.Errorf("containers.%s.limits: %v", s.Name, err)
+	}
+	if err := s.Snapshots.Validate(); err != nil {
+		return fmt.Errorf("containers.%s.snapshots: %v", s.Name, err)
+	}
+	if err := s.validateProvision(); err != nil {
+		return err
+	}
+	if err := s.validateProbes(); err != nil {
+		return err
+	}
+	return s.validateForwards()
+}
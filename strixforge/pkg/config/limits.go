@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Limits caps the resources a container may use. Empty values leave the
+// LXD default, which is unlimited.
+type Limits struct {
+	// CPU is a CPU count ("8") or a set of CPUs ("0-7", "0,2,4")
+	CPU string `yaml:"cpu"`
+
+	// Memory is a size ("32GiB") or a share of host RAM ("50%")
+	Memory string `yaml:"memory"`
+
+	Processes int `yaml:"processes"`
+
+	// Swap allows the container to swap; unset keeps the LXD default
+	Swap *bool `yaml:"swap"`
+}
+
+var cpuLimitRe = regexp.MustCompile(`^\d+([-,]\d+)*$`)
+
+// Validate checks limit values before they reach LXD
+func (l Limits) Validate() error {
+	if l.CPU != "" && !cpuLimitRe.MatchString(l.CPU) {
+		return fmt.Errorf("invalid cpu limit %q", l.CPU)
+	}
+	if l.Memory != "" {
+		if _, _, err := ParseMemoryLimit(l.Memory); err != nil {
+			return err
+		}
+	}
+	if l.Processes < 0 {
+		return fmt.Errorf("processes limit must not be negative")
+	}
+	return nil
+}
+
+// sizeUnits are the suffixes LXD accepts for memory limits
+var sizeUnits = []struct {
+	suffix string
+	bytes  uint64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"kB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"B", 1},
+}
+
+// ParseMemoryLimit parses a memory limit. It returns either a size in bytes
+// or, for values like "50%", a percentage of host RAM.
+func ParseMemoryLimit(value string) (bytes uint64, percent float64, err error) {
+	value = strings.TrimSpace(value)
+	if n, ok := strings.CutSuffix(value, "%"); ok {
+		percent, err = strconv.ParseFloat(n, 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return 0, 0, fmt.Errorf("invalid memory limit %q", value)
+		}
+		return 0, percent, nil
+	}
+	for _, unit := range sizeUnits {
+		if n, ok := strings.CutSuffix(value, unit.suffix); ok {
+			count, err := strconv.ParseFloat(n, 64)
+			if err != nil || count <= 0 {
+				return 0, 0, fmt.Errorf("invalid memory limit %q", value)
+			}
+			return uint64(count * float64(unit.bytes)), 0, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid memory limit %q (use e.g. 32GiB or 50%%)", value)
+}
+
+// LXDConfig returns the instance config keys for the limits
+func (l Limits) LXDConfig() map[string]string {
+	cfg := make(map[string]string)
+	if l.CPU != "" {
+		cfg["limits.cpu"] = l.CPU
+	}
+	if l.Memory != "" {
+		cfg["limits.memory"] = l.Memory
+	}
+	if l.Processes > 0 {
+		cfg["limits.processes"] = strconv.Itoa(l.Processes)
+	}
+	if l.Swap != nil {
+		cfg["limits.memory.swap"] = strconv.FormatBool(*l.Swap)
+	}
+	return cfg
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+)
+
+// Home mount modes for ContainerSpec.Home
+const (
+	HomeNone      = ""
+	HomeReadOnly  = "ro"
+	HomeReadWrite = "rw"
+)
+
+// HomeMountPath is where the user's home directory appears in containers
+const HomeMountPath = "/mnt/host-home"
+
+// MountSpec maps a host directory into a container
+type MountSpec struct {
+	Source   string `yaml:"source"`
+	Path     string `yaml:"path"`
+	ReadOnly bool   `yaml:"readonly"`
+}
+
+// VolumeMount attaches a shared custom storage volume, such as "models",
+// to a container. Volumes are created on first use.
+type VolumeMount struct {
+	Name     string `yaml:"name"`
+	Path     string `yaml:"path"`
+	ReadOnly bool   `yaml:"readonly"`
+}
+
+// VolumeSpec sets where a custom volume lives and how big it may grow
+type VolumeSpec struct {
+	Pool        string `yaml:"pool"`
+	Size        string `yaml:"size"`
+	Description string `yaml:"description"`
+}
+
+// DefaultVolumePool is used for volumes without a pool in the config
+const DefaultVolumePool = "default"
+
+// validateMounts checks a spec's mounts, volumes and home mode
+func (s ContainerSpec) validateMounts() error {
+	paths := make(map[string]bool)
+	claim := func(p string) error {
+		if !path.IsAbs(p) {
+			return fmt.Errorf("containers.%s: mount path %q must be absolute", s.Name, p)
+		}
+		if paths[path.Clean(p)] {
+			return fmt.Errorf("containers.%s: %s is mounted twice", s.Name, p)
+		}
+		paths[path.Clean(p)] = true
+		return nil
+	}
+
+	for _, m := range s.Mounts {
+		if !filepath.IsAbs(m.Source) {
+			return fmt.Errorf("containers.%s.mounts: source %q must be an absolute host path", s.Name, m.Source)
+		}
+		if err := claim(m.Path); err != nil {
+			return err
+		}
+	}
+	for _, v := range s.Volumes {
+		if !containerNameRe.MatchString(v.Name) {
+			return fmt.Errorf("containers.%s.volumes: invalid volume name %q", s.Name, v.Name)
+		}
+		if err := claim(v.Path); err != nil {
+			return err
+		}
+	}
+	switch s.Home {
+	case HomeNone:
+	case HomeReadOnly, HomeReadWrite:
+		if err := claim(HomeMountPath); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("containers.%s.home: must be %q or %q", s.Name, HomeReadOnly, HomeReadWrite)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package config
+
+import "fmt"
+
+// ProbeSpec is a custom readiness check run inside a container after it is
+// provisioned, e.g. waiting for a service to answer. The command runs
+// through sh and passes when it exits 0; it is retried until the timeout.
+type ProbeSpec struct {
+	Name    string `yaml:"name"`
+	Command string `yaml:"command"`
+	Timeout Age    `yaml:"timeout"`
+}
+
+// validateProbes rejects probes and validation checks without a command
+func (s ContainerSpec) validateProbes() error {
+	lists := []struct {
+		field  string
+		probes []ProbeSpec
+	}{{"probes", s.Probes}, {"validate", s.Validation}}
+	for _, list := range lists {
+		field := list.field
+		for i, p := range list.probes {
+			if p.Command == "" {
+				return fmt.Errorf("containers.%s.%s[%d]: command is required", s.Name, field, i)
+			}
+			if p.Timeout < 0 {
+				return fmt.Errorf("containers.%s.%s[%d]: timeout must not be negative", s.Name, field, i)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// permissionsRe matches an octal file mode such as "644" or "0755"
+var permissionsRe = regexp.MustCompile(`^[0-7]{3,4}$`)
+
+// Provisioning methods for ContainerSpec.Provision
+const (
+	ProvisionAuto      = ""
+	ProvisionCloudInit = "cloud-init"
+	ProvisionExec      = "exec"
+)
+
+// UserSpec is a user account created in the container
+type UserSpec struct {
+	Name    string   `yaml:"name"`
+	Groups  []string `yaml:"groups,omitempty"`
+	Shell   string   `yaml:"shell,omitempty"`
+	Sudo    bool     `yaml:"sudo,omitempty"`
+	SSHKeys []string `yaml:"ssh-authorized-keys,omitempty"`
+}
+
+// FileSpec is a file written into the container
+type FileSpec struct {
+	Path        string `yaml:"path"`
+	Content     string `yaml:"content"`
+	Permissions string `yaml:"permissions,omitempty"` // octal, e.g. "0644"
+	Owner       string `yaml:"owner,omitempty"`       // "user:group"
+}
+
+// UsesCloudInit reports whether the container is provisioned by cloud-init
+// at first boot rather than by commands run through LXD. With the default
+// "auto", cloud images (".../cloud") use cloud-init unless the container
+// has a golden image, which is provisioned incrementally by exec.
+func (s ContainerSpec) UsesCloudInit() bool {
+	switch s.Provision {
+	case ProvisionCloudInit:
+		return true
+	case ProvisionExec:
+		return false
+	}
+	if s.Golden != "" {
+		return false
+	}
+	return strings.HasSuffix(s.Image, "/cloud") || strings.Contains(s.Image, "/cloud/")
+}
+
+// validateProvision checks the provisioning method, users and files
+func (s ContainerSpec) validateProvision() error {
+	switch s.Provision {
+	case ProvisionAuto, ProvisionCloudInit, ProvisionExec:
+	default:
+		return fmt.Errorf("containers.%s.provision: must be %q or %q", s.Name, ProvisionCloudInit, ProvisionExec)
+	}
+	if s.Golden != "" && s.Provision == ProvisionCloudInit {
+		return fmt.Errorf("containers.%s: golden images are provisioned by exec, not cloud-init", s.Name)
+	}
+	for _, u := range s.Users {
+		if !containerNameRe.MatchString(u.Name) || strings.ToLower(u.Name) != u.Name {
+			return fmt.Errorf("containers.%s.users: invalid user name %q", s.Name, u.Name)
+		}
+	}
+	for _, f := range s.Files {
+		if !path.IsAbs(f.Path) {
+			return fmt.Errorf("containers.%s.files: path %q must be absolute", s.Name, f.Path)
+		}
+		if f.Permissions != "" && !permissionsRe.MatchString(f.Permissions) {
+			return fmt.Errorf("containers.%s.files: permissions %q for %s must be octal, e.g. \"0644\"", s.Name, f.Permissions, f.Path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package config
+
+import "fmt"
+
+// Container runtimes for Config.Runtime
+const (
+	RuntimeLXD    = "lxd"
+	RuntimePodman = "podman"
+	RuntimeDocker = "docker"
+)
+
+// DefaultOCIImage is used by podman and docker for specs without oci-image
+const DefaultOCIImage = "docker.io/library/archlinux:latest"
+
+// OCI reports whether the config uses podman or docker
+func (c *Config) OCI() bool {
+	return c.Runtime == RuntimePodman || c.Runtime == RuntimeDocker
+}
+
+// ImageFor returns the image a spec uses under a runtime. LXD images such
+// as images:archlinux/current/cloud mean nothing to podman or docker.
+func (s ContainerSpec) ImageFor(runtime string) string {
+	if runtime == RuntimeLXD {
+		return s.Image
+	}
+	if s.OCIImage != "" {
+		return s.OCIImage
+	}
+	return DefaultOCIImage
+}
+
+// validateRuntime rejects unknown runtimes and the LXD-only spec options
+// when containers run under podman or docker
+func (c *Config) validateRuntime() error {
+	switch c.Runtime {
+	case RuntimeLXD, RuntimePodman, RuntimeDocker:
+	default:
+		return fmt.Errorf("runtime: unknown container runtime %q (use lxd, podman or docker)", c.Runtime)
+	}
+	if !c.OCI() {
+		return nil
+	}
+	for _, spec := range c.Containers {
+		switch {
+		case spec.Provision == ProvisionCloudInit:
+			return fmt.Errorf("containers.%s: provision: cloud-init needs the lxd runtime", spec.Name)
+		case len(spec.Profiles) > 0:
+			return fmt.Errorf("containers.%s: profiles need the lxd runtime", spec.Name)
+		case len(spec.Devices) > 0:
+			return fmt.Errorf("containers.%s: devices need the lxd runtime", spec.Name)
+		case spec.Golden != "":
+			return fmt.Errorf("containers.%s: golden images need the lxd runtime", spec.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ServiceSpec is a network service running in a container, such as
+// ComfyUI or ollama. Its port is forwarded to the host like an entry in
+// ports, and `strixforge services` lists it with its URL.
+type ServiceSpec struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Port        PortSpec `yaml:"port"`
+
+	// Scheme and Path build the URL shown to the user; scheme defaults to
+	// http. Services that are not web apps can set scheme to "tcp".
+	Scheme string `yaml:"scheme"`
+	Path   string `yaml:"path"`
+}
+
+var serviceNameRe = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// URL returns the address the service is reachable at from the host
+func (s ServiceSpec) URL() string {
+	scheme := s.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	path := s.Path
+	if path != "" && !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return fmt.Sprintf("%s://localhost:%d%s", scheme, s.Port.Host, path)
+}
+
+// Forwards returns every port forwarded from the host: the spec's ports
+// followed by its services' ports
+func (s ContainerSpec) Forwards() []PortSpec {
+	forwards := append([]PortSpec(nil), s.Ports...)
+	for _, svc := range s.Services {
+		forwards = append(forwards, svc.Port)
+	}
+	return forwards
+}
+
+// validateForwards rejects services without a usable name or port, and any
+// host port forwarded twice
+func (s ContainerSpec) validateForwards() error {
+	names := make(map[string]bool)
+	for _, svc := range s.Services {
+		if !serviceNameRe.MatchString(svc.Name) {
+			return fmt.Errorf("containers.%s.services: invalid service name %q", s.Name, svc.Name)
+		}
+		if names[svc.Name] {
+			return fmt.Errorf("containers.%s.services: %s defined twice", s.Name, svc.Name)
+		}
+		names[svc.Name] = true
+		if svc.Port.Host == 0 {
+			return fmt.Errorf("containers.%s.services.%s: port is required", s.Name, svc.Name)
+		}
+	}
+
+	hostPorts := make(map[string]bool)
+	for _, port := range s.Forwards() {
+		key := portKey(port)
+		if hostPorts[key] {
+			return fmt.Errorf("containers.%s: host port %s forwarded twice", s.Name, key)
+		}
+		hostPorts[key] = true
+	}
+	return nil
+}
+
+func portKey(p PortSpec) string {
+	return fmt.Sprintf("%d/%s", p.Host, p.Protocol)
+}
+
+// validatePortConflicts rejects two containers forwarding the same host port
+func (c ContainerSpecs) validatePortConflicts() error {
+	owner := make(map[string]string)
+	for _, spec := range c {
+		for _, port := range spec.Forwards() {
+			key := portKey(port)
+			if other, ok := owner[key]; ok {
+				return fmt.Errorf("containers.%s: host port %s is already forwarded to %s", spec.Name, key, other)
+			}
+			owner[key] = spec.Name
+		}
+	}
+	return nil
+}
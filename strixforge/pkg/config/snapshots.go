@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SnapshotPolicy says which snapshots are taken of a container and how many
+// are kept. Counts of zero disable that kind of snapshot.
+type SnapshotPolicy struct {
+	Hourly int `yaml:"hourly"`
+	Daily  int `yaml:"daily"`
+	Weekly int `yaml:"weekly"`
+
+	// BeforeChange keeps this many snapshots taken before hub installs and
+	// package updates
+	BeforeChange int `yaml:"before-change"`
+
+	// MaxAge removes automatic snapshots older than this, whatever the counts
+	MaxAge Age `yaml:"max-age"`
+}
+
+// DefaultSnapshotPolicy is used for containers without a spec, such as
+// ones created by hand, so hub installs still take a snapshot first
+func DefaultSnapshotPolicy() SnapshotPolicy {
+	return SnapshotPolicy{Daily: 7, Weekly: 4, BeforeChange: 5}
+}
+
+// Scheduled reports whether the policy needs the snapshot timer
+func (p SnapshotPolicy) Scheduled() bool {
+	return p.Hourly > 0 || p.Daily > 0 || p.Weekly > 0
+}
+
+// Validate rejects negative counts
+func (p SnapshotPolicy) Validate() error {
+	if p.Hourly < 0 || p.Daily < 0 || p.Weekly < 0 || p.BeforeChange < 0 || p.MaxAge < 0 {
+		return fmt.Errorf("snapshot counts and max-age must not be negative")
+	}
+	return nil
+}
+
+// Age is a duration that also accepts days and weeks, e.g. "30d" or "2w"
+type Age time.Duration
+
+// ParseAge parses a Go duration or a number of days ("d") or weeks ("w")
+func ParseAge(value string) (Age, error) {
+	value = strings.TrimSpace(value)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(value, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil {
+				return 0, fmt.Errorf("invalid age %q", value)
+			}
+			return Age(time.Duration(count) * unit), nil
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q", value)
+	}
+	return Age(d), nil
+}
+
+// MarshalYAML writes the age as a Go duration, which ParseAge accepts
+func (a Age) MarshalYAML() (interface{}, error) {
+	return time.Duration(a).String(), nil
+}
+
+// UnmarshalYAML parses an age such as "30d"
+func (a *Age) UnmarshalYAML(node *yaml.Node) error {
+	var value string
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	parsed, err := ParseAge(value)
+	if err != nil {
+		return fmt.Errorf("line %d: %v", node.Line, err)
+	}
+	*a = parsed
+	return nil
+}
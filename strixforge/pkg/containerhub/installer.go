@@ -3,40 +3,71 @@ package containerhub
 import (
 	"context"
 	"fmt"
-	"os/exec"
 
+	"github.com/daveweinstein1/strixforge/pkg/config"
 	"github.com/daveweinstein1/strixforge/pkg/system"
+	"github.com/daveweinstein1/strixforge/pkg/workspace"
 )
 
 type Installer struct {
-	lxd *system.LXD
+	runtime   system.ContainerRuntime
+	snapshots config.SnapshotPolicy
 }
 
-func NewInstaller() *Installer {
-	return &Installer{
-		lxd: system.NewLXD(),
-	}
+// NewInstaller creates an installer for containers run by runtime. The
+// target container is snapshotted according to policy before installing.
+func NewInstaller(runtime system.ContainerRuntime, policy config.SnapshotPolicy) *Installer {
+	return &Installer{runtime: runtime, snapshots: policy}
 }
 
-// InstallImage installs a selected toolbox image into a target LXD container
-// cmd: lxc exec <targetContainer> -- toolbox create <toolboxName> --image <imageURL>
+// InstallImage installs a selected toolbox image into a target container
+// cmd: <runtime> exec <targetContainer> -- toolbox create <toolboxName> --image <imageURL>
 func (i *Installer) InstallImage(ctx context.Context, targetContainer, toolboxName, imageURL string) error {
 	// 1. Ensure target container exists
-	if !i.lxd.ContainerExists(ctx, targetContainer) {
+	state, err := i.runtime.State(ctx, targetContainer)
+	if err != nil {
+		return fmt.Errorf("failed to look up target container '%s': %v", targetContainer, err)
+	}
+	if state == system.ContainerMissing {
 		return fmt.Errorf("target container '%s' does not exist", targetContainer)
 	}
 
-	// 2. Run toolbox create command inside the container
+	// 2. Snapshot the container so a bad install can be rolled back
+	reason := fmt.Sprintf("before hub install of %s (%s)", toolboxName, imageURL)
+	if err := i.snapshot(ctx, targetContainer, reason); err != nil {
+		return fmt.Errorf("failed to snapshot '%s' before install: %v", targetContainer, err)
+	}
+
+	// 3. Run toolbox create command inside the container
 	// Note: toolbox create might prompt or take time. We assume non-interactive here?
 	// toolbox create -c <name> -i <image> -y (to auto-accept)
-
-	cmd := exec.CommandContext(ctx, "lxc", "exec", targetContainer, "--",
+	result, err := i.runtime.Exec(ctx, targetContainer,
 		"toolbox", "create", "-c", toolboxName, "-i", imageURL, "-y")
-
-	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("toolbox installation failed: %v\nOutput: %s", err, string(output))
+		output := ""
+		if result != nil {
+			output = result.Stdout + result.Stderr
+		}
+		return fmt.Errorf("toolbox installation failed: %v\nOutput: %s", err, output)
+	}
+
+	// 4. Remember the install so container exports can list it
+	if lxdRuntime, ok := i.runtime.(*workspace.LXDRuntime); ok {
+		client, err := lxdRuntime.Client()
+		if err == nil {
+			err = workspace.RecordHubImage(ctx, client, targetContainer, toolboxName, imageURL)
+		}
+		if err != nil {
+			return fmt.Errorf("installed, but failed to record hub image on '%s': %v", targetContainer, err)
+		}
 	}
 
 	return nil
 }
+
+// snapshot takes a before-change snapshot and prunes older ones per the
+// retention policy
+func (i *Installer) snapshot(ctx context.Context, container, reason string) error {
+	_, err := workspace.NewSnapshotter(i.runtime).BeforeChange(ctx, container, i.snapshots, reason)
+	return err
+}
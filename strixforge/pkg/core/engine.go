@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -134,6 +135,14 @@ func (e *Engine) runStage(ctx context.Context, stage Stage, num, total int) Stag
 	if err != nil {
 		result.Status = StatusFailed
 		e.ui.Log(LogError, fmt.Sprintf("Failed: %s - %v", stage.Name(), err))
+
+		var diag *DiagnosticError
+		if errors.As(err, &diag) {
+			result.Logs = append(result.Logs, diag.Logs...)
+			for _, entry := range diag.Logs {
+				e.ui.Log(entry.Level, "  "+entry.Message)
+			}
+		}
 	} else {
 		result.Status = StatusSuccess
 		e.ui.Log(LogInfo, fmt.Sprintf("Complete: %s (%v)", stage.Name(), duration.Round(time.Second)))
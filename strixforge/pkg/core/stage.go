@@ -43,6 +43,12 @@ type Stage interface {
 	Rollback(ctx context.Context) error
 }
 
+// PackageProvider is implemented by stages that install host packages.
+// The offline bundle uses it to collect everything a stage needs.
+type PackageProvider interface {
+	Packages() []string
+}
+
 // StageResult captures the outcome of running a stage
 type StageResult struct {
 	StageID   string
@@ -84,3 +90,19 @@ func (l LogLevel) String() string {
 		return "UNKNOWN"
 	}
 }
+
+// DiagnosticError wraps a stage error with log lines that explain it, such
+// as the journal of a service that failed to start. The engine attaches
+// them to the StageResult and shows them in the UI.
+type DiagnosticError struct {
+	Err  error
+	Logs []LogEntry
+}
+
+func (e *DiagnosticError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DiagnosticError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,62 @@
+package lxd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// BackupsPost creates a backup of an instance
+type BackupsPost struct {
+	Name      string     `json:"name"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// InstanceOnly leaves snapshots out of the backup
+	InstanceOnly bool `json:"instance_only"`
+
+	// OptimizedStorage uses the storage driver's native format (zfs send,
+	// btrfs send). Smaller and faster, but can only be imported into a pool
+	// with the same driver.
+	OptimizedStorage bool `json:"optimized_storage"`
+
+	CompressionAlgorithm string `json:"compression_algorithm,omitempty"`
+}
+
+// CreateBackup creates a backup of an instance on the server
+func (c *Client) CreateBackup(ctx context.Context, instance string, req BackupsPost, progress ProgressFunc) error {
+	_, err := c.async(ctx, http.MethodPost, instancePath(instance, "backups"), req, progress)
+	return err
+}
+
+// ExportBackup streams a backup tarball to w and returns the bytes written
+func (c *Client) ExportBackup(ctx context.Context, instance, backup string, w io.Writer) (int64, error) {
+	body, err := c.stream(ctx, instancePath(instance, "backups", url.PathEscape(backup), "export"))
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	return io.Copy(w, body)
+}
+
+// DeleteBackup removes a backup from the server
+func (c *Client) DeleteBackup(ctx context.Context, instance, backup string) error {
+	_, err := c.async(ctx, http.MethodDelete, instancePath(instance, "backups", url.PathEscape(backup)), nil, nil)
+	return err
+}
+
+// ImportBackup creates an instance from a backup tarball. An empty name
+// keeps the name stored in the backup; an empty pool uses the one in the
+// backup.
+func (c *Client) ImportBackup(ctx context.Context, r io.Reader, name, pool string, progress ProgressFunc) error {
+	headers := make(map[string]string)
+	if name != "" {
+		headers["X-LXD-name"] = name
+	}
+	if pool != "" {
+		headers["X-LXD-pool"] = pool
+	}
+	_, err := c.upload(ctx, "/1.0/instances", r, headers, progress)
+	return err
+}
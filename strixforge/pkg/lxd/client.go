@@ -0,0 +1,263 @@
+// Package lxd is a client for the LXD REST API over its unix socket.
+package lxd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SocketPaths are probed in order when no socket is configured
+var SocketPaths = []string{
+	"/var/lib/lxd/unix.socket",
+	"/var/snap/lxd/common/lxd/unix.socket",
+}
+
+// DefaultSocket overrides socket discovery when set (e.g. from --lxd-socket)
+var DefaultSocket string
+
+// Client talks to the LXD daemon
+type Client struct {
+	http    *http.Client
+	baseURL string
+}
+
+// NewClient creates a client for an LXD API reachable at baseURL. Connect
+// is the usual entry point; this is for non-socket transports and tests.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{http: httpClient, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Connect returns a client for the local daemon. An empty socket uses
+// DefaultSocket, then $LXD_SOCKET and $LXD_DIR, then SocketPaths.
+func Connect(socket string) (*Client, error) {
+	if socket == "" {
+		socket = FindSocket()
+	}
+	if socket == "" {
+		return nil, fmt.Errorf("LXD socket not found (tried %s); is LXD installed and running?", strings.Join(SocketPaths, ", "))
+	}
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	return NewClient("http://lxd", &http.Client{Transport: transport}), nil
+}
+
+// FindSocket returns the first LXD socket that exists, or ""
+func FindSocket() string {
+	candidates := []string{DefaultSocket, os.Getenv("LXD_SOCKET")}
+	if dir := os.Getenv("LXD_DIR"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "unix.socket"))
+	}
+	candidates = append(candidates, SocketPaths...)
+
+	for _, path := range candidates {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return path
+		}
+	}
+	return ""
+}
+
+// response is the envelope of every LXD API reply
+type response struct {
+	Type       string          `json:"type"` // sync, async or error
+	Status     string          `json:"status"`
+	StatusCode int             `json:"status_code"`
+	Operation  string          `json:"operation"`
+	ErrorCode  int             `json:"error_code"`
+	Error      string          `json:"error"`
+	Metadata   json.RawMessage `json:"metadata"`
+}
+
+// do sends a request and decodes the envelope. Error replies are returned
+// as *Error.
+func (c *Client) do(ctx context.Context, method, path string, body interface{}) (*response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("LXD request %s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	var r response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, &Error{Method: method, Path: path, StatusCode: resp.StatusCode, Message: fmt.Sprintf("invalid response: %v", err)}
+	}
+	if r.Type == "error" || resp.StatusCode >= 400 {
+		code := r.ErrorCode
+		if code == 0 {
+			code = resp.StatusCode
+		}
+		return nil, &Error{Method: method, Path: path, StatusCode: code, Message: r.Error}
+	}
+	return &r, nil
+}
+
+// get fetches a sync resource into out
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	r, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	return decodeMetadata(r, out)
+}
+
+// sync sends a request that completes immediately
+func (c *Client) sync(ctx context.Context, method, path string, body interface{}) error {
+	_, err := c.do(ctx, method, path, body)
+	return err
+}
+
+// async sends a request that starts an operation and waits for it
+func (c *Client) async(ctx context.Context, method, path string, body interface{}, progress ProgressFunc) (*Operation, error) {
+	r, err := c.do(ctx, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	if r.Type != "async" {
+		// Completed synchronously
+		return &Operation{Status: r.Status, StatusCode: StatusSuccess}, nil
+	}
+	return c.WaitOperation(ctx, r.Operation, progress)
+}
+
+// raw fetches a non-JSON resource such as an exec output log
+func (c *Client) raw(ctx context.Context, path string) ([]byte, error) {
+	body, err := c.stream(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// stream opens a non-JSON resource, such as a backup tarball, for reading.
+// The caller closes the returned body.
+func (c *Client) stream(ctx context.Context, path string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("LXD request GET %s failed: %v", path, err)
+	}
+	if resp.StatusCode >= 400 {
+		resp.Body.Close()
+		return nil, &Error{Method: http.MethodGet, Path: path, StatusCode: resp.StatusCode, Message: resp.Status}
+	}
+	return resp.Body, nil
+}
+
+// upload posts a binary body, such as a backup tarball, with extra headers
+// and waits for the operation it starts
+func (c *Client) upload(ctx context.Context, path string, body io.Reader, headers map[string]string, progress ProgressFunc) (*Operation, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("LXD request POST %s failed: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	var r response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, &Error{Method: http.MethodPost, Path: path, StatusCode: resp.StatusCode, Message: fmt.Sprintf("invalid response: %v", err)}
+	}
+	if r.Type == "error" || resp.StatusCode >= 400 {
+		return nil, &Error{Method: http.MethodPost, Path: path, StatusCode: resp.StatusCode, Message: r.Error}
+	}
+	if r.Type != "async" {
+		return &Operation{Status: r.Status, StatusCode: StatusSuccess}, nil
+	}
+	return c.WaitOperation(ctx, r.Operation, progress)
+}
+
+func decodeMetadata(r *response, out interface{}) error {
+	if out == nil || len(r.Metadata) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(r.Metadata, out); err != nil {
+		return fmt.Errorf("failed to decode LXD response: %v", err)
+	}
+	return nil
+}
+
+// instancePath returns the API path of an instance or one of its sub-resources
+func instancePath(name string, sub ...string) string {
+	parts := append([]string{"/1.0/instances", url.PathEscape(name)}, sub...)
+	return strings.Join(parts, "/")
+}
+
+// Server describes the daemon
+type Server struct {
+	APIVersion  string `json:"api_version"`
+	Auth        string `json:"auth"`
+	Environment struct {
+		ServerVersion string   `json:"server_version"`
+		Storage       string   `json:"storage"`
+		Kernel        string   `json:"kernel_version"`
+		Driver        string   `json:"driver"`
+		Addresses     []string `json:"addresses"`
+
+		// KernelFeatures reports e.g. "idmapped_mounts": "true"
+		KernelFeatures map[string]string `json:"kernel_features"`
+	} `json:"environment"`
+}
+
+// IdmappedMounts reports whether disk devices can use shift=true to map
+// host file ownership into unprivileged containers
+func (s *Server) IdmappedMounts() bool {
+	return s.Environment.KernelFeatures["idmapped_mounts"] == "true"
+}
+
+// Server returns daemon information, which also verifies connectivity
+func (c *Client) Server(ctx context.Context) (*Server, error) {
+	var s Server
+	if err := c.get(ctx, "/1.0", &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,273 @@
+package lxd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLXD is an httptest server answering with LXD response envelopes
+type fakeLXD struct {
+	*httptest.Server
+	mux *http.ServeMux
+
+	mu       sync.Mutex
+	requests []string // "METHOD path" in order
+}
+
+func newFakeLXD(t *testing.T) (*fakeLXD, *Client) {
+	t.Helper()
+	f := &fakeLXD{mux: http.NewServeMux()}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.requests = append(f.requests, r.Method+" "+r.URL.RequestURI())
+		f.mu.Unlock()
+		f.mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(f.Close)
+
+	interval := operationPollInterval
+	operationPollInterval = time.Millisecond
+	t.Cleanup(func() { operationPollInterval = interval })
+
+	return f, NewClient(f.URL+"/", f.Client())
+}
+
+func (f *fakeLXD) seen(request string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range f.requests {
+		if r == request {
+			return true
+		}
+	}
+	return false
+}
+
+func writeEnvelope(w http.ResponseWriter, status int, envelope map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(envelope)
+}
+
+func writeSync(w http.ResponseWriter, metadata interface{}) {
+	writeEnvelope(w, http.StatusOK, map[string]interface{}{
+		"type": "sync", "status": "Success", "status_code": 200, "metadata": metadata,
+	})
+}
+
+func writeAsync(w http.ResponseWriter, operation string) {
+	writeEnvelope(w, http.StatusAccepted, map[string]interface{}{
+		"type": "async", "status": "Operation created", "status_code": 100, "operation": operation,
+	})
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeEnvelope(w, code, map[string]interface{}{
+		"type": "error", "error": message, "error_code": code,
+	})
+}
+
+func TestSyncResponse(t *testing.T) {
+	f, c := newFakeLXD(t)
+	f.mux.HandleFunc("/1.0/instances/ai-lab", func(w http.ResponseWriter, r *http.Request) {
+		writeSync(w, map[string]interface{}{
+			"name":     "ai-lab",
+			"status":   "Running",
+			"config":   map[string]string{"limits.memory": "64GiB"},
+			"profiles": []string{"default", "strix-gpu"},
+		})
+	})
+	f.mux.HandleFunc("/1.0/instances/ai-lab/state", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("state change used %s", r.Method)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["action"] != "start" {
+			t.Errorf("state change body = %v, %v", body, err)
+		}
+		writeSync(w, nil)
+	})
+
+	ctx := context.Background()
+	instance, err := c.Instance(ctx, "ai-lab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instance.Name != "ai-lab" || instance.Status != "Running" || instance.Config["limits.memory"] != "64GiB" || len(instance.Profiles) != 2 {
+		t.Errorf("Instance = %+v", instance)
+	}
+	if !f.seen("GET /1.0/instances/ai-lab") {
+		t.Errorf("requests = %v", f.requests)
+	}
+
+	// A sync reply to a request that may be async completes immediately
+	if err := c.StartInstance(ctx, "ai-lab"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAsyncOperationWait(t *testing.T) {
+	f, c := newFakeLXD(t)
+	var polls int
+	f.mux.HandleFunc("/1.0/images", func(w http.ResponseWriter, r *http.Request) {
+		writeAsync(w, "/1.0/operations/op-1")
+	})
+	f.mux.HandleFunc("/1.0/operations/op-1", func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		op := map[string]interface{}{"id": "op-1", "status": "Running", "status_code": StatusRunning}
+		switch polls {
+		case 1:
+			op["metadata"] = map[string]interface{}{"create_image_from_container_pack_progress": "Image pack: 45% (12.3MB/s)"}
+		case 2:
+			// Repeated progress is not reported again
+			op["metadata"] = map[string]interface{}{"create_image_from_container_pack_progress": "Image pack: 45% (12.3MB/s)"}
+		default:
+			op["status"], op["status_code"] = "Success", StatusSuccess
+			op["metadata"] = map[string]interface{}{"fingerprint": "abc123"}
+		}
+		writeSync(w, op)
+	})
+
+	var updates []Progress
+	fingerprint, err := c.PublishImage(context.Background(), ImagesPost{}, func(p Progress) { updates = append(updates, p) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fingerprint != "abc123" {
+		t.Errorf("fingerprint = %q", fingerprint)
+	}
+	if polls != 3 {
+		t.Errorf("operation polled %d times, want 3", polls)
+	}
+	if len(updates) != 1 || updates[0].Percent != 45 || updates[0].Stage != "create_image_from_container_pack_progress" {
+		t.Errorf("progress = %+v", updates)
+	}
+}
+
+func TestAsyncOperationFailure(t *testing.T) {
+	f, c := newFakeLXD(t)
+	f.mux.HandleFunc("/1.0/instances", func(w http.ResponseWriter, r *http.Request) {
+		writeAsync(w, "op-2") // a bare ID rather than a URL
+	})
+	f.mux.HandleFunc("/1.0/operations/op-2", func(w http.ResponseWriter, r *http.Request) {
+		writeSync(w, map[string]interface{}{
+			"id": "op-2", "status": "Failure", "status_code": StatusFailure,
+			"err": "Failed getting remote image info: not found",
+		})
+	})
+
+	err := c.CreateInstance(context.Background(), InstancesPost{Name: "ai-lab"}, nil)
+	var lxdErr *Error
+	if !errors.As(err, &lxdErr) {
+		t.Fatalf("err = %v, want *Error", err)
+	}
+	if lxdErr.StatusCode != StatusFailure || !strings.Contains(lxdErr.Message, "remote image info") {
+		t.Errorf("err = %+v", lxdErr)
+	}
+}
+
+func TestWaitOperationCancel(t *testing.T) {
+	f, c := newFakeLXD(t)
+	cancelled := make(chan struct{})
+	f.mux.HandleFunc("/1.0/operations/op-3", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			close(cancelled)
+			writeSync(w, nil)
+			return
+		}
+		writeSync(w, map[string]interface{}{"id": "op-3", "status": "Running", "status_code": StatusRunning, "may_cancel": true})
+	})
+
+	// The deadline passes while waiting between polls
+	operationPollInterval = time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.WaitOperation(ctx, "op-3", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("operation was not cancelled")
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	f, c := newFakeLXD(t)
+	f.mux.HandleFunc("/1.0/instances/missing", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "Instance not found")
+	})
+	f.mux.HandleFunc("/1.0/instances/broken", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusInternalServerError, "Failed to load instance")
+	})
+	f.mux.HandleFunc("/1.0/images/aliases", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusConflict, "Alias already exists")
+	})
+	f.mux.HandleFunc("/1.0/instances/garbled", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>proxy error</html>"))
+	})
+
+	ctx := context.Background()
+	_, err := c.Instance(ctx, "missing")
+	if !IsNotFound(err) {
+		t.Errorf("err = %v, want not found", err)
+	}
+	if exists, err := c.InstanceExists(ctx, "missing"); exists || err != nil {
+		t.Errorf("InstanceExists(missing) = %v, %v", exists, err)
+	}
+
+	exists, err := c.InstanceExists(ctx, "broken")
+	if exists || err == nil || IsNotFound(err) {
+		t.Errorf("InstanceExists(broken) = %v, %v; want the server error", exists, err)
+	}
+	if want := "LXD GET /1.0/instances/broken: Failed to load instance (500)"; err.Error() != want {
+		t.Errorf("err = %q, want %q", err, want)
+	}
+
+	if err := c.CreateImageAlias(ctx, ImageAlias{Name: "strix/ai-lab"}); !IsConflict(err) {
+		t.Errorf("err = %v, want conflict", err)
+	}
+
+	_, err = c.Instance(ctx, "garbled")
+	if err == nil || !strings.Contains(err.Error(), "invalid response") {
+		t.Errorf("err = %v, want an invalid response error", err)
+	}
+}
+
+func TestParseImage(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    InstanceSource
+		wantErr bool
+	}{
+		{ref: "images:archlinux/current", want: InstanceSource{
+			Type: "image", Alias: "archlinux/current", Server: "https://images.lxd.canonical.com", Protocol: "simplestreams", Mode: "pull",
+		}},
+		{ref: "ubuntu:24.04", want: InstanceSource{
+			Type: "image", Alias: "24.04", Server: ImageRemotes["ubuntu"], Protocol: "simplestreams", Mode: "pull",
+		}},
+		{ref: "local:strix/ai-lab", want: InstanceSource{Type: "image", Alias: "strix/ai-lab"}},
+		{ref: "strix/ai-lab:2026-10", want: InstanceSource{Type: "image", Alias: "strix/ai-lab:2026-10"}},
+		{ref: "ai-lab", want: InstanceSource{Type: "image", Alias: "ai-lab"}},
+		{ref: "nowhere:arch", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseImage(tt.ref)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseImage(%q) succeeded", tt.ref)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseImage(%q) = %+v, %v; want %+v", tt.ref, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package lxd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error is a failed API request or operation
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int // HTTP status, or the operation status code
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("LXD: %s", e.Message)
+	}
+	return fmt.Sprintf("LXD %s %s: %s (%d)", e.Method, e.Path, e.Message, e.StatusCode)
+}
+
+// IsNotFound reports whether err means the resource does not exist
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// IsConflict reports whether err means the resource already exists
+func IsConflict(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusConflict
+}
+
+// ExitError is returned by Exec when the command exits non-zero
+type ExitError struct {
+	Command []string
+	Code    int
+	Stderr  string
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
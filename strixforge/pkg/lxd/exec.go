@@ -0,0 +1,73 @@
+package lxd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ExecRequest runs a non-interactive command in an instance
+type ExecRequest struct {
+	Command     []string          `json:"command"`
+	Environment map[string]string `json:"environment,omitempty"`
+	Cwd         string            `json:"cwd,omitempty"`
+	User        uint32            `json:"user,omitempty"`
+	Group       uint32            `json:"group,omitempty"`
+
+	// LXD writes output to log files instead of websockets
+	RecordOutput     bool `json:"record-output"`
+	WaitForWebsocket bool `json:"wait-for-websocket"`
+	Interactive      bool `json:"interactive"`
+}
+
+// ExecResult holds the output of a command run in an instance
+type ExecResult struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+}
+
+// Exec runs a command in an instance and collects its output. Like
+// system.Exec, a non-zero exit status is returned as an error (*ExitError)
+// together with the result.
+func (c *Client) Exec(ctx context.Context, instance string, command ...string) (*ExecResult, error) {
+	return c.ExecWith(ctx, instance, ExecRequest{Command: command})
+}
+
+// ExecWith runs a command with a custom environment, directory or user
+func (c *Client) ExecWith(ctx context.Context, instance string, req ExecRequest) (*ExecResult, error) {
+	req.RecordOutput = true
+	req.WaitForWebsocket = false
+	req.Interactive = false
+
+	op, err := c.async(ctx, http.MethodPost, instancePath(instance, "exec"), req, nil)
+	if err != nil {
+		return nil, fmt.Errorf("exec in %s failed: %w", instance, err)
+	}
+
+	result := &ExecResult{ExitCode: -1}
+	if code, ok := op.Metadata["return"].(float64); ok {
+		result.ExitCode = int(code)
+	}
+
+	// Output logs are keyed by file descriptor
+	if output, ok := op.Metadata["output"].(map[string]interface{}); ok {
+		for fd, dst := range map[string]*string{"1": &result.Stdout, "2": &result.Stderr} {
+			path, _ := output[fd].(string)
+			if path == "" {
+				continue
+			}
+			data, err := c.raw(ctx, path)
+			if err != nil {
+				return result, err
+			}
+			*dst = string(data)
+			_ = c.sync(ctx, http.MethodDelete, path, nil)
+		}
+	}
+
+	if result.ExitCode != 0 {
+		return result, &ExitError{Command: req.Command, Code: result.ExitCode, Stderr: result.Stderr}
+	}
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package lxd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Image is an image in the local image store
+type Image struct {
+	Fingerprint  string            `json:"fingerprint"`
+	Filename     string            `json:"filename"`
+	Size         int64             `json:"size"`
+	Architecture string            `json:"architecture"`
+	Public       bool              `json:"public"`
+	Properties   map[string]string `json:"properties"`
+	Aliases      []ImageAlias      `json:"aliases"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UploadedAt   time.Time         `json:"uploaded_at"`
+}
+
+// ImageAlias names an image
+type ImageAlias struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Target      string `json:"target,omitempty"` // fingerprint
+}
+
+// ImageSource is what a new image is published from
+type ImageSource struct {
+	Type string `json:"type"` // "instance" or "snapshot"
+	Name string `json:"name"` // "NAME" or "NAME/SNAPSHOT"
+}
+
+// ImagesPost publishes an instance or snapshot as an image
+type ImagesPost struct {
+	Source     ImageSource       `json:"source"`
+	Properties map[string]string `json:"properties,omitempty"`
+	Public     bool              `json:"public"`
+}
+
+func imagePath(fingerprint string) string {
+	return "/1.0/images/" + url.PathEscape(fingerprint)
+}
+
+func aliasPath(name string) string {
+	return "/1.0/images/aliases/" + url.PathEscape(name)
+}
+
+// Images returns every local image
+func (c *Client) Images(ctx context.Context) ([]Image, error) {
+	var images []Image
+	if err := c.get(ctx, "/1.0/images?recursion=1", &images); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// Image returns a local image by fingerprint
+func (c *Client) Image(ctx context.Context, fingerprint string) (*Image, error) {
+	var image Image
+	if err := c.get(ctx, imagePath(fingerprint), &image); err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+// ImageAlias returns a local alias and the fingerprint it points to
+func (c *Client) ImageAlias(ctx context.Context, name string) (*ImageAlias, error) {
+	var alias ImageAlias
+	if err := c.get(ctx, aliasPath(name), &alias); err != nil {
+		return nil, err
+	}
+	return &alias, nil
+}
+
+// PublishImage creates an image from an instance or snapshot and returns
+// its fingerprint. Publishing a running instance fails; publish a snapshot
+// instead.
+func (c *Client) PublishImage(ctx context.Context, req ImagesPost, progress ProgressFunc) (string, error) {
+	op, err := c.async(ctx, http.MethodPost, "/1.0/images", req, progress)
+	if err != nil {
+		return "", err
+	}
+	fingerprint, _ := op.Metadata["fingerprint"].(string)
+	if fingerprint == "" {
+		return "", fmt.Errorf("LXD did not report the new image's fingerprint")
+	}
+	return fingerprint, nil
+}
+
+// CreateImageAlias points a new alias at an image
+func (c *Client) CreateImageAlias(ctx context.Context, alias ImageAlias) error {
+	return c.sync(ctx, http.MethodPost, "/1.0/images/aliases", alias)
+}
+
+// UpdateImageAlias points an existing alias at another image
+func (c *Client) UpdateImageAlias(ctx context.Context, alias ImageAlias) error {
+	return c.sync(ctx, http.MethodPut, aliasPath(alias.Name), map[string]string{
+		"description": alias.Description,
+		"target":      alias.Target,
+	})
+}
+
+// DeleteImage removes an image and its aliases
+func (c *Client) DeleteImage(ctx context.Context, fingerprint string) error {
+	_, err := c.async(ctx, http.MethodDelete, imagePath(fingerprint), nil, nil)
+	return err
+}
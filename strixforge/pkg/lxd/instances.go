@@ -0,0 +1,110 @@
+package lxd
+
+import (
+	"context"
+	"net/http"
+)
+
+// Instances returns all instances
+func (c *Client) Instances(ctx context.Context) ([]Instance, error) {
+	var instances []Instance
+	if err := c.get(ctx, "/1.0/instances?recursion=1", &instances); err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// Instance returns a single instance
+func (c *Client) Instance(ctx context.Context, name string) (*Instance, error) {
+	var instance Instance
+	if err := c.get(ctx, instancePath(name), &instance); err != nil {
+		return nil, err
+	}
+	return &instance, nil
+}
+
+// InstanceExists checks if an instance exists. Unlike a failed lookup,
+// errors other than "not found" are returned.
+func (c *Client) InstanceExists(ctx context.Context, name string) (bool, error) {
+	_, err := c.Instance(ctx, name)
+	if IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// InstanceState returns runtime state such as addresses and memory use
+func (c *Client) InstanceState(ctx context.Context, name string) (*InstanceState, error) {
+	var state InstanceState
+	if err := c.get(ctx, instancePath(name, "state"), &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// CreateInstance creates an instance and waits for image download and unpacking
+func (c *Client) CreateInstance(ctx context.Context, req InstancesPost, progress ProgressFunc) error {
+	_, err := c.async(ctx, http.MethodPost, "/1.0/instances", req, progress)
+	return err
+}
+
+// UpdateInstance replaces an instance's config, devices and profiles
+func (c *Client) UpdateInstance(ctx context.Context, name string, put InstancePut) error {
+	_, err := c.async(ctx, http.MethodPut, instancePath(name), put, nil)
+	return err
+}
+
+// RenameInstance renames a stopped instance
+func (c *Client) RenameInstance(ctx context.Context, name, newName string) error {
+	_, err := c.async(ctx, http.MethodPost, instancePath(name), map[string]string{"name": newName}, nil)
+	return err
+}
+
+// DeleteInstance deletes an instance, stopping it first if force is set
+func (c *Client) DeleteInstance(ctx context.Context, name string, force bool) error {
+	if force {
+		instance, err := c.Instance(ctx, name)
+		if err != nil {
+			return err
+		}
+		if instance.Running() {
+			if err := c.StopInstance(ctx, name, true); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := c.async(ctx, http.MethodDelete, instancePath(name), nil, nil)
+	return err
+}
+
+// instanceStatePut changes the running state of an instance
+type instanceStatePut struct {
+	Action  string `json:"action"`
+	Timeout int    `json:"timeout"`
+	Force   bool   `json:"force"`
+}
+
+// setState performs a state action and waits for it
+func (c *Client) setState(ctx context.Context, name, action string, force bool) error {
+	_, err := c.async(ctx, http.MethodPut, instancePath(name, "state"), instanceStatePut{
+		Action:  action,
+		Timeout: 30,
+		Force:   force,
+	}, nil)
+	return err
+}
+
+// StartInstance starts an instance
+func (c *Client) StartInstance(ctx context.Context, name string) error {
+	return c.setState(ctx, name, "start", false)
+}
+
+// StopInstance stops an instance; force kills it instead of a clean shutdown
+func (c *Client) StopInstance(ctx context.Context, name string, force bool) error {
+	return c.setState(ctx, name, "stop", force)
+}
+
+// RestartInstance restarts an instance
+func (c *Client) RestartInstance(ctx context.Context, name string, force bool) error {
+	return c.setState(ctx, name, "restart", force)
+}
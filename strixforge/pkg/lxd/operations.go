@@ -0,0 +1,130 @@
+package lxd
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Operation status codes
+const (
+	StatusRunning   = 103
+	StatusPending   = 105
+	StatusSuccess   = 200
+	StatusFailure   = 400
+	StatusCancelled = 401
+)
+
+// operationPollInterval is how often progress is read while waiting
+var operationPollInterval = 500 * time.Millisecond
+
+// Operation is a background task such as creating an instance
+type Operation struct {
+	ID          string                 `json:"id"`
+	Class       string                 `json:"class"`
+	Description string                 `json:"description"`
+	Status      string                 `json:"status"`
+	StatusCode  int                    `json:"status_code"`
+	Resources   map[string][]string    `json:"resources"`
+	Metadata    map[string]interface{} `json:"metadata"`
+	Err         string                 `json:"err"`
+	MayCancel   bool                   `json:"may_cancel"`
+}
+
+// Done reports whether the operation has finished
+func (o *Operation) Done() bool {
+	return o.StatusCode >= StatusSuccess
+}
+
+// Progress is reported while an operation runs
+type Progress struct {
+	Stage   string // metadata key, e.g. "download_progress"
+	Percent int    // -1 if unknown
+	Text    string // LXD's own description, e.g. "rootfs: 45% (12.3MB/s)"
+}
+
+// ProgressFunc receives operation progress updates
+type ProgressFunc func(Progress)
+
+var percentRe = regexp.MustCompile(`(\d+)%`)
+
+// progress extracts the progress entries LXD puts in operation metadata
+func (o *Operation) progress() []Progress {
+	var out []Progress
+	for key, value := range o.Metadata {
+		text, ok := value.(string)
+		if !ok || !strings.HasSuffix(key, "_progress") {
+			continue
+		}
+		p := Progress{Stage: key, Percent: -1, Text: text}
+		if m := percentRe.FindStringSubmatch(text); m != nil {
+			p.Percent, _ = strconv.Atoi(m[1])
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// Operation returns the current state of an operation
+func (c *Client) Operation(ctx context.Context, id string) (*Operation, error) {
+	var op Operation
+	if err := c.get(ctx, operationPath(id), &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// WaitOperation polls an operation until it finishes, reporting progress.
+// A failed or cancelled operation is returned as *Error along with its state.
+func (c *Client) WaitOperation(ctx context.Context, id string, progress ProgressFunc) (*Operation, error) {
+	ticker := time.NewTicker(operationPollInterval)
+	defer ticker.Stop()
+
+	last := make(map[string]string)
+	for {
+		op, err := c.Operation(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		if progress != nil {
+			for _, p := range op.progress() {
+				if last[p.Stage] != p.Text {
+					last[p.Stage] = p.Text
+					progress(p)
+				}
+			}
+		}
+
+		if op.Done() {
+			if op.StatusCode != StatusSuccess {
+				msg := op.Err
+				if msg == "" {
+					msg = op.Status
+				}
+				return op, &Error{StatusCode: op.StatusCode, Message: msg}
+			}
+			return op, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if op.MayCancel {
+				// Best effort; the caller is already giving up
+				_ = c.sync(context.Background(), "DELETE", operationPath(id), nil)
+			}
+			return op, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// operationPath accepts either an operation ID or the URL LXD returns
+func operationPath(id string) string {
+	if strings.HasPrefix(id, "/") {
+		return id
+	}
+	return "/1.0/operations/" + id
+}
@@ -0,0 +1,45 @@
+package lxd
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+func profilePath(name string) string {
+	return "/1.0/profiles/" + url.PathEscape(name)
+}
+
+// Profiles returns all profiles
+func (c *Client) Profiles(ctx context.Context) ([]Profile, error) {
+	var profiles []Profile
+	if err := c.get(ctx, "/1.0/profiles?recursion=1", &profiles); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// Profile returns a single profile
+func (c *Client) Profile(ctx context.Context, name string) (*Profile, error) {
+	var profile Profile
+	if err := c.get(ctx, profilePath(name), &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// CreateProfile creates a profile
+func (c *Client) CreateProfile(ctx context.Context, profile Profile) error {
+	profile.UsedBy = nil
+	return c.sync(ctx, http.MethodPost, "/1.0/profiles", profile)
+}
+
+// UpdateProfile replaces a profile's config and devices
+func (c *Client) UpdateProfile(ctx context.Context, name string, put ProfilePut) error {
+	return c.sync(ctx, http.MethodPut, profilePath(name), put)
+}
+
+// DeleteProfile removes a profile that no instance uses
+func (c *Client) DeleteProfile(ctx context.Context, name string) error {
+	return c.sync(ctx, http.MethodDelete, profilePath(name), nil)
+}
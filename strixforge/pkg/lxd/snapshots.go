@@ -0,0 +1,34 @@
+package lxd
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Snapshots returns an instance's snapshots, oldest first
+func (c *Client) Snapshots(ctx context.Context, instance string) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	if err := c.get(ctx, instancePath(instance, "snapshots")+"?recursion=1", &snapshots); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// CreateSnapshot snapshots an instance
+func (c *Client) CreateSnapshot(ctx context.Context, instance string, req SnapshotsPost) error {
+	_, err := c.async(ctx, http.MethodPost, instancePath(instance, "snapshots"), req, nil)
+	return err
+}
+
+// RestoreSnapshot rolls an instance back to a snapshot
+func (c *Client) RestoreSnapshot(ctx context.Context, instance, snapshot string) error {
+	_, err := c.async(ctx, http.MethodPut, instancePath(instance), map[string]string{"restore": snapshot}, nil)
+	return err
+}
+
+// DeleteSnapshot removes a snapshot
+func (c *Client) DeleteSnapshot(ctx context.Context, instance, snapshot string) error {
+	_, err := c.async(ctx, http.MethodDelete, instancePath(instance, "snapshots", url.PathEscape(snapshot)), nil, nil)
+	return err
+}
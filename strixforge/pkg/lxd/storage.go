@@ -0,0 +1,78 @@
+package lxd
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// StoragePool is a storage pool and the driver behind it
+type StoragePool struct {
+	Name        string            `json:"name"`
+	Driver      string            `json:"driver"`
+	Description string            `json:"description"`
+	Status      string            `json:"status"`
+	Config      map[string]string `json:"config"`
+}
+
+// StoragePool returns a storage pool
+func (c *Client) StoragePool(ctx context.Context, name string) (*StoragePool, error) {
+	var pool StoragePool
+	if err := c.get(ctx, "/1.0/storage-pools/"+url.PathEscape(name), &pool); err != nil {
+		return nil, err
+	}
+	return &pool, nil
+}
+
+// OptimizedBackups reports whether the pool's driver has a native backup
+// format
+func (p *StoragePool) OptimizedBackups() bool {
+	return p.Driver == "zfs" || p.Driver == "btrfs"
+}
+
+func customVolumePath(pool, name string) string {
+	return "/1.0/storage-pools/" + url.PathEscape(pool) + "/volumes/custom/" + url.PathEscape(name)
+}
+
+// StorageVolume is a custom storage volume that can be attached to several
+// instances as a disk device
+type StorageVolume struct {
+	Name        string            `json:"name"`
+	Type        string            `json:"type"`
+	ContentType string            `json:"content_type,omitempty"`
+	Description string            `json:"description"`
+	Config      map[string]string `json:"config"`
+	UsedBy      []string          `json:"used_by,omitempty"`
+}
+
+// StorageVolume returns a custom volume
+func (c *Client) StorageVolume(ctx context.Context, pool, name string) (*StorageVolume, error) {
+	var vol StorageVolume
+	if err := c.get(ctx, customVolumePath(pool, name), &vol); err != nil {
+		return nil, err
+	}
+	return &vol, nil
+}
+
+// CreateStorageVolume creates a custom filesystem volume
+func (c *Client) CreateStorageVolume(ctx context.Context, pool string, vol StorageVolume) error {
+	vol.Type = "custom"
+	if vol.ContentType == "" {
+		vol.ContentType = "filesystem"
+	}
+	vol.UsedBy = nil
+	return c.sync(ctx, http.MethodPost, "/1.0/storage-pools/"+url.PathEscape(pool)+"/volumes/custom", vol)
+}
+
+// UpdateStorageVolume replaces a custom volume's description and config
+func (c *Client) UpdateStorageVolume(ctx context.Context, pool, name string, vol StorageVolume) error {
+	return c.sync(ctx, http.MethodPut, customVolumePath(pool, name), map[string]interface{}{
+		"description": vol.Description,
+		"config":      vol.Config,
+	})
+}
+
+// DeleteStorageVolume removes a custom volume that no instance uses
+func (c *Client) DeleteStorageVolume(ctx context.Context, pool, name string) error {
+	return c.sync(ctx, http.MethodDelete, customVolumePath(pool, name), nil)
+}
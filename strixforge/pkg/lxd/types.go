@@ -0,0 +1,175 @@
+package lxd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Devices maps device names to their configuration
+type Devices map[string]map[string]string
+
+// Instance is a container or virtual machine
+type Instance struct {
+	Name            string            `json:"name"`
+	Type            string            `json:"type"` // "container" or "virtual-machine"
+	Description     string            `json:"description"`
+	Status          string            `json:"status"` // "Running", "Stopped", "Frozen", ...
+	StatusCode      int               `json:"status_code"`
+	Architecture    string            `json:"architecture"`
+	Config          map[string]string `json:"config"`
+	Devices         Devices           `json:"devices"`
+	Profiles        []string          `json:"profiles"`
+	Ephemeral       bool              `json:"ephemeral"`
+	Stateful        bool              `json:"stateful"`
+	CreatedAt       time.Time         `json:"created_at"`
+	LastUsedAt      time.Time         `json:"last_used_at"`
+	ExpandedConfig  map[string]string `json:"expanded_config"`
+	ExpandedDevices Devices           `json:"expanded_devices"`
+}
+
+// Running reports whether the instance is running
+func (i *Instance) Running() bool {
+	return i.Status == "Running"
+}
+
+// InstancePut is the writable part of an instance
+type InstancePut struct {
+	Description string            `json:"description"`
+	Config      map[string]string `json:"config"`
+	Devices     Devices           `json:"devices"`
+	Profiles    []string          `json:"profiles"`
+	Ephemeral   bool              `json:"ephemeral"`
+}
+
+// Writable returns the instance fields that can be updated
+func (i *Instance) Writable() InstancePut {
+	return InstancePut{
+		Description: i.Description,
+		Config:      i.Config,
+		Devices:     i.Devices,
+		Profiles:    i.Profiles,
+		Ephemeral:   i.Ephemeral,
+	}
+}
+
+// InstanceSource describes what a new instance is created from
+type InstanceSource struct {
+	Type        string `json:"type"` // "image", "copy", "none"
+	Alias       string `json:"alias,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Server      string `json:"server,omitempty"`
+	Protocol    string `json:"protocol,omitempty"`
+	Mode        string `json:"mode,omitempty"`
+	Source      string `json:"source,omitempty"` // instance or snapshot to copy
+
+	// InstanceOnly copies the instance without its snapshots
+	InstanceOnly bool `json:"instance_only,omitempty"`
+}
+
+// InstancesPost creates an instance
+type InstancesPost struct {
+	Name        string            `json:"name"`
+	Type        string            `json:"type,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Source      InstanceSource    `json:"source"`
+	Config      map[string]string `json:"config,omitempty"`
+	Devices     Devices           `json:"devices,omitempty"`
+	Profiles    []string          `json:"profiles,omitempty"`
+}
+
+// InstanceState is the runtime state of an instance
+type InstanceState struct {
+	Status     string                  `json:"status"`
+	StatusCode int                     `json:"status_code"`
+	Pid        int64                   `json:"pid"`
+	Processes  int64                   `json:"processes"`
+	Network    map[string]NetworkState `json:"network"`
+	Memory     struct {
+		Usage     int64 `json:"usage"`
+		UsagePeak int64 `json:"usage_peak"`
+	} `json:"memory"`
+	CPU struct {
+		Usage int64 `json:"usage"` // nanoseconds
+	} `json:"cpu"`
+	Disk map[string]struct {
+		Usage int64 `json:"usage"`
+	} `json:"disk"`
+}
+
+// NetworkState is one interface inside an instance
+type NetworkState struct {
+	Addresses []struct {
+		Family  string `json:"family"`
+		Address string `json:"address"`
+		Scope   string `json:"scope"`
+	} `json:"addresses"`
+	State string `json:"state"`
+}
+
+// Snapshot is a point-in-time copy of an instance
+type Snapshot struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	CreatedAt   time.Time         `json:"created_at"`
+	ExpiresAt   time.Time         `json:"expires_at"`
+	Stateful    bool              `json:"stateful"`
+	Config      map[string]string `json:"config"`
+	Profiles    []string          `json:"profiles"`
+}
+
+// SnapshotsPost creates a snapshot
+type SnapshotsPost struct {
+	Name      string     `json:"name"`
+	Stateful  bool       `json:"stateful"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Profile is a reusable set of config and devices
+type Profile struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Config      map[string]string `json:"config"`
+	Devices     Devices           `json:"devices"`
+	UsedBy      []string          `json:"used_by,omitempty"`
+}
+
+// ProfilePut is the writable part of a profile
+type ProfilePut struct {
+	Description string            `json:"description"`
+	Config      map[string]string `json:"config"`
+	Devices     Devices           `json:"devices"`
+}
+
+// ImageRemotes maps the remote prefixes accepted by ParseImage to
+// simplestreams servers, as configured by default in the lxc client
+var ImageRemotes = map[string]string{
+	"images":       "https://images.lxd.canonical.com",
+	"ubuntu":       "https://cloud-images.ubuntu.com/releases",
+	"ubuntu-daily": "https://cloud-images.ubuntu.com/daily",
+}
+
+// ParseImage turns an lxc-style image reference such as
+// "images:archlinux/current" into an instance source. References without a
+// remote, or with "local:", name a local image alias, including tagged
+// ones such as "strix/ai-lab:2026-10" (remote names never contain a slash).
+func ParseImage(ref string) (InstanceSource, error) {
+	remote, alias, found := strings.Cut(ref, ":")
+	if !found || strings.Contains(remote, "/") {
+		return InstanceSource{Type: "image", Alias: ref}, nil
+	}
+	if remote == "local" {
+		return InstanceSource{Type: "image", Alias: alias}, nil
+	}
+	server, ok := ImageRemotes[remote]
+	if !ok {
+		return InstanceSource{}, fmt.Errorf("unknown image remote %q in %s", remote, ref)
+	}
+	return InstanceSource{
+		Type:     "image",
+		Alias:    alias,
+		Server:   server,
+		Protocol: "simplestreams",
+		Mode:     "pull",
+	}, nil
+}
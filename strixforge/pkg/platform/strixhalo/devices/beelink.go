@@ -2,6 +2,7 @@ package devices
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/daveweinstein1/strixforge/pkg/core"
 	"github.com/daveweinstein1/strixforge/pkg/system"
@@ -45,7 +46,8 @@ func (d *BeelinkGTR9) buildQuirks() []core.Quirk {
 
 				var lastErr error
 				for _, loader := range loaders {
-					if err := loader.AddParam(ctx, "modprobe.blacklist=ice"); err != nil {
+					// Keeps modules the user already blacklisted
+					if err := loader.MergeListParam(ctx, "modprobe.blacklist", "ice"); err != nil {
 						lastErr = err
 					}
 				}
@@ -58,13 +60,19 @@ func (d *BeelinkGTR9) buildQuirks() []core.Quirk {
 			Type:        core.QuirkAuto,
 			Apply: func(ctx context.Context) error {
 				pacman := system.NewPacman()
-				// ryzenadj may be in AUR
-				if !pacman.IsInstalled(ctx, "ryzenadj") {
-					// Try AUR via yay
-					yay := system.NewYay("") // Will use current user
-					return yay.Install(ctx, "ryzenadj")
+				if pacman.IsInstalled(ctx, "ryzenadj") {
+					return nil
 				}
-				return nil
+				// ryzenadj may be in AUR; build it as the invoking user
+				username, err := system.TargetUser()
+				if err != nil {
+					return err
+				}
+				aur := system.DetectAURHelper(username)
+				if aur == nil {
+					return fmt.Errorf("ryzenadj needs an AUR helper but neither yay nor paru is installed")
+				}
+				return aur.Install(ctx, "ryzenadj")
 			},
 		},
 	}
@@ -3,18 +3,32 @@ package strixhalo
 import (
 	"context"
 
+	"github.com/daveweinstein1/strixforge/pkg/config"
 	"github.com/daveweinstein1/strixforge/pkg/core"
 	"github.com/daveweinstein1/strixforge/pkg/platform/strixhalo/stages"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+	"github.com/daveweinstein1/strixforge/pkg/workspace"
 )
 
 // Platform implements the Strix Halo installation platform
 type Platform struct {
 	device core.Device
+	config *config.Config
 }
 
-// New creates a new Strix Halo platform
+// New creates a new Strix Halo platform with the default configuration
 func New() *Platform {
-	return &Platform{}
+	return &Platform{config: config.Default()}
+}
+
+// SetConfig replaces the platform configuration
+func (p *Platform) SetConfig(cfg *config.Config) {
+	p.config = cfg
+}
+
+// Config returns the platform configuration
+func (p *Platform) Config() *config.Config {
+	return p.config
 }
 
 // Name returns the platform display name
@@ -34,17 +48,37 @@ func (p *Platform) Detect() (core.Device, error) {
 
 // Stages returns all installation stages in order
 func (p *Platform) Stages() []core.Stage {
+	runtime := p.Runtime()
 	return []core.Stage{
 		stages.NewKernelStage(p.device),
 		stages.NewGraphicsStage(),
 		stages.NewSystemStage(),
-		stages.NewLXDStage(),
+		p.runtimeStage(),
 		stages.NewThermalStage(),
 		stages.NewCleanupStage(),
-		stages.NewValidateStage(),
-		stages.NewAppsStage(),
-		stages.NewWorkspaceStage(),
+		stages.NewValidateStage(runtime),
+		stages.NewAppsStage(p.config.Apps),
+		stages.NewWorkspaceStage(p.config, runtime),
+	}
+}
+
+// Runtime returns the container runtime the config selects
+func (p *Platform) Runtime() system.ContainerRuntime {
+	runtime, err := workspace.NewRuntime(p.config.Runtime)
+	if err != nil {
+		// Load rejects unknown runtimes; only a hand-built config gets here
+		return workspace.NewLXDRuntime("")
+	}
+	return runtime
+}
+
+// runtimeStage sets up the container runtime: LXD with its profiles, or
+// podman or docker
+func (p *Platform) runtimeStage() core.Stage {
+	if p.config.OCI() {
+		return stages.NewOCIStage(p.config.Runtime)
 	}
+	return stages.NewLXDStage()
 }
 
 // Validate checks prerequisites (called before running stages)
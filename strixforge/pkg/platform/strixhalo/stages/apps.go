@@ -3,85 +3,207 @@ package stages
 import (
 	"context"
 	"fmt"
-	"os"
-	"os/user"
+	"strings"
 
+	"github.com/daveweinstein1/strixforge/pkg/config"
 	"github.com/daveweinstein1/strixforge/pkg/core"
 	"github.com/daveweinstein1/strixforge/pkg/system"
 )
 
+// desktopApp is an app catalog entry. An empty package name means the
+// source does not carry the app.
+type desktopApp struct {
+	ID      string
+	Name    string
+	Pacman  string
+	AUR     string
+	Flatpak string // Flathub application ID
+	Ask     bool   // confirm before installing
+}
+
+// pkg returns the package name for a source
+func (a desktopApp) pkg(source string) string {
+	switch source {
+	case config.SourcePacman:
+		return a.Pacman
+	case config.SourceAUR:
+		return a.AUR
+	case config.SourceFlatpak:
+		return a.Flatpak
+	}
+	return ""
+}
+
+// desktopApps is the catalog, in install order
+var desktopApps = []desktopApp{
+	{ID: "firefox", Name: "Firefox", Pacman: "firefox", Flatpak: "org.mozilla.firefox"},
+	{ID: "vlc", Name: "VLC", Pacman: "vlc", Flatpak: "org.videolan.VLC"},
+	{ID: "signal", Name: "Signal", Pacman: "signal-desktop", Flatpak: "org.signal.Signal"},
+	{ID: "chrome", Name: "Google Chrome", AUR: "google-chrome", Flatpak: "com.google.Chrome", Ask: true},
+	{ID: "ungoogled-chromium", Name: "Ungoogled Chromium", AUR: "ungoogled-chromium-bin", Flatpak: "io.github.ungoogled_software.ungoogled_chromium", Ask: true},
+	{ID: "helium", Name: "Helium Browser", AUR: "helium", Ask: true},
+	{ID: "onlyoffice", Name: "OnlyOffice", AUR: "onlyoffice-bin", Flatpak: "org.onlyoffice.desktopeditors", Ask: true},
+}
+
 // AppsStage installs desktop applications
-type AppsStage struct{}
+type AppsStage struct {
+	policy config.AppsConfig
+}
 
-func NewAppsStage() *AppsStage { return &AppsStage{} }
+func NewAppsStage(policy config.AppsConfig) *AppsStage { return &AppsStage{policy: policy} }
 
 func (s *AppsStage) ID() string          { return "apps" }
 func (s *AppsStage) Name() string        { return "Desktop Software" }
 func (s *AppsStage) Description() string { return "Install browsers, office suite, and utilities" }
 func (s *AppsStage) Optional() bool      { return true }
 
+// chooseSource picks where an app comes from: a pinned source if usable,
+// otherwise the first preferred source that carries it. Returns "" if none.
+func (s *AppsStage) chooseSource(app desktopApp, usable map[string]bool) string {
+	if pinned, ok := s.policy.Sources[app.ID]; ok && usable[pinned] && app.pkg(pinned) != "" {
+		return pinned
+	}
+	for _, source := range s.policy.Prefer {
+		if usable[source] && app.pkg(source) != "" {
+			return source
+		}
+	}
+	return ""
+}
+
+// plan assigns a source to every catalog app
+func (s *AppsStage) plan(usable map[string]bool) map[string]string {
+	sources := make(map[string]string, len(desktopApps))
+	for _, app := range desktopApps {
+		sources[app.ID] = s.chooseSource(app, usable)
+	}
+	return sources
+}
+
 func (s *AppsStage) Run(ctx context.Context, ui core.UI) error {
-	pacman := system.NewPacman()
+	pacman := newPacman(ui)
 
-	// Get current user for AUR operations
-	username := os.Getenv("SUDO_USER")
-	if username == "" {
-		if u, err := user.Current(); err == nil {
-			username = u.Username
-		}
+	// AUR builds must run as the invoking user, never as root
+	username, err := system.TargetUser()
+	if err != nil {
+		ui.Log(core.LogWarn, fmt.Sprintf("AUR packages will be skipped: %v", err))
 	}
-	yay := system.NewYay(username)
 
-	// Step 1: Install yay (AUR helper)
+	// Step 1: Set up an AUR helper (prefer whatever is already installed)
 	ui.Progress(5, "Setting up AUR helper...")
-	if !pacman.IsInstalled(ctx, "yay") {
-		if err := pacman.Install(ctx, "yay"); err != nil {
-			ui.Log(core.LogWarn, fmt.Sprintf("Could not install yay: %v", err))
+	var aur system.AURHelper
+	if username != "" {
+		aur = system.DetectAURHelper(username)
+		if aur == nil {
+			if err := pacman.Install(ctx, "yay"); err != nil {
+				ui.Log(core.LogWarn, fmt.Sprintf("Could not install yay: %v", err))
+			} else {
+				ui.Log(core.LogInfo, "✓ yay installed")
+				aur = system.NewYay(username)
+			}
 		} else {
-			ui.Log(core.LogInfo, "✓ yay installed")
+			ui.Log(core.LogInfo, fmt.Sprintf("✓ Using AUR helper: %s", aur.Name()))
 		}
 	}
+	if aur == nil {
+		ui.Log(core.LogWarn, "No AUR helper available, AUR packages will be skipped")
+	}
 
-	// Step 2: Official repo packages
-	ui.Progress(15, "Installing browsers and utilities...")
-	officialPackages := []string{
-		"firefox",
-		"vlc",
-		"signal-desktop",
+	usable := map[string]bool{
+		config.SourcePacman:  true,
+		config.SourceAUR:     aur != nil,
+		config.SourceFlatpak: !pacman.Offline(),
 	}
-	if err := pacman.Install(ctx, officialPackages...); err != nil {
-		ui.Log(core.LogWarn, fmt.Sprintf("Some official packages failed: %v", err))
+	sources := s.plan(usable)
+
+	// Step 2: Set up Flatpak only if the policy sends an app there
+	flatpak := system.NewFlatpak()
+	if containsSource(sources, config.SourceFlatpak) {
+		ui.Progress(10, "Setting up Flatpak...")
+		if err := s.setupFlatpak(ctx, pacman, flatpak); err != nil {
+			ui.Log(core.LogWarn, fmt.Sprintf("Flatpak unavailable, using other sources: %v", err))
+			usable[config.SourceFlatpak] = false
+			sources = s.plan(usable)
+		} else {
+			ui.Log(core.LogInfo, "✓ Flatpak ready with Flathub remote")
+		}
 	}
-	ui.Log(core.LogInfo, "✓ Firefox, VLC, Signal installed")
 
-	// Step 3: AUR packages (optional, ask user)
-	ui.Progress(40, "AUR packages...")
+	// Step 3: Pre-flight AUR packages, falling back to other sources for
+	// apps that are missing or renamed
+	nordPackages := []string{"nordvpn-bin", "nordvpn-plasmoid"}
+	unavailable := make(map[string]bool)
+	if aur != nil {
+		ui.Progress(20, "Checking AUR package availability...")
+		wanted := append([]string{}, nordPackages...)
+		for _, app := range desktopApps {
+			if sources[app.ID] == config.SourceAUR {
+				wanted = append(wanted, app.AUR)
+			}
+		}
+
+		preflight, err := system.CheckAURPackages(ctx, aur, wanted...)
+		if err != nil {
+			ui.Log(core.LogWarn, fmt.Sprintf("AUR pre-flight check failed: %v", err))
+		} else {
+			for _, pkg := range preflight.Missing {
+				unavailable[pkg] = true
+				if suggestions := preflight.Suggestions[pkg]; len(suggestions) > 0 {
+					ui.Log(core.LogWarn, fmt.Sprintf("✗ AUR package '%s' not found (renamed? try: %s)", pkg, strings.Join(suggestions, ", ")))
+				} else {
+					ui.Log(core.LogWarn, fmt.Sprintf("✗ AUR package '%s' not found", pkg))
+				}
+			}
+		}
 
-	aurPackages := map[string]string{
-		"google-chrome":          "Google Chrome",
-		"ungoogled-chromium-bin": "Ungoogled Chromium",
-		"helium":                 "Helium Browser",
-		"onlyoffice-bin":         "OnlyOffice",
+		noAUR := map[string]bool{
+			config.SourcePacman:  usable[config.SourcePacman],
+			config.SourceFlatpak: usable[config.SourceFlatpak],
+		}
+		for _, app := range desktopApps {
+			if sources[app.ID] == config.SourceAUR && unavailable[app.AUR] {
+				sources[app.ID] = s.chooseSource(app, noAUR)
+			}
+		}
 	}
 
-	for pkg, name := range aurPackages {
-		if ui.Confirm(fmt.Sprintf("Install %s?", name), false) {
-			ui.Log(core.LogInfo, fmt.Sprintf("Installing %s...", name))
-			if err := yay.Install(ctx, pkg); err != nil {
-				ui.Log(core.LogWarn, fmt.Sprintf("Failed to install %s: %v", name, err))
-			} else {
-				ui.Log(core.LogInfo, fmt.Sprintf("✓ %s installed", name))
-			}
+	// Step 4: Install apps from their chosen sources
+	ui.Progress(30, "Installing desktop apps...")
+	for i, app := range desktopApps {
+		ui.Progress(30+i*40/len(desktopApps), fmt.Sprintf("Installing %s...", app.Name))
+
+		source := sources[app.ID]
+		if source == "" {
+			ui.Log(core.LogWarn, fmt.Sprintf("Skipping %s: no usable source", app.Name))
+			continue
+		}
+		if app.Ask && !ui.Confirm(fmt.Sprintf("Install %s (%s)?", app.Name, source), false) {
+			continue
 		}
+
+		var err error
+		switch source {
+		case config.SourcePacman:
+			err = pacman.Install(ctx, app.Pacman)
+		case config.SourceAUR:
+			err = aur.Install(ctx, app.AUR)
+		case config.SourceFlatpak:
+			err = flatpak.Install(ctx, system.FlathubRemote, app.Flatpak)
+		}
+		if err != nil {
+			ui.Log(core.LogWarn, fmt.Sprintf("Failed to install %s: %v", app.Name, err))
+			continue
+		}
+		ui.Log(core.LogInfo, fmt.Sprintf("✓ %s installed (%s)", app.Name, source))
 	}
 
-	// Step 4: Special Suites (NordVPN)
-	if ui.Confirm("Install NordVPN Suite (CLI + KDE Tray Icon)?", false) {
-		ui.Progress(60, "Installing NordVPN Suite...")
+	// Step 5: Special Suites (NordVPN)
+	nordAvailable := aur != nil && !unavailable[nordPackages[0]] && !unavailable[nordPackages[1]]
+	if nordAvailable && ui.Confirm("Install NordVPN Suite (CLI + KDE Tray Icon)?", false) {
+		ui.Progress(75, "Installing NordVPN Suite...")
 
 		// 1. Install packages
-		nordPackages := []string{"nordvpn-bin", "nordvpn-plasmoid"}
-		if err := yay.Install(ctx, nordPackages...); err != nil {
+		if err := aur.Install(ctx, nordPackages...); err != nil {
 			ui.Log(core.LogWarn, fmt.Sprintf("Failed to install NordVPN packages: %v", err))
 		} else {
 			ui.Log(core.LogInfo, "✓ NordVPN packages installed")
@@ -96,9 +218,10 @@ func (s *AppsStage) Run(ctx context.Context, ui core.UI) error {
 
 			// 3. Enable Service
 			// systemctl enable --now nordvpnd
-			sysd := system.NewSystemd()
-			if err := sysd.EnableAndStart(ctx, "nordvpnd"); err != nil {
+			sysd := system.NewServiceManager()
+			if err := sysd.EnableAndStart(ctx, "nordvpnd.service"); err != nil {
 				ui.Log(core.LogWarn, fmt.Sprintf("Failed to enable nordvpnd: %v", err))
+				logDiagnostics(ui, err)
 			} else {
 				ui.Log(core.LogInfo, "✓ NordVPN service enabled and started")
 			}
@@ -109,6 +232,29 @@ func (s *AppsStage) Run(ctx context.Context, ui core.UI) error {
 	return nil
 }
 
+// setupFlatpak installs flatpak if needed and adds the Flathub remote
+func (s *AppsStage) setupFlatpak(ctx context.Context, pacman *system.Pacman, flatpak *system.Flatpak) error {
+	if !flatpak.Available() {
+		if err := pacman.Install(ctx, "flatpak"); err != nil {
+			return err
+		}
+	}
+	if flatpak.HasRemote(ctx, system.FlathubRemote) {
+		return nil
+	}
+	return flatpak.AddRemote(ctx, system.FlathubRemote, system.FlathubURL)
+}
+
 func (s *AppsStage) Rollback(ctx context.Context) error {
 	return nil
 }
+
+// containsSource checks if any app in a plan uses source
+func containsSource(sources map[string]string, source string) bool {
+	for _, s := range sources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
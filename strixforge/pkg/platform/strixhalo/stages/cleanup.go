@@ -4,7 +4,6 @@ import (
 	"context"
 
 	"github.com/daveweinstein1/strixforge/pkg/core"
-	"github.com/daveweinstein1/strixforge/pkg/system"
 )
 
 // CleanupStage removes orphaned packages and cleans cache
@@ -18,7 +17,7 @@ func (s *CleanupStage) Description() string { return "Remove orphaned packages,
 func (s *CleanupStage) Optional() bool      { return true }
 
 func (s *CleanupStage) Run(ctx context.Context, ui core.UI) error {
-	pacman := system.NewPacman()
+	pacman := newPacman(ui)
 
 	// Step 1: Remove orphaned packages
 	ui.Progress(30, "Removing orphaned packages...")
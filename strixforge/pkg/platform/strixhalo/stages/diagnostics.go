@@ -0,0 +1,45 @@
+package stages
+
+import (
+	"errors"
+
+	"github.com/daveweinstein1/strixforge/pkg/core"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+)
+
+// journalLogs converts journal entries to stage log entries
+func journalLogs(entries []system.JournalEntry) []core.LogEntry {
+	logs := make([]core.LogEntry, 0, len(entries))
+	for _, e := range entries {
+		level := core.LogInfo
+		if e.Priority <= system.PriorityErr {
+			level = core.LogError
+		} else if e.Priority == system.PriorityWarning {
+			level = core.LogWarn
+		}
+		logs = append(logs, core.LogEntry{Time: e.Time, Level: level, Message: e.String()})
+	}
+	return logs
+}
+
+// withDiagnostics attaches the journal of a failed unit to err so that the
+// engine reports it alongside the failure
+func withDiagnostics(err error) error {
+	var unitErr *system.UnitError
+	if !errors.As(err, &unitErr) || len(unitErr.Journal) == 0 {
+		return err
+	}
+	return &core.DiagnosticError{Err: err, Logs: journalLogs(unitErr.Journal)}
+}
+
+// logDiagnostics shows the journal of a failed unit for errors that do not
+// fail the stage
+func logDiagnostics(ui core.UI, err error) {
+	var unitErr *system.UnitError
+	if !errors.As(err, &unitErr) {
+		return
+	}
+	for _, entry := range journalLogs(unitErr.Journal) {
+		ui.Log(entry.Level, "  "+entry.Message)
+	}
+}
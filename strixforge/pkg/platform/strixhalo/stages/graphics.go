@@ -22,24 +22,45 @@ func (s *GraphicsStage) Description() string {
 }
 func (s *GraphicsStage) Optional() bool { return false }
 
-func (s *GraphicsStage) Run(ctx context.Context, ui core.UI) error {
-	pacman := system.NewPacman()
+// graphicsPackages make up the Mesa/Vulkan/LLVM stack
+var graphicsPackages = []string{
+	"mesa", "lib32-mesa", "mesa-utils",
+	"vulkan-radeon", "lib32-vulkan-radeon", "vulkan-tools",
+	"linux-firmware",
+	"llvm", "lib32-llvm",
+}
 
-	// Step 1: Install graphics packages
-	ui.Progress(10, "Installing graphics packages...")
-	packages := []string{
-		"mesa", "lib32-mesa", "mesa-utils",
-		"vulkan-radeon", "lib32-vulkan-radeon", "vulkan-tools",
-		"linux-firmware",
-		"llvm", "lib32-llvm",
+// Packages returns the host packages installed by this stage
+func (s *GraphicsStage) Packages() []string { return graphicsPackages }
+
+func (s *GraphicsStage) Run(ctx context.Context, ui core.UI) error {
+	pacman := newPacman(ui)
+
+	// Step 1: lib32-* packages live in multilib, which stock Arch ships disabled
+	ui.Progress(5, "Checking multilib repository...")
+	if !pacman.Offline() {
+		changed, err := editPacmanConf(ctx, ui, func(conf *system.PacmanConf) bool {
+			return conf.EnsureMultilib()
+		})
+		if err != nil {
+			return fmt.Errorf("failed to enable multilib: %v", err)
+		}
+		if changed {
+			if err := pacman.SyncDatabases(ctx); err != nil {
+				return err
+			}
+			ui.Log(core.LogInfo, "✓ multilib repository enabled")
+		}
 	}
 
-	if err := pacman.Install(ctx, packages...); err != nil {
+	// Step 2: Install graphics packages
+	ui.Progress(10, "Installing graphics packages...")
+	if err := pacman.Install(ctx, graphicsPackages...); err != nil {
 		return fmt.Errorf("failed to install graphics packages: %v", err)
 	}
 	ui.Log(core.LogInfo, "✓ Graphics packages installed")
 
-	// Step 2: Verify Mesa version
+	// Step 3: Verify Mesa version
 	ui.Progress(50, "Verifying Mesa version...")
 	mesaVersion, err := pacman.GetVersion(ctx, "mesa")
 	if err != nil {
@@ -54,7 +75,7 @@ func (s *GraphicsStage) Run(ctx context.Context, ui core.UI) error {
 	}
 	ui.Log(core.LogInfo, "✓ Mesa version meets requirements")
 
-	// Step 3: Verify LLVM version
+	// Step 4: Verify LLVM version
 	ui.Progress(70, "Verifying LLVM version...")
 	llvmVersion, err := pacman.GetVersion(ctx, "llvm")
 	if err != nil {
@@ -69,7 +90,7 @@ func (s *GraphicsStage) Run(ctx context.Context, ui core.UI) error {
 		ui.Log(core.LogInfo, "✓ LLVM version meets requirements")
 	}
 
-	// Step 4: Quick Vulkan check
+	// Step 5: Quick Vulkan check
 	ui.Progress(90, "Checking Vulkan...")
 	result, err := system.Exec(ctx, "vulkaninfo", "--summary")
 	if err != nil {
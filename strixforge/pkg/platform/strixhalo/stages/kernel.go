@@ -10,7 +10,7 @@ import (
 	"strings"
 
 	"github.com/daveweinstein1/strixforge/pkg/core"
-
+	"github.com/daveweinstein1/strixforge/pkg/system"
 	"github.com/daveweinstein1/strixforge/pkg/system/bootloader"
 )
 
@@ -68,13 +68,11 @@ func (s *KernelStage) Run(ctx context.Context, ui core.UI) error {
 			// Add Parameters
 			ui.Progress(40, fmt.Sprintf("Adding kernel parameters to %s...", loader.Name()))
 
-			// IOMMU
-			if err := loader.AddParam(ctx, "iommu=pt"); err != nil {
-				ui.Log(core.LogWarn, fmt.Sprintf("Failed to add iommu=pt to %s: %v", loader.Name(), err))
-			}
-			// AMD P-State
-			if err := loader.AddParam(ctx, "amd_pstate=active"); err != nil {
-				ui.Log(core.LogWarn, fmt.Sprintf("Failed to add amd_pstate=active to %s: %v", loader.Name(), err))
+			// SetParam replaces conflicting values such as iommu=off
+			for _, param := range []string{"iommu=pt", "amd_pstate=active"} {
+				if err := loader.SetParam(ctx, param); err != nil {
+					ui.Log(core.LogWarn, fmt.Sprintf("Failed to set %s in %s: %v", param, loader.Name(), err))
+				}
 			}
 		}
 	} else {
@@ -105,10 +103,13 @@ func (s *KernelStage) Run(ctx context.Context, ui core.UI) error {
 			ui.Log(core.LogInfo, fmt.Sprintf("High memory system (%d GB) detected. Disabling ZRAM to prevent GTT conflicts.", ramGB))
 
 			// Disable ZRAM generator service
-			cmd := exec.CommandContext(ctx, "systemctl", "disable", "--now", "zram-generator@zram0.service")
-			if out, err := cmd.CombinedOutput(); err != nil {
-				// Don't fail if service doesn't exist, just log
-				ui.Log(core.LogWarn, fmt.Sprintf("Failed to disable ZRAM (might not be active): %v %s", err, string(out)))
+			services := system.NewServiceManager()
+			const zramUnit = "zram-generator@zram0.service"
+			if state, err := services.State(ctx, zramUnit); err == nil && !state.Exists() {
+				ui.Log(core.LogInfo, "ZRAM service not installed, nothing to disable")
+			} else if err := services.DisableAndStop(ctx, zramUnit); err != nil {
+				// Don't fail the stage, just log
+				ui.Log(core.LogWarn, fmt.Sprintf("Failed to disable ZRAM: %v", err))
 			} else {
 				ui.Log(core.LogInfo, "✓ ZRAM disabled")
 			}
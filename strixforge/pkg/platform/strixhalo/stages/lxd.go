@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"os/user"
+	"reflect"
 
 	"github.com/daveweinstein1/strixforge/pkg/core"
+	"github.com/daveweinstein1/strixforge/pkg/lxd"
 	"github.com/daveweinstein1/strixforge/pkg/system"
+	"github.com/daveweinstein1/strixforge/pkg/workspace"
 )
 
 // LXDStage installs and configures LXD with GPU passthrough
@@ -17,14 +20,17 @@ func NewLXDStage() *LXDStage { return &LXDStage{} }
 func (s *LXDStage) ID() string   { return "lxd" }
 func (s *LXDStage) Name() string { return "LXD Containerization" }
 func (s *LXDStage) Description() string {
-	return "Install LXD, configure GPU passthrough, enable nesting"
+	return "Install LXD and create GPU passthrough and nesting profiles"
 }
 func (s *LXDStage) Optional() bool { return false }
 
+// Packages returns the host packages installed by this stage
+func (s *LXDStage) Packages() []string { return []string{"lxd"} }
+
 func (s *LXDStage) Run(ctx context.Context, ui core.UI) error {
-	pacman := system.NewPacman()
-	systemd := system.NewSystemd()
-	lxd := system.NewLXD()
+	pacman := newPacman(ui)
+	systemd := system.NewServiceManager()
+	host := system.NewLXD()
 
 	// Get current user
 	currentUser, err := user.Current()
@@ -35,7 +41,7 @@ func (s *LXDStage) Run(ctx context.Context, ui core.UI) error {
 
 	// Step 1: Install LXD
 	ui.Progress(10, "Installing LXD...")
-	if err := pacman.Install(ctx, "lxd"); err != nil {
+	if err := pacman.Install(ctx, s.Packages()...); err != nil {
 		return fmt.Errorf("failed to install LXD: %v", err)
 	}
 	ui.Log(core.LogInfo, "✓ LXD installed")
@@ -43,14 +49,14 @@ func (s *LXDStage) Run(ctx context.Context, ui core.UI) error {
 	// Step 2: Enable and start LXD socket
 	ui.Progress(25, "Enabling LXD service...")
 	if err := systemd.EnableAndStart(ctx, "lxd.socket"); err != nil {
-		return fmt.Errorf("failed to enable LXD: %v", err)
+		return withDiagnostics(fmt.Errorf("failed to enable LXD: %w", err))
 	}
 	ui.Log(core.LogInfo, "✓ LXD service enabled")
 
 	// Step 3: Add user to lxd group
 	ui.Progress(40, "Configuring user permissions...")
-	if !lxd.IsUserInGroup(ctx, username) {
-		if err := lxd.AddUserToGroup(ctx, username); err != nil {
+	if !host.IsUserInGroup(ctx, username) {
+		if err := host.AddUserToGroup(ctx, username); err != nil {
 			return fmt.Errorf("failed to add user to lxd group: %v", err)
 		}
 		ui.Log(core.LogInfo, fmt.Sprintf("✓ Added %s to lxd group", username))
@@ -61,32 +67,73 @@ func (s *LXDStage) Run(ctx context.Context, ui core.UI) error {
 
 	// Step 4: Initialize LXD
 	ui.Progress(55, "Initializing LXD...")
-	if err := lxd.Init(ctx); err != nil {
+	if err := host.Init(ctx); err != nil {
 		ui.Log(core.LogWarn, fmt.Sprintf("LXD init warning: %v", err))
 		// Continue - may already be initialized
 	}
 	ui.Log(core.LogInfo, "✓ LXD initialized")
 
-	// Step 5: Add GPU device to default profile
-	ui.Progress(70, "Configuring GPU passthrough...")
-	if err := lxd.AddGPUDevice(ctx); err != nil {
-		ui.Log(core.LogWarn, fmt.Sprintf("GPU device config warning: %v", err))
-	} else {
-		ui.Log(core.LogInfo, "✓ GPU passthrough configured")
+	client, err := lxd.Connect("")
+	if err != nil {
+		return fmt.Errorf("cannot reach LXD: %v", err)
 	}
 
-	// Step 6: Enable nesting for Docker-in-LXD
-	ui.Progress(85, "Enabling container nesting...")
-	if err := lxd.EnableNesting(ctx); err != nil {
-		ui.Log(core.LogWarn, fmt.Sprintf("Nesting config warning: %v", err))
-	} else {
-		ui.Log(core.LogInfo, "✓ Container nesting enabled")
+	// Step 5: Create the GPU and nesting profiles containers opt into
+	ui.Progress(70, "Configuring container profiles...")
+	profiles, err := workspace.ManagedProfiles()
+	if err != nil {
+		return fmt.Errorf("failed to build LXD profiles: %v", err)
+	}
+	for _, profile := range profiles {
+		action, err := workspace.EnsureProfile(ctx, client, profile)
+		if err != nil {
+			return err
+		}
+		ui.Log(core.LogInfo, fmt.Sprintf("✓ Profile %s %s", profile.Name, action))
+	}
+
+	// Step 6: Undo GPU passthrough and nesting that older versions added
+	// to the default profile, which every container inherits
+	ui.Progress(85, "Cleaning up default profile...")
+	err = editProfile(ctx, client, "default", func(p *lxd.Profile) {
+		if reflect.DeepEqual(p.Devices["gpu"], legacyGPUDevice) {
+			delete(p.Devices, "gpu")
+			delete(p.Config, "security.nesting")
+			ui.Log(core.LogInfo, "✓ Moved GPU passthrough and nesting out of the default profile")
+		}
+	})
+	if err != nil {
+		ui.Log(core.LogWarn, fmt.Sprintf("Default profile cleanup warning: %v", err))
 	}
 
 	ui.Progress(100, "LXD setup complete")
 	return nil
 }
 
+// legacyGPUDevice is the device older versions added to the default profile
+var legacyGPUDevice = map[string]string{"type": "gpu", "gid": "110"}
+
+// editProfile applies edit to an LXD profile and saves it
+func editProfile(ctx context.Context, client *lxd.Client, name string, edit func(p *lxd.Profile)) error {
+	profile, err := client.Profile(ctx, name)
+	if err != nil {
+		return err
+	}
+	if profile.Config == nil {
+		profile.Config = make(map[string]string)
+	}
+	if profile.Devices == nil {
+		profile.Devices = make(lxd.Devices)
+	}
+
+	edit(profile)
+	return client.UpdateProfile(ctx, name, lxd.ProfilePut{
+		Description: profile.Description,
+		Config:      profile.Config,
+		Devices:     profile.Devices,
+	})
+}
+
 func (s *LXDStage) Rollback(ctx context.Context) error {
 	return nil
 }
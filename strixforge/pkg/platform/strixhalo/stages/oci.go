@@ -0,0 +1,98 @@
+package stages
+
+import (
+	"context"
+	"fmt"
+	"os/user"
+	"slices"
+	"strings"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/core"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+)
+
+// OCIStage installs podman or docker when the config picks one of them as
+// the container runtime instead of LXD
+type OCIStage struct {
+	runtime string
+}
+
+// NewOCIStage creates the setup stage for a podman or docker runtime
+func NewOCIStage(runtime string) *OCIStage {
+	return &OCIStage{runtime: runtime}
+}
+
+func (s *OCIStage) ID() string { return "oci" }
+func (s *OCIStage) Name() string {
+	if s.runtime == config.RuntimeDocker {
+		return "Docker Containerization"
+	}
+	return "Podman Containerization"
+}
+func (s *OCIStage) Description() string {
+	return fmt.Sprintf("Install %s and give the user GPU access in containers", s.runtime)
+}
+func (s *OCIStage) Optional() bool { return false }
+
+// Packages returns the host packages installed by this stage
+func (s *OCIStage) Packages() []string { return []string{s.runtime} }
+
+// groups the user joins: the GPU device groups, which rootless podman
+// passes into containers, and docker's socket group
+func (s *OCIStage) groups() []string {
+	groups := []string{"video", "render"}
+	if s.runtime == config.RuntimeDocker {
+		groups = append(groups, "docker")
+	}
+	return groups
+}
+
+func (s *OCIStage) Run(ctx context.Context, ui core.UI) error {
+	pacman := newPacman(ui)
+
+	ui.Progress(10, fmt.Sprintf("Installing %s...", s.runtime))
+	if err := pacman.Install(ctx, s.Packages()...); err != nil {
+		return fmt.Errorf("failed to install %s: %v", s.runtime, err)
+	}
+	ui.Log(core.LogInfo, fmt.Sprintf("✓ %s installed", s.runtime))
+
+	if s.runtime == config.RuntimeDocker {
+		ui.Progress(35, "Enabling Docker service...")
+		if err := system.NewServiceManager().EnableAndStart(ctx, "docker.socket"); err != nil {
+			return withDiagnostics(fmt.Errorf("failed to enable Docker: %w", err))
+		}
+		ui.Log(core.LogInfo, "✓ Docker service enabled")
+	}
+
+	ui.Progress(60, "Configuring user permissions...")
+	currentUser, err := user.Current()
+	if err != nil {
+		return fmt.Errorf("could not determine current user: %v", err)
+	}
+	result, err := system.Exec(ctx, "id", "-nG", currentUser.Username)
+	if err != nil {
+		return fmt.Errorf("failed to read groups of %s: %v", currentUser.Username, err)
+	}
+	member := strings.Fields(result.Stdout)
+	var missing []string
+	for _, group := range s.groups() {
+		if !slices.Contains(member, group) {
+			missing = append(missing, group)
+		}
+	}
+	if len(missing) > 0 {
+		if result, err := system.ExecSudo(ctx, "usermod", "-aG", strings.Join(missing, ","), currentUser.Username); err != nil {
+			return fmt.Errorf("failed to add %s to %s: %s\n%s", currentUser.Username, strings.Join(missing, ", "), err, result.Stderr)
+		}
+		ui.Log(core.LogInfo, fmt.Sprintf("✓ Added %s to %s", currentUser.Username, strings.Join(missing, ", ")))
+		ui.Log(core.LogWarn, "NOTE: Log out and back in for group changes to take effect")
+	}
+
+	ui.Progress(100, fmt.Sprintf("%s setup complete", s.runtime))
+	return nil
+}
+
+func (s *OCIStage) Rollback(ctx context.Context) error {
+	return nil
+}
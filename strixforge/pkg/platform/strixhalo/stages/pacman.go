@@ -0,0 +1,52 @@
+package stages
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/daveweinstein1/strixforge/pkg/core"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+)
+
+// newPacman returns a Pacman that reports database lock waits to the UI
+// and only removes a stale lock after the user explicitly agrees
+func newPacman(ui core.UI) *system.Pacman {
+	pacman := system.NewPacman()
+
+	pacman.OnLockWait(func(holders []system.LockHolder, waited, limit time.Duration) {
+		names := make([]string, len(holders))
+		for i, h := range holders {
+			names[i] = h.String()
+		}
+		ui.Log(core.LogWarn, fmt.Sprintf("Waiting for pacman lock held by %s (%v of %v)...",
+			strings.Join(names, ", "), waited.Round(time.Second), limit))
+	})
+
+	pacman.OnStaleLock(func(lockPath string) bool {
+		ui.Log(core.LogWarn, fmt.Sprintf("%s exists but no package manager is running", lockPath))
+		return ui.Confirm(fmt.Sprintf("Remove stale pacman lock %s?", lockPath), false)
+	})
+
+	return pacman
+}
+
+// editPacmanConf applies edit to /etc/pacman.conf and writes it back (with a
+// backup) only if edit reports a change. Returns whether the file changed.
+func editPacmanConf(ctx context.Context, ui core.UI, edit func(conf *system.PacmanConf) bool) (bool, error) {
+	conf, err := system.LoadPacmanConf(system.DefaultPacmanConfPath)
+	if err != nil {
+		return false, err
+	}
+	if !edit(conf) {
+		return false, nil
+	}
+
+	backup, err := system.WritePacmanConf(ctx, system.DefaultPacmanConfPath, conf)
+	if err != nil {
+		return false, err
+	}
+	ui.Log(core.LogInfo, fmt.Sprintf("Updated %s (backup: %s)", system.DefaultPacmanConfPath, backup))
+	return true, nil
+}
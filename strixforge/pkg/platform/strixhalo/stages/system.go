@@ -20,12 +20,30 @@ func (s *SystemStage) Description() string {
 }
 func (s *SystemStage) Optional() bool { return false }
 
+// essentialPackages are installed on every system
+var essentialPackages = []string{
+	"base-devel",
+	"git",
+	"wget",
+	"curl",
+	"vim",
+	"neovim",
+	"btop",
+	"neofetch",
+	"fastfetch",
+}
+
+// Packages returns the host packages installed by this stage
+func (s *SystemStage) Packages() []string { return essentialPackages }
+
 func (s *SystemStage) Run(ctx context.Context, ui core.UI) error {
-	pacman := system.NewPacman()
+	pacman := newPacman(ui)
 
 	// Step 1: Rate mirrors (optional, CachyOS specific)
 	ui.Progress(10, "Checking for mirror optimization...")
-	if system.CheckCommand("cachyos-rate-mirrors") {
+	if pacman.Offline() {
+		ui.Log(core.LogInfo, "Offline repository in use, skipping mirror ranking")
+	} else if system.CheckCommand("cachyos-rate-mirrors") {
 		ui.Log(core.LogInfo, "Running CachyOS mirror ranking...")
 		result, err := system.ExecSudo(ctx, "cachyos-rate-mirrors")
 		if err != nil {
@@ -44,28 +62,30 @@ func (s *SystemStage) Run(ctx context.Context, ui core.UI) error {
 		ui.Log(core.LogInfo, "Mirror ranking tools not found, skipping")
 	}
 
-	// Step 2: Full system update
+	// Step 2: Repositories and keyrings
+	if !pacman.Offline() {
+		ui.Progress(20, "Checking repositories...")
+		if err := s.setupCachyOSRepos(ctx, ui, pacman); err != nil {
+			ui.Log(core.LogWarn, fmt.Sprintf("CachyOS repository setup failed: %v", err))
+		}
+
+		if err := system.NewPacmanKey().RefreshKeyrings(ctx, pacman); err != nil {
+			ui.Log(core.LogWarn, fmt.Sprintf("Keyring refresh failed: %v", err))
+		} else {
+			ui.Log(core.LogInfo, "✓ Keyrings up to date")
+		}
+	}
+
+	// Step 3: Full system update
 	ui.Progress(30, "Updating system packages...")
 	if err := pacman.Update(ctx); err != nil {
 		return fmt.Errorf("system update failed: %v", err)
 	}
 	ui.Log(core.LogInfo, "✓ System updated")
 
-	// Step 3: Install essential packages
+	// Step 4: Install essential packages
 	ui.Progress(60, "Installing essential packages...")
-	essentials := []string{
-		"base-devel",
-		"git",
-		"wget",
-		"curl",
-		"vim",
-		"neovim",
-		"btop",
-		"neofetch",
-		"fastfetch",
-	}
-
-	if err := pacman.Install(ctx, essentials...); err != nil {
+	if err := pacman.Install(ctx, essentialPackages...); err != nil {
 		return fmt.Errorf("failed to install essentials: %v", err)
 	}
 	ui.Log(core.LogInfo, "✓ Essential packages installed")
@@ -74,6 +94,49 @@ func (s *SystemStage) Run(ctx context.Context, ui core.UI) error {
 	return nil
 }
 
+// setupCachyOSRepos offers to add the CachyOS repositories on systems that
+// do not have them yet, matching the CPU's microarchitecture level
+func (s *SystemStage) setupCachyOSRepos(ctx context.Context, ui core.UI, pacman *system.Pacman) error {
+	conf, err := system.LoadPacmanConf(system.DefaultPacmanConfPath)
+	if err != nil {
+		return err
+	}
+	if conf.HasCachyOSRepos() {
+		ui.Log(core.LogInfo, "CachyOS repositories already configured")
+		return nil
+	}
+	if !ui.Confirm("CachyOS repositories are not configured. Add them?", false) {
+		ui.Log(core.LogInfo, "Continuing with stock Arch repositories")
+		return nil
+	}
+
+	keys := system.NewPacmanKey()
+	if err := keys.Init(ctx); err != nil {
+		return err
+	}
+	if !keys.HasKey(ctx, system.CachyOSKeyID) {
+		ui.Log(core.LogInfo, "Importing CachyOS signing key...")
+		if err := keys.ImportKey(ctx, system.CachyOSKeyID, ""); err != nil {
+			return err
+		}
+	}
+
+	level := system.SupportedArchLevel(ctx)
+	ui.Log(core.LogInfo, fmt.Sprintf("CPU supports x86-64-v%d", level))
+	if _, err := editPacmanConf(ctx, ui, func(conf *system.PacmanConf) bool {
+		conf.AddCachyOSRepos(level)
+		return true
+	}); err != nil {
+		return err
+	}
+
+	if err := pacman.SyncDatabases(ctx); err != nil {
+		return err
+	}
+	ui.Log(core.LogInfo, "✓ CachyOS repositories added")
+	return nil
+}
+
 func (s *SystemStage) Rollback(ctx context.Context) error {
 	return nil
 }
@@ -20,17 +20,35 @@ func (s *ThermalStage) Description() string {
 }
 func (s *ThermalStage) Optional() bool { return true }
 
+// thermalPackages are the sensor and fan control tools
+var thermalPackages = []string{"lm_sensors", "fancontrol"}
+
+// Packages returns the host packages this stage installs
+func (s *ThermalStage) Packages() []string { return thermalPackages }
+
+// fancontrolDropIn skips fancontrol until pwmconfig has written its config,
+// and restarts it if hwmon devices were not ready at boot
+const fancontrolDropIn = `[Unit]
+ConditionPathExists={{.Config}}
+
+[Service]
+Restart=on-failure
+RestartSec=5
+`
+
+// Units returns the systemd files this stage manages
+func (s *ThermalStage) Units() []system.ManagedFile {
+	return []system.ManagedFile{
+		system.DropIn("fancontrol.service", "strixforge", fancontrolDropIn, map[string]string{"Config": "/etc/fancontrol"}),
+	}
+}
+
 func (s *ThermalStage) Run(ctx context.Context, ui core.UI) error {
-	pacman := system.NewPacman()
+	pacman := newPacman(ui)
 
 	// Step 1: Install thermal packages
 	ui.Progress(10, "Installing thermal monitoring packages...")
-	packages := []string{
-		"lm_sensors",
-		"fancontrol",
-	}
-
-	if err := pacman.Install(ctx, packages...); err != nil {
+	if err := pacman.Install(ctx, thermalPackages...); err != nil {
 		return fmt.Errorf("failed to install thermal packages: %v", err)
 	}
 	ui.Log(core.LogInfo, "✓ lm_sensors and fancontrol installed")
@@ -47,7 +65,9 @@ func (s *ThermalStage) Run(ctx context.Context, ui core.UI) error {
 
 	// Step 3: Load detected modules
 	ui.Progress(60, "Loading sensor modules...")
-	_, _ = system.ExecSudo(ctx, "systemctl", "restart", "systemd-modules-load")
+	if err := system.NewServiceManager().Restart(ctx, "systemd-modules-load.service"); err != nil {
+		ui.Log(core.LogWarn, fmt.Sprintf("Could not reload sensor modules: %v", err))
+	}
 
 	// Step 4: Test sensors
 	ui.Progress(75, "Testing sensors...")
@@ -59,7 +79,13 @@ func (s *ThermalStage) Run(ctx context.Context, ui core.UI) error {
 		ui.Log(core.LogInfo, "✓ Sensors responding")
 	}
 
-	// Step 5: Note about fancontrol
+	// Step 5: fancontrol service overrides
+	ui.Progress(85, "Configuring fancontrol service...")
+	if err := installUnits(ctx, ui, s.Units()...); err != nil {
+		ui.Log(core.LogWarn, fmt.Sprintf("Could not configure fancontrol service: %v", err))
+	}
+
+	// Step 6: Note about fancontrol
 	ui.Progress(90, "Fan control ready...")
 	ui.Log(core.LogInfo, "")
 	ui.Log(core.LogInfo, "To configure fan curves, run:")
@@ -73,5 +99,5 @@ func (s *ThermalStage) Run(ctx context.Context, ui core.UI) error {
 }
 
 func (s *ThermalStage) Rollback(ctx context.Context) error {
-	return nil
+	return removeUnits(ctx, s.Units()...)
 }
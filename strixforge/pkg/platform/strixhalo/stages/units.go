@@ -0,0 +1,35 @@
+package stages
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/daveweinstein1/strixforge/pkg/core"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+)
+
+// installUnits installs a stage's managed systemd files and logs what changed
+func installUnits(ctx context.Context, ui core.UI, files ...system.ManagedFile) error {
+	manager := system.NewUnitManager(system.NewServiceManager())
+	changes, err := manager.Install(ctx, files...)
+	for _, c := range changes {
+		switch c.Action {
+		case system.ChangeCreate:
+			ui.Log(core.LogInfo, fmt.Sprintf("✓ Installed %s", c.File.Path))
+		case system.ChangeUpdate:
+			ui.Log(core.LogInfo, fmt.Sprintf("✓ Updated %s", c.File.Path))
+			for _, line := range strings.Split(strings.TrimSuffix(c.Diff, "\n"), "\n") {
+				ui.Log(core.LogDebug, "  "+line)
+			}
+		case system.ChangeConflict:
+			ui.Log(core.LogWarn, fmt.Sprintf("%s exists and is not managed by strixforge, leaving it alone", c.File.Path))
+		}
+	}
+	return err
+}
+
+// removeUnits removes a stage's managed systemd files
+func removeUnits(ctx context.Context, files ...system.ManagedFile) error {
+	return system.NewUnitManager(system.NewServiceManager()).Remove(ctx, files...)
+}
@@ -7,25 +7,41 @@ import (
 	"os/exec"
 	"strings"
 
+	"github.com/daveweinstein1/strixforge/pkg/config"
 	"github.com/daveweinstein1/strixforge/pkg/core"
 	"github.com/daveweinstein1/strixforge/pkg/system"
 )
 
 // ValidateStage verifies the installation
-type ValidateStage struct{}
+type ValidateStage struct {
+	runtime system.ContainerRuntime
+}
 
-func NewValidateStage() *ValidateStage { return &ValidateStage{} }
+// NewValidateStage creates the validation stage, checking runtime as the
+// container runtime
+func NewValidateStage(runtime system.ContainerRuntime) *ValidateStage {
+	return &ValidateStage{runtime: runtime}
+}
+
+// runtimeUnits are the systemd units container runtimes are activated by;
+// podman has no daemon
+var runtimeUnits = map[string]string{
+	config.RuntimeLXD:    "lxd.socket",
+	config.RuntimeDocker: "docker.socket",
+}
 
 func (s *ValidateStage) ID() string   { return "validate" }
 func (s *ValidateStage) Name() string { return "Validation" }
 func (s *ValidateStage) Description() string {
-	return "Verify kernel, GPU, IOMMU, and LXD configuration"
+	return "Verify kernel, GPU, IOMMU, and container runtime configuration"
 }
 func (s *ValidateStage) Optional() bool { return false }
 
 func (s *ValidateStage) Run(ctx context.Context, ui core.UI) error {
-	systemd := system.NewSystemd()
+	systemd := system.NewServiceManager()
+	journal := system.NewJournal()
 	failures := 0
+	var diagnostics []core.LogEntry
 
 	// Check 1: Kernel version
 	ui.Progress(10, "Checking kernel version...")
@@ -88,34 +104,69 @@ func (s *ValidateStage) Run(ctx context.Context, ui core.UI) error {
 		ui.Log(core.LogInfo, "✓ Vulkan is functional")
 	}
 
-	// Check 5: LXD service
-	ui.Progress(70, "Checking LXD service...")
-	if systemd.IsActive(ctx, "lxd.socket") {
-		ui.Log(core.LogInfo, "✓ LXD socket is active")
+	// Check 5: Container runtime service
+	if unit, ok := runtimeUnits[s.runtime.Name()]; ok {
+		ui.Progress(70, fmt.Sprintf("Checking %s service...", s.runtime.Name()))
+		if systemd.IsActive(ctx, unit) {
+			ui.Log(core.LogInfo, fmt.Sprintf("✓ %s is active", unit))
+		} else if state, err := systemd.State(ctx, unit); err == nil && state.Failed() {
+			ui.Log(core.LogError, fmt.Sprintf("✗ %s failed: %s", unit, state.FailureReason()))
+			failures++
+		} else {
+			ui.Log(core.LogError, fmt.Sprintf("✗ %s is not running", unit))
+			failures++
+		}
+		if !systemd.IsActive(ctx, unit) {
+			if entries, err := journal.UnitLogs(ctx, unit, system.DiagnosticLines); err == nil {
+				diagnostics = append(diagnostics, journalLogs(entries)...)
+			}
+		}
+	}
+
+	// Check 6: Container runtime is usable by this user
+	ui.Progress(85, fmt.Sprintf("Testing %s access...", s.runtime.Name()))
+	if err := s.runtime.Ping(ctx); err != nil {
+		ui.Log(core.LogWarn, fmt.Sprintf("✗ Cannot use %s - may need to log out/in for group changes: %v", s.runtime.Name(), err))
 	} else {
-		ui.Log(core.LogError, "✗ LXD socket is not running")
-		failures++
+		ui.Log(core.LogInfo, fmt.Sprintf("✓ %s access working", s.runtime.Name()))
 	}
 
-	// Check 6: LXD can run
-	ui.Progress(85, "Testing LXD access...")
-	result, err = system.Exec(ctx, "lxc", "list")
-	if err != nil || result.ExitCode != 0 {
-		ui.Log(core.LogWarn, "✗ Cannot run 'lxc list' - may need to log out/in for group changes")
+	// Check 7: Driver errors since boot (GPU, NPU, network)
+	ui.Progress(95, "Checking kernel messages...")
+	entries, err := journal.KernelMessages(ctx, kernelDriverPattern, system.PriorityErr)
+	if err != nil {
+		ui.Log(core.LogWarn, fmt.Sprintf("Could not read kernel messages: %v", err))
+	} else if len(entries) == 0 {
+		ui.Log(core.LogInfo, "✓ No driver errors in kernel log")
 	} else {
-		ui.Log(core.LogInfo, "✓ LXD access working")
+		ui.Log(core.LogWarn, fmt.Sprintf("⚠ %d driver errors in kernel log since boot", len(entries)))
+		if len(entries) > system.DiagnosticLines {
+			entries = entries[len(entries)-system.DiagnosticLines:]
+		}
+		for _, entry := range journalLogs(entries) {
+			ui.Log(core.LogWarn, "  "+entry.Message)
+		}
+		diagnostics = append(diagnostics, journalLogs(entries)...)
 	}
 
 	ui.Progress(100, "Validation complete")
 
 	if failures > 0 {
-		return fmt.Errorf("%d validation checks failed", failures)
+		err := fmt.Errorf("%d validation checks failed", failures)
+		if len(diagnostics) > 0 {
+			return &core.DiagnosticError{Err: err, Logs: diagnostics}
+		}
+		return err
 	}
 
 	ui.Log(core.LogInfo, "All validation checks passed!")
 	return nil
 }
 
+// kernelDriverPattern matches kernel messages from the drivers validation
+// cares about: amdgpu (GPU), amdxdna (NPU) and ice (Intel E610 network)
+const kernelDriverPattern = `\b(amdgpu|amdxdna|ice)\b`
+
 func (s *ValidateStage) Rollback(ctx context.Context) error {
 	return nil
 }
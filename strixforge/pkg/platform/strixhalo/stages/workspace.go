@@ -2,156 +2,145 @@ package stages
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 
+	"github.com/daveweinstein1/strixforge/pkg/config"
 	"github.com/daveweinstein1/strixforge/pkg/core"
 	"github.com/daveweinstein1/strixforge/pkg/system"
+	"github.com/daveweinstein1/strixforge/pkg/workspace"
 )
 
-// WorkspaceStage provisions development containers
-type WorkspaceStage struct{}
-
-func NewWorkspaceStage() *WorkspaceStage { return &WorkspaceStage{} }
-
-func (s *WorkspaceStage) ID() string   { return "workspace" }
-func (s *WorkspaceStage) Name() string { return "AI & Dev Workspaces" }
-func (s *WorkspaceStage) Description() string {
-	return "Create ai-lab (ROCm/PyTorch) and dev-lab (Rust/Go) containers"
+// WorkspaceStage provisions the containers declared in strixhalo.yaml
+type WorkspaceStage struct {
+	config  *config.Config
+	specs   config.ContainerSpecs
+	runtime system.ContainerRuntime
+	created []string
 }
-func (s *WorkspaceStage) Optional() bool { return true }
 
-func (s *WorkspaceStage) Run(ctx context.Context, ui core.UI) error {
-	lxd := system.NewLXD()
-
-	// Create ai-lab container
-	ui.Progress(10, "Creating ai-lab container...")
-	if err := s.createAILab(ctx, ui, lxd); err != nil {
-		return err
-	}
-
-	// Create dev-lab container
-	ui.Progress(55, "Creating dev-lab container...")
-	if err := s.createDevLab(ctx, ui, lxd); err != nil {
-		return err
-	}
-
-	ui.Progress(100, "Workspaces ready")
-	return nil
+// NewWorkspaceStage creates the stage for the containers and volumes in a
+// config, run by runtime. The snapshot timer is pointed at the same config
+// file.
+func NewWorkspaceStage(cfg *config.Config, runtime system.ContainerRuntime) *WorkspaceStage {
+	return &WorkspaceStage{config: cfg, specs: cfg.Containers, runtime: runtime}
 }
 
-func (s *WorkspaceStage) createAILab(ctx context.Context, ui core.UI, lxd *system.LXD) error {
-	name := "ai-lab"
-
-	// Check if already exists
-	if lxd.ContainerExists(ctx, name) {
-		ui.Log(core.LogInfo, fmt.Sprintf("Container %s already exists, skipping creation", name))
+// Units returns the snapshot timer when any container has a schedule.
+// Scheduled snapshots are LXD snapshots.
+func (s *WorkspaceStage) Units() []system.ManagedFile {
+	if s.runtime.Name() != config.RuntimeLXD {
 		return nil
 	}
-
-	// Create container
-	ui.Log(core.LogInfo, "Launching ai-lab container from archlinux image...")
-	if err := lxd.CreateContainer(ctx, name, "images:archlinux/current"); err != nil {
-		return fmt.Errorf("failed to create %s: %v", name, err)
+	scheduled := false
+	for _, spec := range s.specs {
+		scheduled = scheduled || spec.Snapshots.Scheduled()
 	}
-
-	// Wait for network
-	ui.Progress(20, "Waiting for container network...")
-	if err := lxd.WaitForNetwork(ctx, name); err != nil {
-		ui.Log(core.LogWarn, "Network wait timed out, continuing anyway")
+	if !scheduled {
+		return nil
 	}
-
-	// Install ROCm and AI packages
-	ui.Progress(25, "Installing ROCm stack...")
-	packages := []string{
-		"rocm-hip-sdk",
-		"python-pytorch-rocm",
-		"python-numpy",
-		"python-pip",
-		"git",
-		"base-devel",
-		"fastfetch",
-		"vim",
-		"ollama",
+	binary, err := os.Executable()
+	if err != nil {
+		binary = "/usr/local/bin/strixforge"
 	}
+	return workspace.SnapshotUnits(binary, s.config.Path)
+}
 
-	for i, pkg := range packages {
-		ui.Progress(25+(i*2), fmt.Sprintf("Installing %s...", pkg))
-		_, err := lxd.ExecInContainer(ctx, name, "pacman", "-S", "--needed", "--noconfirm", pkg)
-		if err != nil {
-			ui.Log(core.LogWarn, fmt.Sprintf("Failed to install %s: %v", pkg, err))
-		}
+func (s *WorkspaceStage) ID() string   { return "workspace" }
+func (s *WorkspaceStage) Name() string { return "AI & Dev Workspaces" }
+func (s *WorkspaceStage) Description() string {
+	if len(s.specs) == 0 {
+		return "No workspace containers configured"
 	}
-
-	// Clone ComfyUI
-	ui.Progress(48, "Cloning ComfyUI...")
-	_, err := lxd.ExecInContainer(ctx, name, "git", "clone", "https://github.com/comfyanonymous/ComfyUI", "/opt/ComfyUI")
-	if err != nil {
-		ui.Log(core.LogWarn, fmt.Sprintf("Failed to clone ComfyUI: %v", err))
-	} else {
-		// Install ComfyUI requirements
-		ui.Progress(50, "Installing ComfyUI dependencies...")
-		_, _ = lxd.ExecInContainer(ctx, name, "pip", "install", "-r", "/opt/ComfyUI/requirements.txt")
-		ui.Log(core.LogInfo, "✓ ComfyUI installed at /opt/ComfyUI")
+	names := make([]string, len(s.specs))
+	for i, spec := range s.specs {
+		names[i] = spec.Name
 	}
-
-	ui.Log(core.LogInfo, "✓ ai-lab container ready")
-	ui.Log(core.LogInfo, "  Run 'ollama pull llama3.2' to download a model")
-	ui.Log(core.LogInfo, "  Run 'python /opt/ComfyUI/main.py' to start ComfyUI")
-	return nil
+	if s.config.UpdateContainers {
+		return "Create or upgrade containers: " + strings.Join(names, ", ")
+	}
+	return "Create or update containers: " + strings.Join(names, ", ")
 }
+func (s *WorkspaceStage) Optional() bool { return true }
 
-func (s *WorkspaceStage) createDevLab(ctx context.Context, ui core.UI, lxd *system.LXD) error {
-	name := "dev-lab"
-
-	// Check if already exists
-	if lxd.ContainerExists(ctx, name) {
-		ui.Log(core.LogInfo, fmt.Sprintf("Container %s already exists, skipping creation", name))
+func (s *WorkspaceStage) Run(ctx context.Context, ui core.UI) error {
+	if len(s.specs) == 0 {
+		ui.Log(core.LogInfo, "No containers declared in config, nothing to do")
 		return nil
 	}
 
-	// Create container
-	ui.Log(core.LogInfo, "Launching dev-lab container from archlinux image...")
-	if err := lxd.CreateContainer(ctx, name, "images:archlinux/current"); err != nil {
-		return fmt.Errorf("failed to create %s: %v", name, err)
+	if err := s.runtime.Ping(ctx); err != nil {
+		return fmt.Errorf("cannot reach %s: %v", s.runtime.Name(), err)
 	}
+	reconciler := workspace.NewReconciler(s.runtime, s.config.Volumes)
 
-	// Wait for network
-	ui.Progress(65, "Waiting for container network...")
-	if err := lxd.WaitForNetwork(ctx, name); err != nil {
-		ui.Log(core.LogWarn, "Network wait timed out, continuing anyway")
+	// GPU allocations on an APU come out of the same RAM as containers
+	if host, err := system.ReadMemoryInfo(); err != nil {
+		ui.Log(core.LogWarn, fmt.Sprintf("Could not read host memory, skipping memory budget: %v", err))
+	} else {
+		for _, warning := range workspace.PlanMemory(s.specs, host).Warnings() {
+			ui.Log(core.LogWarn, "⚠ "+warning)
+		}
 	}
 
-	// Install development packages
-	ui.Progress(70, "Installing development tools...")
-	packages := []string{
-		"base-devel",
-		"git",
-		"rust",
-		"go",
-		"nodejs",
-		"npm",
-		"python",
-		"python-pip",
-		"vim",
-		"neovim",
-		"fastfetch",
+	// A failed upgrade leaves a working container, so the rest still run
+	var updateErrs []error
+	s.created = nil
+	for i, spec := range s.specs {
+		ui.Progress(5+i*90/len(s.specs), fmt.Sprintf("Reconciling %s container...", spec.Name))
+		result, err := reconciler.Reconcile(ctx, ui, spec)
+		if result != nil && result.Action == workspace.ActionCreated {
+			s.created = append(s.created, spec.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", spec.Name, err)
+		}
+
+		switch result.Action {
+		case workspace.ActionCreated:
+			ui.Log(core.LogInfo, fmt.Sprintf("✓ %s container ready", spec.Name))
+		case workspace.ActionUpdated:
+			ui.Log(core.LogInfo, fmt.Sprintf("✓ %s container updated (%s)", spec.Name, strings.Join(result.Changes, ", ")))
+		default:
+			ui.Log(core.LogInfo, fmt.Sprintf("✓ %s container already matches its spec", spec.Name))
+		}
+		if s.config.UpdateContainers && result.Action != workspace.ActionCreated {
+			if _, err := workspace.Update(ctx, ui, s.runtime, spec); err != nil {
+				ui.Log(core.LogError, fmt.Sprintf("✗ %s packages not upgraded: %v", spec.Name, err))
+				updateErrs = append(updateErrs, fmt.Errorf("%s: %w", spec.Name, err))
+			} else {
+				ui.Log(core.LogInfo, fmt.Sprintf("✓ %s packages upgraded", spec.Name))
+			}
+		}
+		for _, svc := range spec.Services {
+			ui.Log(core.LogInfo, fmt.Sprintf("  %s: %s", svc.Name, svc.URL()))
+		}
+		for _, note := range spec.Notes {
+			ui.Log(core.LogInfo, "  "+note)
+		}
 	}
 
-	for i, pkg := range packages {
-		ui.Progress(70+(i*2), fmt.Sprintf("Installing %s...", pkg))
-		_, err := lxd.ExecInContainer(ctx, name, "pacman", "-S", "--needed", "--noconfirm", pkg)
-		if err != nil {
-			ui.Log(core.LogWarn, fmt.Sprintf("Failed to install %s: %v", pkg, err))
+	if units := s.Units(); len(units) > 0 {
+		ui.Progress(95, "Installing snapshot timer...")
+		if err := installUnits(ctx, ui, units...); err != nil {
+			ui.Log(core.LogWarn, fmt.Sprintf("Snapshot timer not installed: %v", err))
 		}
 	}
 
-	ui.Log(core.LogInfo, "✓ dev-lab container ready")
+	if len(updateErrs) > 0 {
+		return errors.Join(updateErrs...)
+	}
+	ui.Progress(100, "Workspaces ready")
 	return nil
 }
 
+// Rollback deletes the containers this run created; containers that
+// already existed are left alone
 func (s *WorkspaceStage) Rollback(ctx context.Context) error {
-	lxd := system.NewLXD()
-	lxd.DeleteContainer(ctx, "ai-lab", true)
-	lxd.DeleteContainer(ctx, "dev-lab", true)
+	for _, name := range s.created {
+		s.runtime.Delete(ctx, name)
+	}
 	return nil
 }
@@ -0,0 +1,326 @@
+package system
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// AURHelper builds and installs AUR packages as an unprivileged user
+type AURHelper interface {
+	// Name returns the helper command (e.g. "yay", "paru")
+	Name() string
+
+	// Install installs packages from the repos or the AUR
+	Install(ctx context.Context, packages ...string) error
+
+	// IsInstalled checks if a package is installed
+	IsInstalled(ctx context.Context, pkg string) bool
+
+	// Query returns metadata for the packages that exist. Packages that
+	// could not be found are simply absent from the result.
+	Query(ctx context.Context, packages ...string) ([]AURPackage, error)
+
+	// Search returns package names matching a term in the AUR
+	Search(ctx context.Context, term string) ([]string, error)
+}
+
+// AURPackage is package metadata reported by an AUR helper
+type AURPackage struct {
+	Name       string
+	Version    string
+	Repository string // "aur" or a sync repo name
+	Provides   []string
+	Replaces   []string
+}
+
+// aurCLI runs a yay-compatible helper as the target user
+type aurCLI struct {
+	command string
+	user    string
+}
+
+func (a *aurCLI) Name() string { return a.command }
+
+// exec runs the helper as the target user; makepkg refuses to run as root
+func (a *aurCLI) exec(ctx context.Context, args ...string) (*ExecResult, error) {
+	if current, err := user.Current(); err == nil && current.Username == a.user {
+		return Exec(ctx, a.command, args...)
+	}
+	sudoArgs := append([]string{"-u", a.user, a.command}, args...)
+	return Exec(ctx, "sudo", sudoArgs...)
+}
+
+func (a *aurCLI) Install(ctx context.Context, packages ...string) error {
+	args := append([]string{"-S", "--needed", "--noconfirm"}, packages...)
+	result, err := a.exec(ctx, args...)
+	if err != nil {
+		return fmt.Errorf("%s install failed: %s\n%s", a.command, err, result.Stderr)
+	}
+	return nil
+}
+
+func (a *aurCLI) IsInstalled(ctx context.Context, pkg string) bool {
+	result, err := Exec(ctx, "pacman", "-Q", pkg)
+	return err == nil && result.ExitCode == 0
+}
+
+func (a *aurCLI) Query(ctx context.Context, packages ...string) ([]AURPackage, error) {
+	args := append([]string{"-Si"}, packages...)
+	result, err := a.exec(ctx, args...)
+	return parseAURQuery(a.command, result, err)
+}
+
+// parseAURQuery interprets `-Si` output. Helpers exit non-zero when any
+// package is missing but still print the rest: paru reports "package 'x'
+// was not found", yay "Could not find all required packages".
+func parseAURQuery(command string, result *ExecResult, err error) ([]AURPackage, error) {
+	infos := ParseAURInfo(result.Stdout)
+	if err != nil && len(infos) == 0 {
+		stderr := strings.ToLower(result.Stderr)
+		if !strings.Contains(stderr, "not found") && !strings.Contains(stderr, "could not find") {
+			return nil, fmt.Errorf("%s query failed: %s\n%s", command, err, result.Stderr)
+		}
+	}
+	return infos, nil
+}
+
+func (a *aurCLI) Search(ctx context.Context, term string) ([]string, error) {
+	result, err := a.exec(ctx, "-Ssq", "--aur", term)
+	if err != nil && strings.TrimSpace(result.Stdout) == "" {
+		// No matches is reported as failure
+		return nil, nil
+	}
+	return strings.Fields(result.Stdout), nil
+}
+
+// Yay provides AUR package management (runs as user, not root)
+type Yay struct {
+	aurCLI
+}
+
+// NewYay creates a new Yay instance for the specified user
+func NewYay(user string) *Yay {
+	return &Yay{aurCLI{command: "yay", user: user}}
+}
+
+// Paru provides AUR package management via paru (runs as user, not root)
+type Paru struct {
+	aurCLI
+}
+
+// NewParu creates a new Paru instance for the specified user
+func NewParu(user string) *Paru {
+	return &Paru{aurCLI{command: "paru", user: user}}
+}
+
+// NewAURHelper returns the named helper ("yay" or "paru") for user
+func NewAURHelper(name, user string) (AURHelper, error) {
+	switch name {
+	case "yay":
+		return NewYay(user), nil
+	case "paru":
+		return NewParu(user), nil
+	default:
+		return nil, fmt.Errorf("unsupported AUR helper: %s", name)
+	}
+}
+
+// DetectAURHelper returns the first installed helper, preferring yay.
+// It returns nil if neither yay nor paru is installed.
+func DetectAURHelper(user string) AURHelper {
+	for _, name := range []string{"yay", "paru"} {
+		if CheckCommand(name) {
+			helper, _ := NewAURHelper(name, user)
+			return helper
+		}
+	}
+	return nil
+}
+
+// TargetUser returns the non-root user the installer acts on behalf of.
+// When run through sudo or pkexec, that is the invoking user; otherwise the
+// login user or the current user. Returns an error if only root is known.
+func TargetUser() (string, error) {
+	if name := os.Getenv("SUDO_USER"); name != "" && name != "root" {
+		return name, nil
+	}
+
+	if uid := os.Getenv("PKEXEC_UID"); uid != "" {
+		if u, err := user.LookupId(uid); err == nil && u.Username != "root" {
+			return u.Username, nil
+		}
+	}
+
+	// The audit login UID survives su and sudo -i
+	if data, err := os.ReadFile("/proc/self/loginuid"); err == nil {
+		uid := strings.TrimSpace(string(data))
+		if id, err := strconv.ParseUint(uid, 10, 32); err == nil && id != 0 && id != 4294967295 {
+			if u, err := user.LookupId(uid); err == nil {
+				return u.Username, nil
+			}
+		}
+	}
+
+	if u, err := user.Current(); err == nil && u.Username != "root" {
+		return u.Username, nil
+	}
+
+	return "", fmt.Errorf("could not determine a non-root user for AUR builds; run the installer with sudo from your user account")
+}
+
+// ParseAURInfo parses `yay -Si` / `paru -Si` output into packages.
+// Records are "Key : Value" lines separated by blank lines.
+func ParseAURInfo(output string) []AURPackage {
+	var packages []AURPackage
+	var current *AURPackage
+	var lastKey string
+
+	flush := func() {
+		if current != nil && current.Name != "" {
+			packages = append(packages, *current)
+		}
+		current = nil
+		lastKey = ""
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, " : ")
+		if !ok || strings.HasPrefix(line, " ") {
+			// Continuation of a wrapped multi-value field
+			if current != nil {
+				appendAURField(current, lastKey, strings.TrimSpace(line))
+			}
+			continue
+		}
+
+		if current == nil {
+			current = &AURPackage{}
+		}
+		lastKey = strings.TrimSpace(key)
+		appendAURField(current, lastKey, strings.TrimSpace(value))
+	}
+	flush()
+
+	return packages
+}
+
+func appendAURField(pkg *AURPackage, key, value string) {
+	switch key {
+	case "Name":
+		pkg.Name = value
+	case "Version":
+		pkg.Version = value
+	case "Repository":
+		pkg.Repository = value
+	case "Provides":
+		pkg.Provides = append(pkg.Provides, splitAURList(value)...)
+	case "Replaces":
+		pkg.Replaces = append(pkg.Replaces, splitAURList(value)...)
+	}
+}
+
+// splitAURList splits a space-separated field, ignoring the "None" placeholder
+func splitAURList(value string) []string {
+	if value == "None" {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Fields(value) {
+		// Drop version constraints such as "foo=1.2"
+		name, _, _ := strings.Cut(item, "=")
+		items = append(items, name)
+	}
+	return items
+}
+
+// AURPreflight reports which requested packages can be installed
+type AURPreflight struct {
+	Found       []AURPackage
+	Missing     []string
+	Suggestions map[string][]string // missing name -> similarly named packages
+}
+
+// OK returns true if every requested package was found
+func (p *AURPreflight) OK() bool {
+	return len(p.Missing) == 0
+}
+
+// CheckAURPackages verifies that packages exist before an install starts.
+// Missing packages are reported with suggestions; a package that now only
+// exists as another package's Provides/Replaces entry (i.e. it was renamed)
+// suggests the new name.
+func CheckAURPackages(ctx context.Context, helper AURHelper, packages ...string) (*AURPreflight, error) {
+	infos, err := helper.Query(ctx, packages...)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]AURPackage, len(infos))
+	for _, info := range infos {
+		byName[info.Name] = info
+	}
+
+	preflight := &AURPreflight{Suggestions: make(map[string][]string)}
+	for _, pkg := range packages {
+		if info, ok := byName[pkg]; ok {
+			preflight.Found = append(preflight.Found, info)
+			continue
+		}
+
+		preflight.Missing = append(preflight.Missing, pkg)
+		if suggestions := suggestAURReplacements(ctx, helper, pkg); len(suggestions) > 0 {
+			preflight.Suggestions[pkg] = suggestions
+		}
+	}
+
+	return preflight, nil
+}
+
+// suggestAURReplacements searches for packages that provide or replace a
+// missing package, falling back to the closest name matches
+func suggestAURReplacements(ctx context.Context, helper AURHelper, pkg string) []string {
+	matches, err := helper.Search(ctx, pkg)
+	if err != nil || len(matches) == 0 {
+		return nil
+	}
+	if len(matches) > 5 {
+		matches = matches[:5]
+	}
+
+	candidates, err := helper.Query(ctx, matches...)
+	if err != nil {
+		return matches
+	}
+
+	var renamed []string
+	for _, info := range candidates {
+		if containsString(info.Provides, pkg) || containsString(info.Replaces, pkg) {
+			renamed = append(renamed, info.Name)
+		}
+	}
+	if len(renamed) > 0 {
+		return renamed
+	}
+	return matches
+}
+
+func containsString(list []string, item string) bool {
+	for _, s := range list {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var (
+	aurGit     = AURPackage{Name: "git", Version: "2.51.0-1", Repository: "extra", Provides: []string{"git-core"}, Replaces: []string{"git-core"}}
+	aurYayBin  = AURPackage{Name: "yay-bin", Version: "12.5.2-1", Repository: "aur", Provides: []string{"yay"}}
+	aurRocm    = AURPackage{Name: "rocm-hip-sdk", Version: "6.4.3-1", Repository: "extra"}
+	aurTorch   = AURPackage{Name: "python-pytorch-rocm", Version: "2.8.0-3", Repository: "extra", Provides: []string{"python-pytorch", "libtorch.so"}}
+	aurNordVPN = AURPackage{Name: "nordvpn-bin", Version: "4.1.1-1", Repository: "aur", Provides: []string{"nordvpn"}, Replaces: []string{"nordvpn"}}
+)
+
+func readTestdata(t *testing.T, file string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestParseAURInfo(t *testing.T) {
+	tests := []struct {
+		file string
+		want []AURPackage
+	}{
+		// Sync repo records come from pacman, AUR records from the helper
+		{"yay-si.stdout", []AURPackage{aurGit, aurYayBin}},
+		{"yay-si-missing.stdout", []AURPackage{aurGit}},
+		{"paru-si.stdout", []AURPackage{aurRocm, aurTorch, aurNordVPN}},
+		{"paru-si-missing.stdout", []AURPackage{aurNordVPN}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got := ParseAURInfo(readTestdata(t, tt.file))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAURInfo() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAURQuery(t *testing.T) {
+	exitErr := errors.New("exit status 1")
+	tests := []struct {
+		name    string
+		stdout  string
+		stderr  string
+		want    []AURPackage
+		wantErr bool
+	}{
+		{"yay missing", "yay-si-missing.stdout", "yay-si-missing.stderr", []AURPackage{aurGit}, false},
+		{"paru missing", "paru-si-missing.stdout", "paru-si-missing.stderr", []AURPackage{aurNordVPN}, false},
+		{"yay all missing", "", "yay-si-missing.stderr", nil, false},
+		{"paru all missing", "", "paru-si-missing.stderr", nil, false},
+		{"failure", "", "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &ExecResult{ExitCode: 1}
+			if tt.stdout != "" {
+				result.Stdout = readTestdata(t, tt.stdout)
+			}
+			if tt.stderr != "" {
+				result.Stderr = readTestdata(t, tt.stderr)
+			}
+			got, err := parseAURQuery("yay", result, exitErr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("packages = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeAURHelper answers queries from parsed -Si fixtures
+type fakeAURHelper struct {
+	packages []AURPackage
+	search   map[string][]string
+}
+
+func (f *fakeAURHelper) Name() string                                          { return "paru" }
+func (f *fakeAURHelper) Install(ctx context.Context, packages ...string) error { return nil }
+func (f *fakeAURHelper) IsInstalled(ctx context.Context, pkg string) bool      { return false }
+
+func (f *fakeAURHelper) Query(ctx context.Context, packages ...string) ([]AURPackage, error) {
+	var found []AURPackage
+	for _, info := range f.packages {
+		if containsString(packages, info.Name) {
+			found = append(found, info)
+		}
+	}
+	return found, nil
+}
+
+func (f *fakeAURHelper) Search(ctx context.Context, term string) ([]string, error) {
+	return f.search[term], nil
+}
+
+func TestCheckAURPackages(t *testing.T) {
+	helper := &fakeAURHelper{
+		packages: ParseAURInfo(readTestdata(t, "paru-si.stdout")),
+		search: map[string][]string{
+			"nordvpn":     {"nordvpn-bin", "nordvpn-gui"},
+			"rocm-sdk":    {"rocm-hip-sdk", "rocm-sdk-builder"},
+			"python-rocm": nil,
+		},
+	}
+
+	preflight, err := CheckAURPackages(context.Background(), helper, "rocm-hip-sdk", "nordvpn", "rocm-sdk", "python-rocm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []AURPackage{aurRocm}; !reflect.DeepEqual(preflight.Found, want) {
+		t.Errorf("Found = %+v, want %+v", preflight.Found, want)
+	}
+	if want := []string{"nordvpn", "rocm-sdk", "python-rocm"}; !reflect.DeepEqual(preflight.Missing, want) {
+		t.Errorf("Missing = %v, want %v", preflight.Missing, want)
+	}
+	want := map[string][]string{
+		// nordvpn-bin provides and replaces nordvpn, so it is the rename
+		"nordvpn": {"nordvpn-bin"},
+		// Nothing provides rocm-sdk, so the search matches are offered
+		"rocm-sdk": {"rocm-hip-sdk", "rocm-sdk-builder"},
+	}
+	if !reflect.DeepEqual(preflight.Suggestions, want) {
+		t.Errorf("Suggestions = %v, want %v", preflight.Suggestions, want)
+	}
+	if preflight.OK() {
+		t.Error("OK() = true with missing packages")
+	}
+}
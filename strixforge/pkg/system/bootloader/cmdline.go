@@ -0,0 +1,219 @@
+package bootloader
+
+import (
+	"strings"
+)
+
+// Param is one kernel command line parameter: a flag such as "quiet" or a
+// key=value pair such as "iommu=pt"
+type Param struct {
+	Key      string
+	Value    string
+	HasValue bool
+}
+
+// ParseParam parses a single parameter. Quotes around the value, which the
+// kernel uses for values with spaces, are removed.
+func ParseParam(s string) Param {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return Param{Key: s}
+	}
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	return Param{Key: key, Value: value, HasValue: true}
+}
+
+// String formats the parameter as the kernel expects it
+func (p Param) String() string {
+	if !p.HasValue {
+		return p.Key
+	}
+	if strings.ContainsAny(p.Value, " \t") {
+		return p.Key + `="` + p.Value + `"`
+	}
+	return p.Key + "=" + p.Value
+}
+
+// List splits a comma-separated value such as modprobe.blacklist=a,b
+func (p Param) List() []string {
+	var items []string
+	for _, item := range strings.Split(p.Value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Cmdline is a parsed kernel command line. Parameters keep their order and
+// keys may repeat. Everything after "--" is passed to init and is left
+// alone by edits.
+type Cmdline struct {
+	Params []Param
+}
+
+// ParseCmdline splits a command line into parameters, keeping quoted values
+// with spaces together
+func ParseCmdline(s string) Cmdline {
+	var c Cmdline
+	var word strings.Builder
+	inQuote := false
+	flush := func() {
+		if word.Len() > 0 {
+			c.Params = append(c.Params, ParseParam(word.String()))
+			word.Reset()
+		}
+	}
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			word.WriteRune(r)
+		case !inQuote && (r == ' ' || r == '\t' || r == '\n'):
+			flush()
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return c
+}
+
+// String formats the command line
+func (c Cmdline) String() string {
+	words := make([]string, len(c.Params))
+	for i, p := range c.Params {
+		words[i] = p.String()
+	}
+	return strings.Join(words, " ")
+}
+
+// kernelParams returns the number of parameters before "--"
+func (c Cmdline) kernelParams() int {
+	for i, p := range c.Params {
+		if p.Key == "--" && !p.HasValue {
+			return i
+		}
+	}
+	return len(c.Params)
+}
+
+// Get returns the values of every occurrence of key, in order
+func (c Cmdline) Get(key string) []string {
+	var values []string
+	for _, p := range c.Params[:c.kernelParams()] {
+		if p.Key == key {
+			values = append(values, p.Value)
+		}
+	}
+	return values
+}
+
+// Has reports whether key is set, with any value
+func (c Cmdline) Has(key string) bool {
+	return len(c.Get(key)) > 0
+}
+
+// Contains reports whether the exact parameter is present. Keys must match
+// in full, so iommu=pt is not found in amd_iommu=pt.
+func (c Cmdline) Contains(p Param) bool {
+	for _, q := range c.Params[:c.kernelParams()] {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
+
+// insert adds p at the end of the kernel parameters, before any "--"
+func (c *Cmdline) insert(p Param) {
+	n := c.kernelParams()
+	c.Params = append(c.Params[:n], append([]Param{p}, c.Params[n:]...)...)
+}
+
+// Add appends p unless the exact parameter is already present. It reports
+// whether the command line changed.
+func (c *Cmdline) Add(p Param) bool {
+	if c.Contains(p) {
+		return false
+	}
+	c.insert(p)
+	return true
+}
+
+// Set makes p the only value of its key, replacing the first occurrence in
+// place and dropping the rest, or appending it if the key is missing. It
+// reports whether the command line changed.
+func (c *Cmdline) Set(p Param) bool {
+	if values := c.Get(p.Key); len(values) == 1 && c.Contains(p) {
+		return false
+	}
+	n := c.kernelParams()
+	out := make([]Param, 0, len(c.Params))
+	replaced := false
+	for i, q := range c.Params {
+		switch {
+		case i >= n || q.Key != p.Key:
+			out = append(out, q)
+		case !replaced:
+			out = append(out, p)
+			replaced = true
+		}
+	}
+	c.Params = out
+	if !replaced {
+		c.insert(p)
+	}
+	return true
+}
+
+// Remove drops every occurrence of key and reports whether there was one
+func (c *Cmdline) Remove(key string) bool {
+	n := c.kernelParams()
+	out := make([]Param, 0, len(c.Params))
+	for i, q := range c.Params {
+		if i < n && q.Key == key {
+			continue
+		}
+		out = append(out, q)
+	}
+	removed := len(out) != len(c.Params)
+	c.Params = out
+	return removed
+}
+
+// MergeList adds items to a comma-separated list parameter such as
+// modprobe.blacklist. Repeated occurrences of key are combined into the
+// first one, keeping their items in order without duplicates. It reports
+// whether the command line changed.
+func (c *Cmdline) MergeList(key string, items ...string) bool {
+	var merged []string
+	seen := map[string]bool{}
+	occurrences := 0
+	for _, p := range c.Params[:c.kernelParams()] {
+		if p.Key != key {
+			continue
+		}
+		occurrences++
+		for _, item := range p.List() {
+			if !seen[item] {
+				seen[item] = true
+				merged = append(merged, item)
+			}
+		}
+	}
+	added := false
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			merged = append(merged, item)
+			added = true
+		}
+	}
+	if !added && occurrences <= 1 {
+		return false
+	}
+	return c.Set(Param{Key: key, Value: strings.Join(merged, ","), HasValue: true})
+}
@@ -0,0 +1,161 @@
+package bootloader
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCmdline(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []Param
+	}{
+		{"empty", "  ", nil},
+		{"flags and values", "quiet  iommu=pt\tsplash", []Param{
+			{Key: "quiet"},
+			{Key: "iommu", Value: "pt", HasValue: true},
+			{Key: "splash"},
+		}},
+		{"empty value", "root=", []Param{{Key: "root", HasValue: true}}},
+		{"value containing =", "root=UUID=abc", []Param{{Key: "root", Value: "UUID=abc", HasValue: true}}},
+		{"quoted value", `dyndbg="file foo.c +p" quiet`, []Param{
+			{Key: "dyndbg", Value: "file foo.c +p", HasValue: true},
+			{Key: "quiet"},
+		}},
+		{"init args", "quiet -- single", []Param{{Key: "quiet"}, {Key: "--"}, {Key: "single"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseCmdline(tt.in)
+			if !reflect.DeepEqual(got.Params, tt.want) {
+				t.Errorf("ParseCmdline(%q) = %#v, want %#v", tt.in, got.Params, tt.want)
+			}
+		})
+	}
+}
+
+func TestCmdlineStringQuotesSpaces(t *testing.T) {
+	in := `dyndbg="file foo.c +p" iommu=pt quiet`
+	if got := ParseCmdline(in).String(); got != in {
+		t.Errorf("String() = %q, want %q", got, in)
+	}
+}
+
+func TestCmdlineContains(t *testing.T) {
+	c := ParseCmdline("quiet amd_iommu=pt iommu=off -- iommu=pt")
+	tests := []struct {
+		param string
+		want  bool
+	}{
+		{"quiet", true},
+		{"amd_iommu=pt", true},
+		{"iommu=off", true},
+		{"iommu=pt", false}, // only amd_iommu=pt and after "--"
+		{"iommu", false},
+		{"quiet=1", false},
+	}
+	for _, tt := range tests {
+		if got := c.Contains(ParseParam(tt.param)); got != tt.want {
+			t.Errorf("Contains(%q) = %v, want %v", tt.param, got, tt.want)
+		}
+	}
+	if c.Has("single") {
+		t.Error("Has reported a parameter passed to init")
+	}
+}
+
+func TestCmdlineAdd(t *testing.T) {
+	c := ParseCmdline("quiet amd_iommu=pt -- single")
+	if !c.Add(ParseParam("iommu=pt")) {
+		t.Fatal("Add(iommu=pt) reported no change next to amd_iommu=pt")
+	}
+	if c.Add(ParseParam("iommu=pt")) {
+		t.Error("second Add(iommu=pt) reported a change")
+	}
+	if got, want := c.String(), "quiet amd_iommu=pt iommu=pt -- single"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCmdlineSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		param   string
+		want    string
+		changed bool
+	}{
+		{"replaces in place", "quiet iommu=off splash", "iommu=pt", "quiet iommu=pt splash", true},
+		{"drops repeats", "iommu=off quiet iommu=soft", "iommu=pt", "iommu=pt quiet", true},
+		{"appends when missing", "quiet amd_iommu=pt", "iommu=pt", "quiet amd_iommu=pt iommu=pt", true},
+		{"unchanged", "quiet iommu=pt", "iommu=pt", "quiet iommu=pt", false},
+		{"before init args", "quiet -- iommu=off", "iommu=pt", "quiet iommu=pt -- iommu=off", true},
+		{"flag to value", "nomodeset", "nomodeset=0", "nomodeset=0", true},
+		{"quoted value", "quiet", `dyndbg="file a.c +p"`, `quiet dyndbg="file a.c +p"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := ParseCmdline(tt.in)
+			changed := c.Set(ParseParam(tt.param))
+			if got := c.String(); got != tt.want || changed != tt.changed {
+				t.Errorf("Set(%q) = %q, %v; want %q, %v", tt.param, got, changed, tt.want, tt.changed)
+			}
+		})
+	}
+}
+
+func TestCmdlineRemove(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		key     string
+		want    string
+		changed bool
+	}{
+		{"every occurrence", "iommu=off quiet iommu=pt", "iommu", "quiet", true},
+		{"exact key only", "amd_iommu=pt quiet", "iommu", "amd_iommu=pt quiet", false},
+		{"flag", "quiet splash", "quiet", "splash", true},
+		{"keeps init args", "iommu=pt -- iommu=pt", "iommu", "-- iommu=pt", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := ParseCmdline(tt.in)
+			changed := c.Remove(tt.key)
+			if got := c.String(); got != tt.want || changed != tt.changed {
+				t.Errorf("Remove(%q) = %q, %v; want %q, %v", tt.key, got, changed, tt.want, tt.changed)
+			}
+		})
+	}
+}
+
+func TestCmdlineMergeList(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		items   []string
+		want    string
+		changed bool
+	}{
+		{"adds the key", "quiet", []string{"ice"}, "quiet modprobe.blacklist=ice", true},
+		{"extends a list", "modprobe.blacklist=nouveau quiet", []string{"ice"}, "modprobe.blacklist=nouveau,ice quiet", true},
+		{"already listed", "modprobe.blacklist=ice,nouveau", []string{"ice"}, "modprobe.blacklist=ice,nouveau", false},
+		{
+			"combines repeated keys",
+			"modprobe.blacklist=nouveau quiet modprobe.blacklist=ice,radeon",
+			[]string{"ice"},
+			"modprobe.blacklist=nouveau,ice,radeon quiet",
+			true,
+		},
+		{"ignores init args", "quiet -- modprobe.blacklist=ice", []string{"ice"}, "quiet modprobe.blacklist=ice -- modprobe.blacklist=ice", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := ParseCmdline(tt.in)
+			changed := c.MergeList("modprobe.blacklist", tt.items...)
+			if got := c.String(); got != tt.want || changed != tt.changed {
+				t.Errorf("MergeList(%v) = %q, %v; want %q, %v", tt.items, got, changed, tt.want, tt.changed)
+			}
+		})
+	}
+}
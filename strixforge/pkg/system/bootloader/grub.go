@@ -4,8 +4,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"regexp"
-	"strings"
 	"time"
 
 	"github.com/daveweinstein1/strixforge/pkg/system"
@@ -52,50 +50,137 @@ func (g *Grub) Backup(ctx context.Context) (string, error) {
 	return backupPath, nil
 }
 
-// GetCmdlineParams returns current kernel command line parameters
-func (g *Grub) GetCmdlineParams(ctx context.Context) (string, error) {
-	data, err := os.ReadFile(g.configPath)
+// GRUB builds the kernel command line from two variables: options for
+// every boot, then options for normal (non-recovery) boots. New parameters
+// go in the latter, as distributions do.
+const (
+	grubLinuxKey   = "GRUB_CMDLINE_LINUX"
+	grubDefaultKey = "GRUB_CMDLINE_LINUX_DEFAULT"
+)
+
+// ListParams returns the kernel command line of a normal boot, combining
+// GRUB_CMDLINE_LINUX and GRUB_CMDLINE_LINUX_DEFAULT
+func (g *Grub) ListParams(ctx context.Context) (Cmdline, error) {
+	cfg, err := LoadShellConfig(g.configPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to read grub config: %v", err)
+		return Cmdline{}, err
 	}
+	linux, _ := cfg.Get(grubLinuxKey)
+	def, _ := cfg.Get(grubDefaultKey)
+	return ParseCmdline(linux + " " + def), nil
+}
 
-	re := regexp.MustCompile(`GRUB_CMDLINE_LINUX_DEFAULT="([^"]*)"`)
-	matches := re.FindSubmatch(data)
-	if len(matches) < 2 {
-		return "", fmt.Errorf("could not find GRUB_CMDLINE_LINUX_DEFAULT")
+// AddParam adds a parameter to the kernel command line if not present in
+// either variable
+func (g *Grub) AddParam(ctx context.Context, param string) error {
+	return g.edit(ctx, grubAdd(ParseParam(param)))
+}
+
+// grubAdd is the change AddParam makes
+func grubAdd(p Param) func(linux, def *Cmdline) bool {
+	return func(linux, def *Cmdline) bool {
+		if linux.Contains(p) {
+			return false
+		}
+		return def.Add(p)
 	}
+}
 
-	return string(matches[1]), nil
+// SetParam replaces the parameter's key with the given value in whichever
+// variable holds it, dropping it from the other
+func (g *Grub) SetParam(ctx context.Context, param string) error {
+	return g.edit(ctx, grubSet(ParseParam(param)))
 }
 
-// AddParam adds a parameter to kernel command line if not present
-func (g *Grub) AddParam(ctx context.Context, param string) error {
-	current, err := g.GetCmdlineParams(ctx)
-	if err != nil {
-		return err
+// grubSet is the change SetParam makes
+func grubSet(p Param) func(linux, def *Cmdline) bool {
+	return func(linux, def *Cmdline) bool {
+		if !linux.Has(p.Key) {
+			return def.Set(p)
+		}
+		changed := linux.Set(p)
+		return def.Remove(p.Key) || changed
 	}
+}
 
-	// Check if already present
-	if strings.Contains(current, param) {
-		return nil // Already there
+// RemoveParam removes every parameter with the given key from both
+// variables
+func (g *Grub) RemoveParam(ctx context.Context, key string) error {
+	return g.edit(ctx, grubRemove(key))
+}
+
+// grubRemove is the change RemoveParam makes
+func grubRemove(key string) func(linux, def *Cmdline) bool {
+	return func(linux, def *Cmdline) bool {
+		changed := linux.Remove(key)
+		return def.Remove(key) || changed
 	}
+}
 
-	// Add parameter
-	newParams := strings.TrimSpace(current + " " + param)
-	return g.SetCmdlineParams(ctx, newParams)
+// MergeListParam adds items to a comma-separated list parameter, combining
+// occurrences in both variables into the one that holds it first
+func (g *Grub) MergeListParam(ctx context.Context, key string, items ...string) error {
+	return g.edit(ctx, grubMergeList(key, items))
 }
 
-// SetCmdlineParams sets the kernel command line parameters
-func (g *Grub) SetCmdlineParams(ctx context.Context, params string) error {
-	// Use sed to update the config
-	// Note: We use a slightly more robust sed command here, but basically same logic
-	sedCmd := fmt.Sprintf(`sed -i 's/GRUB_CMDLINE_LINUX_DEFAULT="[^"]*"/GRUB_CMDLINE_LINUX_DEFAULT="%s"/' %s`, params, g.configPath)
-	result, err := system.ExecShellSudo(ctx, sedCmd)
+// grubMergeList is the change MergeListParam makes
+func grubMergeList(key string, items []string) func(linux, def *Cmdline) bool {
+	return func(linux, def *Cmdline) bool {
+		if !linux.Has(key) {
+			return def.MergeList(key, items...)
+		}
+		var moved []string
+		for _, value := range def.Get(key) {
+			moved = append(moved, Param{Key: key, Value: value, HasValue: true}.List()...)
+		}
+		changed := def.Remove(key)
+		return linux.MergeList(key, append(moved, items...)...) || changed
+	}
+}
+
+// edit applies a change to the two command line variables, writing the
+// config and regenerating grub.cfg only if either changed
+func (g *Grub) edit(ctx context.Context, change func(linux, def *Cmdline) bool) error {
+	cfg, err := LoadShellConfig(g.configPath)
 	if err != nil {
-		return fmt.Errorf("failed to update grub config: %s\n%s", err, result.Stderr)
+		return err
+	}
+	changed, err := editGrubCmdline(cfg, change)
+	if err != nil || !changed {
+		return err
+	}
+	return g.save(ctx, cfg)
+}
+
+// editGrubCmdline applies change to both command line variables of a
+// parsed /etc/default/grub, storing whichever of them changed
+func editGrubCmdline(cfg *ShellConfig, change func(linux, def *Cmdline) bool) (bool, error) {
+	keys := []string{grubLinuxKey, grubDefaultKey}
+	current := make([]string, len(keys))
+	cmdlines := make([]Cmdline, len(keys))
+	for i, key := range keys {
+		current[i], _ = cfg.Get(key)
+		cmdlines[i] = ParseCmdline(current[i])
+	}
+	if !change(&cmdlines[0], &cmdlines[1]) {
+		return false, nil
+	}
+	for i, key := range keys {
+		if cmdlines[i].String() == ParseCmdline(current[i]).String() {
+			continue
+		}
+		if err := setShellCmdline(cfg, key, current[i], cmdlines[i]); err != nil {
+			return false, err
+		}
 	}
+	return true, nil
+}
 
-	// Update GRUB
+// save writes the config and regenerates grub.cfg from it
+func (g *Grub) save(ctx context.Context, cfg *ShellConfig) error {
+	if err := saveConfig(ctx, g.configPath, cfg.Bytes()); err != nil {
+		return err
+	}
 	return g.update(ctx)
 }
 
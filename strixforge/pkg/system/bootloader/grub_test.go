@@ -0,0 +1,57 @@
+package bootloader
+
+import "testing"
+
+func TestEditGrubCmdline(t *testing.T) {
+	const in = `GRUB_CMDLINE_LINUX_DEFAULT="loglevel=3 quiet"
+GRUB_CMDLINE_LINUX="iommu=off modprobe.blacklist=nouveau"
+`
+	tests := []struct {
+		name   string
+		change func(linux, def *Cmdline) bool
+		want   string
+	}{
+		{"add skips a value in GRUB_CMDLINE_LINUX", grubAdd(ParseParam("iommu=off")), in},
+		{"add goes to the default variable", grubAdd(ParseParam("iommu=pt")), `GRUB_CMDLINE_LINUX_DEFAULT="loglevel=3 quiet iommu=pt"
+GRUB_CMDLINE_LINUX="iommu=off modprobe.blacklist=nouveau"
+`},
+		{"set replaces where the key is", grubSet(ParseParam("iommu=pt")), `GRUB_CMDLINE_LINUX_DEFAULT="loglevel=3 quiet"
+GRUB_CMDLINE_LINUX="iommu=pt modprobe.blacklist=nouveau"
+`},
+		{"remove from GRUB_CMDLINE_LINUX", grubRemove("iommu"), `GRUB_CMDLINE_LINUX_DEFAULT="loglevel=3 quiet"
+GRUB_CMDLINE_LINUX="modprobe.blacklist=nouveau"
+`},
+		{"merge into GRUB_CMDLINE_LINUX", grubMergeList("modprobe.blacklist", []string{"ice"}), `GRUB_CMDLINE_LINUX_DEFAULT="loglevel=3 quiet"
+GRUB_CMDLINE_LINUX="iommu=off modprobe.blacklist=nouveau,ice"
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ParseShellConfig([]byte(in))
+			changed, err := editGrubCmdline(cfg, tt.change)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(cfg.Bytes()); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if changed != (tt.want != in) {
+				t.Errorf("changed = %v", changed)
+			}
+		})
+	}
+}
+
+func TestEditGrubCmdlineMovesDuplicates(t *testing.T) {
+	cfg := ParseShellConfig([]byte("GRUB_CMDLINE_LINUX=\"iommu=off\"\nGRUB_CMDLINE_LINUX_DEFAULT=\"quiet iommu=soft modprobe.blacklist=radeon\"\n"))
+	if _, err := editGrubCmdline(cfg, grubSet(ParseParam("iommu=pt"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := editGrubCmdline(cfg, grubMergeList("modprobe.blacklist", []string{"ice"})); err != nil {
+		t.Fatal(err)
+	}
+	want := "GRUB_CMDLINE_LINUX=\"iommu=pt\"\nGRUB_CMDLINE_LINUX_DEFAULT=\"quiet modprobe.blacklist=radeon,ice\"\n"
+	if got := string(cfg.Bytes()); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -1,6 +1,9 @@
 package bootloader
 
-import "context"
+import (
+	"context"
+	"strings"
+)
 
 // Bootloader interface defines operations for boot configuration
 type Bootloader interface {
@@ -10,10 +13,48 @@ type Bootloader interface {
 	// IsInstalled checks if this bootloader is present and active
 	IsInstalled() bool
 
-	// AddParam adds a kernel parameter if it's not already present
+	// ListParams returns the configured kernel command line
+	ListParams(ctx context.Context) (Cmdline, error)
+
+	// AddParam adds a kernel parameter if the exact key=value is not
+	// already present
 	AddParam(ctx context.Context, param string) error
 
+	// SetParam replaces every parameter with the same key, so iommu=off
+	// becomes iommu=pt, adding it if missing
+	SetParam(ctx context.Context, param string) error
+
+	// RemoveParam removes every parameter with the given key
+	RemoveParam(ctx context.Context, key string) error
+
+	// MergeListParam adds items to a comma-separated list such as
+	// modprobe.blacklist, combining repeated keys into one
+	MergeListParam(ctx context.Context, key string, items ...string) error
+
 	// Backup creates a backup of the bootloader configuration
 	// Returns the path to the backup file
 	Backup(ctx context.Context) (string, error)
 }
+
+// editShellCmdline applies edit to the command line held in a shell
+// variable, reporting whether it changed
+func editShellCmdline(cfg *ShellConfig, key string, edit func(*Cmdline) bool) (bool, error) {
+	current, _ := cfg.Get(key)
+	cmdline := ParseCmdline(current)
+	if !edit(&cmdline) {
+		return false, nil
+	}
+	return true, setShellCmdline(cfg, key, current, cmdline)
+}
+
+// setShellCmdline stores an edited command line in a shell variable whose
+// value was current. Pure additions are appended to the last assignment so
+// "+=" lines stay as they are; anything else rewrites the variable in one
+// assignment.
+func setShellCmdline(cfg *ShellConfig, key, current string, cmdline Cmdline) error {
+	updated := cmdline.String()
+	if old := strings.Join(strings.Fields(current), " "); old != "" && strings.HasPrefix(updated, old+" ") {
+		return cfg.AppendWord(key, strings.TrimPrefix(updated, old+" "))
+	}
+	return cfg.Set(key, updated)
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/daveweinstein1/strixforge/pkg/system"
@@ -53,46 +52,59 @@ func (l *Limine) Backup(ctx context.Context) (string, error) {
 	return backupPath, nil
 }
 
-// AddParam adds a parameter to KERNEL_CMDLINE
-func (l *Limine) AddParam(ctx context.Context, param string) error {
-	data, err := os.ReadFile(l.configPath)
-	if err != nil {
-		return fmt.Errorf("failed to read limine config: %v", err)
+// cmdlineKey picks the variable to edit: CachyOS uses the array-style
+// KERNEL_CMDLINE[default], older configs a plain KERNEL_CMDLINE
+func (l *Limine) cmdlineKey(cfg *ShellConfig) string {
+	if !cfg.Has("KERNEL_CMDLINE[default]") && cfg.Has("KERNEL_CMDLINE") {
+		return "KERNEL_CMDLINE"
 	}
-	content := string(data)
+	return "KERNEL_CMDLINE[default]"
+}
 
-	if strings.Contains(content, param) {
-		return nil
+// ListParams returns the kernel command line from KERNEL_CMDLINE
+func (l *Limine) ListParams(ctx context.Context) (Cmdline, error) {
+	cfg, err := LoadShellConfig(l.configPath)
+	if err != nil {
+		return Cmdline{}, err
 	}
+	current, _ := cfg.Get(l.cmdlineKey(cfg))
+	return ParseCmdline(current), nil
+}
 
-	// Strategy: Append to KERNEL_CMDLINE[default]="...
-	// or KERNEL_CMDLINE="... if that's the syntax used (bash array style usually)
-
-	// Default CachyOS config uses: KERNEL_CMDLINE[default]="..."
-	// Or sometimes just KERNEL_CMDLINE="..."
+// AddParam adds a parameter to the kernel command line if not present
+func (l *Limine) AddParam(ctx context.Context, param string) error {
+	return l.edit(ctx, func(c *Cmdline) bool { return c.Add(ParseParam(param)) })
+}
 
-	// We'll look for KERNEL_CMDLINE...="
-	// Safer: just append a new line which bash will use to override/append?
-	// No, that might overwrite.
+// SetParam replaces the parameter's key with the given value
+func (l *Limine) SetParam(ctx context.Context, param string) error {
+	return l.edit(ctx, func(c *Cmdline) bool { return c.Set(ParseParam(param)) })
+}
 
-	// Sed replace strategy:
-	// Insert param inside the first KERNEL_CMDLINE.*=" quote
-	// sed -i 's/KERNEL_CMDLINE.*="/&param /'
+// RemoveParam removes every parameter with the given key
+func (l *Limine) RemoveParam(ctx context.Context, key string) error {
+	return l.edit(ctx, func(c *Cmdline) bool { return c.Remove(key) })
+}
 
-	sedCmd := fmt.Sprintf(`sed -i 's/KERNEL_CMDLINE.*="/&%s /'`, param)
+// MergeListParam adds items to a comma-separated list parameter
+func (l *Limine) MergeListParam(ctx context.Context, key string, items ...string) error {
+	return l.edit(ctx, func(c *Cmdline) bool { return c.MergeList(key, items...) })
+}
 
-	// NOTE: This assumes the lines exists. If commented out, we must uncomment.
-	if strings.Contains(content, "#KERNEL_CMDLINE") || strings.Contains(content, "# KERNEL_CMDLINE") {
-		// Try to uncomment
-		uncommentCmd := `sed -i 's/^#\s*KERNEL_CMDLINE/KERNEL_CMDLINE/' ` + l.configPath
-		system.ExecShellSudo(ctx, uncommentCmd)
+// edit applies a change to the command line, writing the config and
+// regenerating boot entries only if it changed
+func (l *Limine) edit(ctx context.Context, change func(*Cmdline) bool) error {
+	cfg, err := LoadShellConfig(l.configPath)
+	if err != nil {
+		return err
 	}
-
-	cmd := fmt.Sprintf("%s %s", sedCmd, l.configPath)
-	if _, err := system.ExecShellSudo(ctx, cmd); err != nil {
-		return fmt.Errorf("failed to add param to limine: %v", err)
+	changed, err := editShellCmdline(cfg, l.cmdlineKey(cfg), change)
+	if err != nil || !changed {
+		return err
+	}
+	if err := saveConfig(ctx, l.configPath, cfg.Bytes()); err != nil {
+		return err
 	}
-
 	return l.update(ctx)
 }
 
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/daveweinstein1/strixforge/pkg/system"
@@ -45,37 +44,66 @@ func (r *Refind) Backup(ctx context.Context) (string, error) {
 	return backupPath, nil
 }
 
-// AddParam adds a parameter to the default boot options
-func (r *Refind) AddParam(ctx context.Context, param string) error {
-	data, err := os.ReadFile(r.configPath)
+// ListParams returns the options of the first boot entry, the one rEFInd
+// boots by default
+func (r *Refind) ListParams(ctx context.Context) (Cmdline, error) {
+	cfg, err := LoadRefindConfig(r.configPath)
 	if err != nil {
-		return fmt.Errorf("failed to read refind config: %v", err)
+		return Cmdline{}, err
 	}
-	content := string(data)
-
-	if strings.Contains(content, param) {
-		return nil
+	entries := cfg.Entries()
+	if len(entries) == 0 {
+		return Cmdline{}, fmt.Errorf("no boot entries found in %s", r.configPath)
 	}
+	return ParseCmdline(entries[0].Options), nil
+}
 
-	// Strategy: refind_linux.conf format:
-	// "Description" "loader options..."
-	// We want to target the first entry or entries containing "default" or "Default"?
-	// Or just append to all lines? Usually safe to append to all lines in this file.
+// AddParam adds a parameter to every boot entry's options
+func (r *Refind) AddParam(ctx context.Context, param string) error {
+	return r.edit(ctx, func(c *Cmdline) bool { return c.Add(ParseParam(param)) })
+}
 
-	// Better yet: "Boot using default options" is the standard generated line.
-	// sed -i 's/"$/ param"/' to append BEFORE the closing quote of options?
-	// The file format is:
-	// "Title"  "options"
+// SetParam replaces the parameter's key in every boot entry
+func (r *Refind) SetParam(ctx context.Context, param string) error {
+	return r.edit(ctx, func(c *Cmdline) bool { return c.Set(ParseParam(param)) })
+}
 
-	// So we need to match the SECOND quoted string of each line.
-	// sed 's/"$/ param"/' applies to end of line, which is usually the closing quote of the options.
+// RemoveParam removes every parameter with the given key from every entry
+func (r *Refind) RemoveParam(ctx context.Context, key string) error {
+	return r.edit(ctx, func(c *Cmdline) bool { return c.Remove(key) })
+}
 
-	sedCmd := fmt.Sprintf(`sed -i 's/"$/ %s"/' %s`, param, r.configPath)
+// MergeListParam adds items to a comma-separated list parameter in every
+// entry
+func (r *Refind) MergeListParam(ctx context.Context, key string, items ...string) error {
+	return r.edit(ctx, func(c *Cmdline) bool { return c.MergeList(key, items...) })
+}
 
-	result, err := system.ExecShellSudo(ctx, sedCmd)
+// edit applies a change to each entry's options, as each one is a way of
+// booting the same kernel, and writes the file if any changed
+func (r *Refind) edit(ctx context.Context, change func(*Cmdline) bool) error {
+	cfg, err := LoadRefindConfig(r.configPath)
 	if err != nil {
-		return fmt.Errorf("failed to add param to refind: %s\n%s", err, result.Stderr)
+		return err
 	}
-
-	return nil // No update command needed for rEFInd, it reads config at boot
+	entries := cfg.Entries()
+	if len(entries) == 0 {
+		return fmt.Errorf("no boot entries found in %s", r.configPath)
+	}
+	changed := false
+	for _, e := range entries {
+		cmdline := ParseCmdline(e.Options)
+		if !change(&cmdline) {
+			continue
+		}
+		if err := e.SetOptions(cmdline.String()); err != nil {
+			return err
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	// No update command needed for rEFInd, it reads config at boot
+	return saveConfig(ctx, r.configPath, cfg.Bytes())
 }
@@ -0,0 +1,146 @@
+package bootloader
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RefindConfig is a parsed refind_linux.conf: one boot entry per line, as a
+// title and an options string, each quoted or a bare word. Comments, blank
+// lines and spacing are kept byte for byte; only changed options are
+// rewritten.
+type RefindConfig struct {
+	lines []refindLine
+}
+
+// refindLine is a line of the file, an entry if options were found
+type refindLine struct {
+	text  string // the whole line, used when entry is nil
+	entry *RefindEntry
+}
+
+// RefindEntry is one "Title" "options" line
+type RefindEntry struct {
+	Title   string
+	Options string
+
+	before string // everything up to the options token
+	token  string // the options exactly as written
+	after  string // the rest of the line, including the newline
+	dirty  bool
+}
+
+// render writes the entry back, verbatim unless its options changed
+func (e *RefindEntry) render() string {
+	token := e.token
+	if e.dirty {
+		token = `"` + e.Options + `"`
+	}
+	return e.before + token + e.after
+}
+
+// ParseRefindConfig parses refind_linux.conf content
+func ParseRefindConfig(data []byte) *RefindConfig {
+	c := &RefindConfig{}
+	s := string(data)
+	for len(s) > 0 {
+		end := strings.IndexByte(s, '\n') + 1
+		if end == 0 {
+			end = len(s)
+		}
+		line := s[:end]
+		s = s[end:]
+		c.lines = append(c.lines, refindLine{text: line, entry: parseRefindEntry(line)})
+	}
+	return c
+}
+
+// parseRefindEntry reads the title and options of a line, or returns nil
+// for comments, blank lines and lines without options
+func parseRefindEntry(line string) *RefindEntry {
+	trimmed := strings.TrimLeft(line, " \t")
+	if trimmed == "" || trimmed[0] == '#' || trimmed[0] == '\n' {
+		return nil
+	}
+	start := len(line) - len(trimmed)
+	title, titleEnd, ok := refindToken(line, start)
+	if !ok {
+		return nil
+	}
+	optStart := titleEnd + len(line[titleEnd:]) - len(strings.TrimLeft(line[titleEnd:], " \t"))
+	options, optEnd, ok := refindToken(line, optStart)
+	if !ok || optStart == optEnd {
+		return nil
+	}
+	return &RefindEntry{
+		Title:   title,
+		Options: options,
+		before:  line[:optStart],
+		token:   line[optStart:optEnd],
+		after:   line[optEnd:],
+	}
+}
+
+// refindToken reads a quoted string or bare word at start. rEFInd has no
+// escapes, so a quoted string ends at the next quote.
+func refindToken(line string, start int) (string, int, bool) {
+	if start >= len(line) {
+		return "", start, false
+	}
+	if line[start] == '"' {
+		end := strings.IndexByte(line[start+1:], '"')
+		if end < 0 {
+			return "", 0, false
+		}
+		return line[start+1 : start+1+end], start + end + 2, true
+	}
+	end := strings.IndexAny(line[start:], " \t\r\n")
+	if end < 0 {
+		end = len(line) - start
+	}
+	return line[start : start+end], start + end, true
+}
+
+// LoadRefindConfig reads and parses a refind_linux.conf file
+func LoadRefindConfig(path string) (*RefindConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return ParseRefindConfig(data), nil
+}
+
+// Bytes renders the file
+func (c *RefindConfig) Bytes() []byte {
+	var b strings.Builder
+	for _, line := range c.lines {
+		if line.entry != nil {
+			b.WriteString(line.entry.render())
+		} else {
+			b.WriteString(line.text)
+		}
+	}
+	return []byte(b.String())
+}
+
+// Entries returns the boot entries in file order
+func (c *RefindConfig) Entries() []*RefindEntry {
+	var entries []*RefindEntry
+	for _, line := range c.lines {
+		if line.entry != nil {
+			entries = append(entries, line.entry)
+		}
+	}
+	return entries
+}
+
+// SetOptions replaces an entry's options. rEFInd cannot escape quotes, so
+// options containing one are refused.
+func (e *RefindEntry) SetOptions(options string) error {
+	if strings.ContainsAny(options, "\"\n") {
+		return fmt.Errorf("rEFInd options cannot contain quotes or newlines: %s", options)
+	}
+	e.Options, e.dirty = options, true
+	return nil
+}
@@ -0,0 +1,67 @@
+package bootloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRefindConfigGolden(t *testing.T) {
+	in, err := os.ReadFile(filepath.Join("testdata", "refind_linux.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := ParseRefindConfig(in)
+	if got := cfg.Bytes(); string(got) != string(in) {
+		t.Fatalf("round trip changed the file:\n%s", got)
+	}
+
+	entries := cfg.Entries()
+	wantTitles := []string{"Boot with standard options", "Boot to single-user mode", "Minimal", "Boot with minimal options"}
+	if len(entries) != len(wantTitles) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(wantTitles))
+	}
+	for i, e := range entries {
+		if e.Title != wantTitles[i] {
+			t.Errorf("entry %d title = %q, want %q", i, e.Title, wantTitles[i])
+		}
+	}
+	if got, want := entries[2].Options, "root=UUID=6f1d-42"; got != want {
+		t.Errorf("bare options = %q, want %q", got, want)
+	}
+
+	for _, e := range entries {
+		if err := e.SetOptions(e.Options + " iommu=pt"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkGolden(t, "refind_linux.conf", cfg.Bytes())
+}
+
+func TestRefindConfigRoundTrip(t *testing.T) {
+	for _, in := range []string{
+		"",
+		`"Title" "opts"`, // no trailing newline
+		"\"Only a title\"\n",
+		"\"Unterminated \"opts\n",
+		"  # indented comment\n",
+		"\"Title\"\t\t\"opts\"\r\n",
+	} {
+		if got := string(ParseRefindConfig([]byte(in)).Bytes()); got != in {
+			t.Errorf("round trip of %q = %q", in, got)
+		}
+	}
+}
+
+func TestRefindSetOptionsRefusesQuotes(t *testing.T) {
+	cfg := ParseRefindConfig([]byte("\"Title\" \"quiet\"\n"))
+	e := cfg.Entries()[0]
+	for _, options := range []string{`dyndbg="a b"`, "quiet\nsplash"} {
+		if err := e.SetOptions(options); err == nil {
+			t.Errorf("SetOptions(%q) succeeded", options)
+		}
+	}
+	if got := string(cfg.Bytes()); got != "\"Title\" \"quiet\"\n" {
+		t.Errorf("refused edit changed the file to %q", got)
+	}
+}
@@ -0,0 +1,357 @@
+package bootloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/daveweinstein1/strixforge/pkg/system"
+)
+
+// assignmentRe matches the start of a shell variable assignment, possibly
+// commented out: indentation and "#", "export", the name with an optional
+// array index such as KERNEL_CMDLINE[default], and "=" or "+="
+var assignmentRe = regexp.MustCompile(`^([ \t]*(?:#[ \t]*)?)(export[ \t]+)?([A-Za-z_][A-Za-z0-9_]*(?:\[[^\]\n]*\])?)(\+?=)`)
+
+// bareValueRe matches values that need no quoting
+var bareValueRe = regexp.MustCompile(`^[A-Za-z0-9_./:,=+@%-]+$`)
+
+// ShellConfig is a file of shell variable assignments such as
+// /etc/default/grub, /etc/sdboot-manage.conf or /etc/default/limine.
+// Untouched parts are kept byte for byte; only the value of an assignment
+// that is changed is rewritten, in its original quoting style where
+// possible.
+type ShellConfig struct {
+	parts []shellPart
+}
+
+// shellPart is either verbatim text or one assignment
+type shellPart struct {
+	text   string
+	assign *ShellAssignment
+}
+
+// ShellAssignment is one NAME=value or NAME+=value line
+type ShellAssignment struct {
+	Key       string // including any array index
+	Append    bool   // "+=": appends to the previous value
+	Value     string // with quoting and escapes removed
+	Commented bool
+
+	indent  string // whitespace before the key, or before "#"
+	comment string // "#" and the whitespace after it, when commented
+	export  string
+	token   string // the value exactly as written
+	suffix  string // the rest of the line, including the newline
+	quote   byte   // '"', '\'' or 0 for a bare word
+	expands bool   // the value references variables or commands
+	dirty   bool
+}
+
+// render writes the assignment back, verbatim unless it was changed
+func (a *ShellAssignment) render() string {
+	op := "="
+	if a.Append {
+		op = "+="
+	}
+	lead := a.indent
+	if a.Commented {
+		lead += a.comment
+	}
+	token := a.token
+	if a.dirty {
+		token = shellQuote(a.Value, a.quote)
+	}
+	return lead + a.export + a.Key + op + token + a.suffix
+}
+
+// ParseShellConfig parses shell variable assignments. Lines that are not
+// assignments, including comments, are kept as text.
+func ParseShellConfig(data []byte) *ShellConfig {
+	s := string(data)
+	c := &ShellConfig{}
+	for i := 0; i < len(s); {
+		lineEnd := strings.IndexByte(s[i:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(s)
+		} else {
+			lineEnd += i + 1
+		}
+
+		if a, end, ok := parseAssignment(s, i, lineEnd); ok {
+			c.parts = append(c.parts, shellPart{assign: a})
+			i = end
+			continue
+		}
+		c.addText(s[i:lineEnd])
+		i = lineEnd
+	}
+	return c
+}
+
+// parseAssignment reads an assignment starting at i. Quoted values may
+// continue over several lines, except in commented-out assignments. It
+// returns the assignment and the offset just past its line.
+func parseAssignment(s string, i, lineEnd int) (*ShellAssignment, int, bool) {
+	m := assignmentRe.FindStringSubmatchIndex(s[i:lineEnd])
+	if m == nil {
+		return nil, 0, false
+	}
+	lead := s[i+m[2] : i+m[3]]
+	a := &ShellAssignment{
+		Key:    s[i+m[6] : i+m[7]],
+		Append: s[i+m[8]:i+m[9]] == "+=",
+	}
+	if hash := strings.IndexByte(lead, '#'); hash >= 0 {
+		a.Commented = true
+		a.indent, a.comment = lead[:hash], lead[hash:]
+	} else {
+		a.indent = lead
+	}
+	if m[4] >= 0 {
+		a.export = s[i+m[4] : i+m[5]]
+	}
+
+	start := i + m[1]
+	limit := len(s)
+	if a.Commented {
+		limit = lineEnd
+	}
+	end, ok := scanShellWord(s[:limit], start, a)
+	if !ok {
+		return nil, 0, false
+	}
+	a.token = s[start:end]
+
+	next := strings.IndexByte(s[end:], '\n')
+	if next < 0 {
+		next = len(s)
+	} else {
+		next += end + 1
+	}
+	a.suffix = s[end:next]
+	return a, next, true
+}
+
+// scanShellWord reads one shell word from s[start:], storing its unquoted
+// value and quoting style in a. It returns the offset after the word, or
+// false if a quote is never closed.
+func scanShellWord(s string, start int, a *ShellAssignment) (int, bool) {
+	var value strings.Builder
+	i := start
+	for i < len(s) {
+		switch ch := s[i]; {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == ';':
+			a.Value = value.String()
+			return i, true
+		case ch == '\'':
+			a.setQuote(ch)
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return 0, false
+			}
+			value.WriteString(s[i+1 : i+1+end])
+			i += end + 2
+		case ch == '"':
+			a.setQuote(ch)
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				switch {
+				case s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0:
+					if s[i+1] != '\n' {
+						value.WriteByte(s[i+1])
+					}
+					i++
+				case s[i] == '$' || s[i] == '`':
+					a.expands = true
+					value.WriteByte(s[i])
+				default:
+					value.WriteByte(s[i])
+				}
+			}
+			if i >= len(s) {
+				return 0, false
+			}
+			i++
+		case ch == '\\' && i+1 < len(s):
+			value.WriteByte(s[i+1])
+			i += 2
+		default:
+			if ch == '$' || ch == '`' {
+				a.expands = true
+			}
+			value.WriteByte(ch)
+			i++
+		}
+	}
+	a.Value = value.String()
+	return i, true
+}
+
+// setQuote records the first quote character a value uses
+func (a *ShellAssignment) setQuote(ch byte) {
+	if a.quote == 0 {
+		a.quote = ch
+	}
+}
+
+// shellQuote quotes a value in the preferred style, falling back to double
+// quotes when the style cannot represent it
+func shellQuote(value string, style byte) string {
+	switch {
+	case style == 0 && bareValueRe.MatchString(value):
+		return value
+	case style == '\'' && !strings.Contains(value, "'"):
+		return "'" + value + "'"
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+	return `"` + r.Replace(value) + `"`
+}
+
+// addText appends verbatim text, merging it with preceding text
+func (c *ShellConfig) addText(text string) {
+	if n := len(c.parts); n > 0 && c.parts[n-1].assign == nil {
+		c.parts[n-1].text += text
+		return
+	}
+	c.parts = append(c.parts, shellPart{text: text})
+}
+
+// LoadShellConfig reads and parses a shell variable file
+func LoadShellConfig(path string) (*ShellConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return ParseShellConfig(data), nil
+}
+
+// Bytes renders the file
+func (c *ShellConfig) Bytes() []byte {
+	var b strings.Builder
+	for _, part := range c.parts {
+		if part.assign != nil {
+			b.WriteString(part.assign.render())
+		} else {
+			b.WriteString(part.text)
+		}
+	}
+	return []byte(b.String())
+}
+
+// Assignments returns the active assignments to key in file order
+func (c *ShellConfig) Assignments(key string) []*ShellAssignment {
+	var out []*ShellAssignment
+	for _, part := range c.parts {
+		if a := part.assign; a != nil && a.Key == key && !a.Commented {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// Get returns a variable's value as the shell would see it: the last "="
+// followed by any "+=" after it
+func (c *ShellConfig) Get(key string) (string, bool) {
+	assignments := c.Assignments(key)
+	if len(assignments) == 0 {
+		return "", false
+	}
+	var value string
+	for _, a := range assignments {
+		if a.Append {
+			value += a.Value
+		} else {
+			value = a.Value
+		}
+	}
+	return value, true
+}
+
+// Has reports whether the file assigns key, even if commented out
+func (c *ShellConfig) Has(key string) bool {
+	for _, part := range c.parts {
+		if part.assign != nil && part.assign.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// editable returns the assignment an edit to key should change: the last
+// active one, else the last commented-out one, uncommented, else a new
+// line at the end of the file
+func (c *ShellConfig) editable(key string) (*ShellAssignment, error) {
+	var last, commented *ShellAssignment
+	for _, part := range c.parts {
+		if a := part.assign; a != nil && a.Key == key {
+			if a.Commented {
+				commented = a
+			} else {
+				last = a
+			}
+		}
+	}
+	switch {
+	case last != nil:
+	case commented != nil:
+		last = commented
+		last.Commented = false
+		last.dirty = true
+		// Prose after a commented-out value would become a command
+		if rest := strings.TrimSpace(last.suffix); rest != "" && !strings.HasPrefix(rest, "#") {
+			last.suffix = "\n"
+		}
+	default:
+		if len(c.parts) > 0 && !strings.HasSuffix(string(c.Bytes()), "\n") {
+			c.addText("\n")
+		}
+		last = &ShellAssignment{Key: key, quote: '"', suffix: "\n", dirty: true}
+		c.parts = append(c.parts, shellPart{assign: last})
+	}
+	if last.expands {
+		return nil, fmt.Errorf("%s refers to other variables or commands; edit it by hand", key)
+	}
+	return last, nil
+}
+
+// Set makes key equal value. The last assignment is rewritten as a plain
+// "=", so earlier ones no longer matter but are left as they are.
+func (c *ShellConfig) Set(key, value string) error {
+	a, err := c.editable(key)
+	if err != nil {
+		return err
+	}
+	a.Value, a.Append, a.dirty = value, false, true
+	return nil
+}
+
+// AppendWord adds a space-separated word to the end of key's value by
+// extending its last assignment
+func (c *ShellConfig) AppendWord(key, word string) error {
+	a, err := c.editable(key)
+	if err != nil {
+		return err
+	}
+	// A "+=" concatenates directly, so it needs the space even when empty
+	if (a.Value != "" || a.Append) && !strings.HasSuffix(a.Value, " ") {
+		a.Value += " "
+	}
+	a.Value += word
+	a.dirty = true
+	return nil
+}
+
+// saveConfig writes an edited config back as root, keeping its mode
+func saveConfig(ctx context.Context, path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := system.WriteFileSudo(ctx, path, data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
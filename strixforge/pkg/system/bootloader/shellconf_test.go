@@ -0,0 +1,197 @@
+package bootloader
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// checkGolden compares got with testdata/name.golden
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// shellEdit is a Set or AppendWord call on a ShellConfig
+type shellEdit struct {
+	key, value string
+	append     bool
+}
+
+func TestShellConfigGolden(t *testing.T) {
+	tests := []struct {
+		file  string
+		edits []shellEdit
+	}{
+		{"grub", []shellEdit{
+			{key: "GRUB_CMDLINE_LINUX_DEFAULT", value: "loglevel=3 quiet iommu=pt"},
+			{key: "GRUB_CMDLINE_LINUX", value: "amd_pstate=active", append: true},
+			{key: "GRUB_PRELOAD_MODULES", value: "part_gpt"},
+			{key: "GRUB_TIMEOUT", value: "1"},
+			{key: "GRUB_DISABLE_OS_PROBER", value: "false"}, // uncommented
+			{key: "GRUB_GFXMODE", value: "1920x1080 auto"},  // added at the end
+		}},
+		{"sdboot-manage.conf", []shellEdit{
+			{key: "LINUX_OPTIONS", value: "iommu=pt", append: true},
+			{key: "REMOVE_OBSOLETE", value: "yes"},
+		}},
+		{"limine", []shellEdit{
+			{key: "KERNEL_CMDLINE[default]", value: "iommu=pt", append: true},
+			{key: "ENABLE_UKI", value: "no"},
+			{key: "ESP_PATH", value: `/efi "main"`},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			in, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := ParseShellConfig(in)
+			if got := cfg.Bytes(); string(got) != string(in) {
+				t.Fatalf("round trip changed the file:\n%s", got)
+			}
+			for _, e := range tt.edits {
+				if e.append {
+					err = cfg.AppendWord(e.key, e.value)
+				} else {
+					err = cfg.Set(e.key, e.value)
+				}
+				if err != nil {
+					t.Fatalf("editing %s: %v", e.key, err)
+				}
+			}
+			checkGolden(t, tt.file, cfg.Bytes())
+		})
+	}
+}
+
+func TestShellConfigGet(t *testing.T) {
+	in, err := os.ReadFile(filepath.Join("testdata", "limine"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := ParseShellConfig(in)
+	tests := []struct {
+		key   string
+		want  string
+		found bool
+	}{
+		{"KERNEL_CMDLINE[default]", "root=UUID=6f1d-42 rw rootflags=subvol=/@ quiet splash", true},
+		{"KERNEL_CMDLINE[linux-cachyos-lts]", "", false}, // commented out
+		{"ENABLE_UKI", "yes", true},
+		{"BOOT_ORDER", "", false},
+	}
+	for _, tt := range tests {
+		got, found := cfg.Get(tt.key)
+		if got != tt.want || found != tt.found {
+			t.Errorf("Get(%q) = %q, %v; want %q, %v", tt.key, got, found, tt.want, tt.found)
+		}
+	}
+	if !cfg.Has("BOOT_ORDER") {
+		t.Error("Has(BOOT_ORDER) = false for a commented-out assignment")
+	}
+}
+
+func TestShellConfigRoundTrip(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"KEY=value",            // no trailing newline
+		"A=1; B=2\n",           // two on a line
+		"KEY=\"unterminated\n", // kept as text
+		"  export KEY='a b' # c\n",
+		"KEY=a\\ b\"c\"'d'\n",      // mixed quoting
+		"# KEY=\"spans\nlines\"\n", // commented values end at the line
+	} {
+		if got := string(ParseShellConfig([]byte(in)).Bytes()); got != in {
+			t.Errorf("round trip of %q = %q", in, got)
+		}
+	}
+}
+
+func TestShellConfigUncomment(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"keeps the value style", "#KEY='old'\n", "KEY='new'\n"},
+		{"keeps a trailing comment", "# KEY=old # why\n", "KEY=new # why\n"},
+		{"drops trailing prose", "#KEY=old  to enable this\n", "KEY=new\n"},
+		{"prefers an active assignment", "KEY=old\n#KEY=other\n", "KEY=new\n#KEY=other\n"},
+		{"uses the last commented", "#KEY=a\n#KEY=b\n", "#KEY=a\nKEY=new\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ParseShellConfig([]byte(tt.in))
+			if err := cfg.Set("KEY", "new"); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(cfg.Bytes()); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShellConfigAppendWord(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"to a value", "KEY=\"a\"\n", "KEY=\"a b\"\n"},
+		{"to an empty value", "KEY=\"\"\n", "KEY=\"b\"\n"},
+		{"to an empty +=", "KEY=\"a\"\nKEY+=\"\"\n", "KEY=\"a\"\nKEY+=\" b\"\n"},
+		{"adds a line", "OTHER=1", "OTHER=1\nKEY=\"b\"\n"},
+		{"bare becomes quoted", "KEY=a\n", "KEY=\"a b\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ParseShellConfig([]byte(tt.in))
+			if err := cfg.AppendWord("KEY", "b"); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(cfg.Bytes()); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShellConfigRefusesExpansions(t *testing.T) {
+	for _, in := range []string{
+		"KEY=\"$BASE quiet\"\n",
+		"KEY=$(cat /etc/cmdline)\n",
+		"KEY=\"`cat /etc/cmdline`\"\n",
+	} {
+		cfg := ParseShellConfig([]byte(in))
+		err := cfg.AppendWord("KEY", "iommu=pt")
+		if err == nil || !strings.Contains(err.Error(), "edit it by hand") {
+			t.Errorf("AppendWord on %q: err = %v, want a refusal", in, err)
+		}
+		if got := string(cfg.Bytes()); got != in {
+			t.Errorf("refused edit changed %q to %q", in, got)
+		}
+	}
+	// Escaped dollars are literal, not expansions
+	cfg := ParseShellConfig([]byte("KEY=\"a\\$b\"\n"))
+	if err := cfg.AppendWord("KEY", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(cfg.Bytes()), "KEY=\"a\\$b c\"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/daveweinstein1/strixforge/pkg/system"
@@ -57,58 +56,49 @@ func (s *SystemdBoot) Backup(ctx context.Context) (string, error) {
 	return backupPath, nil
 }
 
-// AddParam adds a parameter to LINUX_OPTIONS
-func (s *SystemdBoot) AddParam(ctx context.Context, param string) error {
-	// Read file
-	data, err := os.ReadFile(s.configPath)
+// ListParams returns the kernel command line from LINUX_OPTIONS
+func (s *SystemdBoot) ListParams(ctx context.Context) (Cmdline, error) {
+	cfg, err := LoadShellConfig(s.configPath)
 	if err != nil {
-		return fmt.Errorf("failed to read sdboot-manage config: %v", err)
+		return Cmdline{}, err
 	}
-	content := string(data)
+	current, _ := cfg.Get("LINUX_OPTIONS")
+	return ParseCmdline(current), nil
+}
 
-	// Check if param already exists
-	// Note: simplistic check, but safe enough for "iommu=pt" etc.
-	if strings.Contains(content, param) {
-		return nil
-	}
+// AddParam adds a parameter to the kernel command line if not present
+func (s *SystemdBoot) AddParam(ctx context.Context, param string) error {
+	return s.edit(ctx, func(c *Cmdline) bool { return c.Add(ParseParam(param)) })
+}
+
+// SetParam replaces the parameter's key with the given value
+func (s *SystemdBoot) SetParam(ctx context.Context, param string) error {
+	return s.edit(ctx, func(c *Cmdline) bool { return c.Set(ParseParam(param)) })
+}
 
-	// We look for LINUX_OPTIONS="..."
-	// If it doesn't exist, we append it. If it does, we assume sed will handle it.
-	// Actually for simplicity let's use sed to append inside the quotes
-	// match: LINUX_OPTIONS="...
-	// replace: LINUX_OPTIONS="... param "
-
-	// Since we don't have robust config parsing, we'll try to use sed carefully
-	// Pattern: Find LINUX_OPTIONS="... and make sure we don't break the closing quote
-	// Actually, easier strategy:
-	// 1. If LINUX_OPTIONS detected, use sed substitution to append inside last quote
-	// 2. If not detected, append new line
-
-	if strings.Contains(content, "LINUX_OPTIONS=") {
-		// Append to existing options
-		// sed -i 's/^LINUX_OPTIONS="/LINUX_OPTIONS="param /'
-		// Note: sdboot-manage usually has LINUX_OPTIONS="" empty by default or commented?
-		// Default in cachyos config checks... it's usually LINUX_OPTIONS=""
-
-		// Let's use a safer approach: append the param to the beginning of the string inside quotes
-		// This handles the empty case LINUX_OPTIONS="" -> LINUX_OPTIONS="param "
-		sedCmd := fmt.Sprintf(`sed -i 's/^LINUX_OPTIONS="/LINUX_OPTIONS="%s /'`, param)
-
-		// Wait, if line is commented out? We should uncomment it.
-		// sed -i 's/^#LINUX_OPTIONS=/LINUX_OPTIONS=/'
-		uncommentCmd := `sed -i 's/^#LINUX_OPTIONS=/LINUX_OPTIONS=/' ` + s.configPath
-		system.ExecShellSudo(ctx, uncommentCmd)
-
-		cmd := fmt.Sprintf("%s %s", sedCmd, s.configPath)
-		if _, err := system.ExecShellSudo(ctx, cmd); err != nil {
-			return fmt.Errorf("failed to add param to systemd-boot: %v", err)
-		}
-	} else {
-		// perform append
-		newLine := fmt.Sprintf(`echo 'LINUX_OPTIONS="%s"' | sudo tee -a %s`, param, s.configPath)
-		if _, err := system.ExecShellSudo(ctx, newLine); err != nil {
-			return fmt.Errorf("failed to append config: %v", err)
-		}
+// RemoveParam removes every parameter with the given key
+func (s *SystemdBoot) RemoveParam(ctx context.Context, key string) error {
+	return s.edit(ctx, func(c *Cmdline) bool { return c.Remove(key) })
+}
+
+// MergeListParam adds items to a comma-separated list parameter
+func (s *SystemdBoot) MergeListParam(ctx context.Context, key string, items ...string) error {
+	return s.edit(ctx, func(c *Cmdline) bool { return c.MergeList(key, items...) })
+}
+
+// edit applies a change to the command line, writing the config and
+// regenerating boot entries only if it changed
+func (s *SystemdBoot) edit(ctx context.Context, change func(*Cmdline) bool) error {
+	cfg, err := LoadShellConfig(s.configPath)
+	if err != nil {
+		return err
+	}
+	changed, err := editShellCmdline(cfg, "LINUX_OPTIONS", change)
+	if err != nil || !changed {
+		return err
+	}
+	if err := saveConfig(ctx, s.configPath, cfg.Bytes()); err != nil {
+		return err
 	}
 
 	// Regenerate entries
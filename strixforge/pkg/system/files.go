@@ -0,0 +1,102 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CopyFile copies src to dst, preserving the file mode
+func CopyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s: %v", src, err)
+	}
+	return out.Close()
+}
+
+// CopyDir recursively copies the contents of src into dst
+func CopyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return CopyFile(path, target)
+	})
+}
+
+// FileExists checks if a regular file exists at path
+func FileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// BackupFileSudo copies a root-owned file to a timestamped backup next to it
+func BackupFileSudo(ctx context.Context, path string) (string, error) {
+	timestamp := time.Now().Format("20060102-150405")
+	backupPath := fmt.Sprintf("%s.backup-%s", path, timestamp)
+
+	result, err := ExecSudo(ctx, "cp", "-p", path, backupPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to backup %s: %s\n%s", path, err, result.Stderr)
+	}
+	return backupPath, nil
+}
+
+// WriteFileSudo atomically replaces a root-owned file. The content is staged
+// next to the target and renamed over it, so readers never see a partial file.
+func WriteFileSudo(ctx context.Context, path string, data []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp("", "strixforge-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	staged := path + ".strixforge-new"
+	result, err := ExecSudo(ctx, "install", "-m", fmt.Sprintf("%04o", mode.Perm()), tmp.Name(), staged)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %s\n%s", path, err, result.Stderr)
+	}
+	result, err = ExecSudo(ctx, "mv", "-f", staged, path)
+	if err != nil {
+		return fmt.Errorf("failed to replace %s: %s\n%s", path, err, result.Stderr)
+	}
+	return nil
+}
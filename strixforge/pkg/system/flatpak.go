@@ -0,0 +1,132 @@
+package system
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Flathub is the default Flatpak remote and its repository file
+const (
+	FlathubRemote = "flathub"
+	FlathubURL    = "https://dl.flathub.org/repo/flathub.flatpakrepo"
+)
+
+// FlatpakApp is an installed Flatpak application
+type FlatpakApp struct {
+	ID      string
+	Name    string
+	Version string
+	Origin  string
+}
+
+// Flatpak manages system-wide Flatpak apps and remotes
+type Flatpak struct{}
+
+// NewFlatpak creates a new Flatpak instance
+func NewFlatpak() *Flatpak {
+	return &Flatpak{}
+}
+
+// Available checks if the flatpak command is installed
+func (f *Flatpak) Available() bool {
+	return CheckCommand("flatpak")
+}
+
+// Remotes returns the configured system remotes
+func (f *Flatpak) Remotes(ctx context.Context) ([]string, error) {
+	result, err := Exec(ctx, "flatpak", "remotes", "--system", "--columns=name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list flatpak remotes: %s\n%s", err, result.Stderr)
+	}
+	return strings.Fields(result.Stdout), nil
+}
+
+// HasRemote checks if a system remote is configured
+func (f *Flatpak) HasRemote(ctx context.Context, name string) bool {
+	remotes, err := f.Remotes(ctx)
+	return err == nil && containsString(remotes, name)
+}
+
+// AddRemote adds a system remote from a .flatpakrepo URL if it is missing
+func (f *Flatpak) AddRemote(ctx context.Context, name, url string) error {
+	result, err := ExecSudo(ctx, "flatpak", "remote-add", "--system", "--if-not-exists", name, url)
+	if err != nil {
+		return fmt.Errorf("failed to add flatpak remote %s: %s\n%s", name, err, result.Stderr)
+	}
+	return nil
+}
+
+// RemoveRemote removes a system remote
+func (f *Flatpak) RemoveRemote(ctx context.Context, name string) error {
+	result, err := ExecSudo(ctx, "flatpak", "remote-delete", "--system", name)
+	if err != nil {
+		return fmt.Errorf("failed to remove flatpak remote %s: %s\n%s", name, err, result.Stderr)
+	}
+	return nil
+}
+
+// Install installs apps system-wide from a remote
+func (f *Flatpak) Install(ctx context.Context, remote string, apps ...string) error {
+	args := append([]string{"install", "--system", "--noninteractive", "-y", remote}, apps...)
+	result, err := ExecSudo(ctx, "flatpak", args...)
+	if err != nil {
+		return fmt.Errorf("flatpak install failed: %s\n%s", err, result.Stderr)
+	}
+	return nil
+}
+
+// Update updates the given apps, or everything if none are given
+func (f *Flatpak) Update(ctx context.Context, apps ...string) error {
+	args := append([]string{"update", "--system", "--noninteractive", "-y"}, apps...)
+	result, err := ExecSudo(ctx, "flatpak", args...)
+	if err != nil {
+		return fmt.Errorf("flatpak update failed: %s\n%s", err, result.Stderr)
+	}
+	return nil
+}
+
+// Uninstall removes apps
+func (f *Flatpak) Uninstall(ctx context.Context, apps ...string) error {
+	args := append([]string{"uninstall", "--system", "--noninteractive", "-y"}, apps...)
+	result, err := ExecSudo(ctx, "flatpak", args...)
+	if err != nil {
+		return fmt.Errorf("flatpak uninstall failed: %s\n%s", err, result.Stderr)
+	}
+	return nil
+}
+
+// List returns installed system applications (runtimes are omitted)
+func (f *Flatpak) List(ctx context.Context) ([]FlatpakApp, error) {
+	result, err := Exec(ctx, "flatpak", "list", "--system", "--app", "--columns=application,name,version,origin")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list flatpaks: %s\n%s", err, result.Stderr)
+	}
+	return parseFlatpakList(result.Stdout), nil
+}
+
+// IsInstalled checks if an app is installed system-wide
+func (f *Flatpak) IsInstalled(ctx context.Context, app string) bool {
+	result, err := Exec(ctx, "flatpak", "info", "--system", app)
+	return err == nil && result.ExitCode == 0
+}
+
+// parseFlatpakList parses tab-separated `flatpak list --columns` output
+func parseFlatpakList(output string) []FlatpakApp {
+	var apps []FlatpakApp
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 4 || fields[0] == "" {
+			continue
+		}
+		apps = append(apps, FlatpakApp{
+			ID:      fields[0],
+			Name:    fields[1],
+			Version: fields[2],
+			Origin:  fields[3],
+		})
+	}
+	return apps
+}
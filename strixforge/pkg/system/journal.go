@@ -0,0 +1,194 @@
+package system
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Syslog priorities used to filter journal entries
+const (
+	PriorityErr     = 3
+	PriorityWarning = 4
+	PriorityInfo    = 6
+)
+
+// DiagnosticLines is how many journal lines are attached to a failure
+const DiagnosticLines = 15
+
+// JournalEntry is one record from `journalctl -o json`
+type JournalEntry struct {
+	Time       time.Time
+	Unit       string
+	Identifier string
+	PID        int
+	Priority   int
+	Message    string
+}
+
+// String formats the entry like journalctl's short output
+func (e JournalEntry) String() string {
+	source := e.Identifier
+	if source == "" {
+		source = e.Unit
+	}
+	if e.PID > 0 {
+		source = fmt.Sprintf("%s[%d]", source, e.PID)
+	}
+	return fmt.Sprintf("%s %s: %s", e.Time.Format("Jan 02 15:04:05"), source, e.Message)
+}
+
+// ParseJournal parses line-delimited JSON as written by `journalctl -o json`
+func ParseJournal(data []byte) ([]JournalEntry, error) {
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(line, &fields); err != nil {
+			return entries, fmt.Errorf("invalid journal record: %v", err)
+		}
+
+		entry := JournalEntry{
+			Unit:       journalField(fields, "_SYSTEMD_UNIT"),
+			Identifier: journalField(fields, "SYSLOG_IDENTIFIER"),
+			Message:    journalField(fields, "MESSAGE"),
+			Priority:   PriorityInfo,
+		}
+		if usec, err := strconv.ParseInt(journalField(fields, "__REALTIME_TIMESTAMP"), 10, 64); err == nil {
+			entry.Time = time.UnixMicro(usec)
+		}
+		if pid, err := strconv.Atoi(journalField(fields, "_PID")); err == nil {
+			entry.PID = pid
+		}
+		// Anything but a syslog level 0-7 is treated as informational
+		if prio, err := strconv.Atoi(journalField(fields, "PRIORITY")); err == nil && prio >= 0 && prio <= 7 {
+			entry.Priority = prio
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// journalField decodes a field value. journalctl writes strings, but uses an
+// array of bytes for values that are not valid UTF-8, and null for fields
+// that are too large.
+func journalField(fields map[string]json.RawMessage, key string) string {
+	raw, ok := fields[key]
+	if !ok {
+		return ""
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var ints []int
+	if err := json.Unmarshal(raw, &ints); err == nil {
+		b := make([]byte, 0, len(ints))
+		for _, i := range ints {
+			b = append(b, byte(i))
+		}
+		return strings.ToValidUTF8(string(b), "?")
+	}
+	return ""
+}
+
+// JournalQuery selects journal entries
+type JournalQuery struct {
+	Unit        string // only this unit
+	Kernel      bool   // only kernel messages
+	CurrentBoot bool
+	Grep        string // PCRE pattern matched against MESSAGE
+	MaxPriority int    // 0 means no filter, otherwise include this priority and more severe
+	Lines       int    // most recent N entries, 0 for all
+}
+
+// args renders the query as journalctl arguments
+func (q JournalQuery) args() []string {
+	args := []string{"-o", "json", "--no-pager", "-q"}
+	if q.Unit != "" {
+		args = append(args, "-u", q.Unit)
+	}
+	if q.Kernel {
+		args = append(args, "-k")
+	}
+	if q.CurrentBoot {
+		args = append(args, "-b", "0")
+	}
+	if q.Grep != "" {
+		args = append(args, "--grep", q.Grep)
+	}
+	if q.MaxPriority > 0 {
+		args = append(args, "-p", strconv.Itoa(q.MaxPriority))
+	}
+	if q.Lines > 0 {
+		args = append(args, "-n", strconv.Itoa(q.Lines))
+	}
+	return args
+}
+
+// Journal reads the systemd journal
+type Journal struct{}
+
+// NewJournal creates a new Journal instance
+func NewJournal() *Journal {
+	return &Journal{}
+}
+
+// Read returns entries matching the query, oldest first
+func (j *Journal) Read(ctx context.Context, q JournalQuery) ([]JournalEntry, error) {
+	// The system journal is only fully readable by root and systemd-journal
+	result, err := ExecSudo(ctx, "journalctl", q.args()...)
+	// journalctl exits 1 when --grep matches nothing
+	if err != nil && strings.TrimSpace(result.Stdout) == "" && strings.TrimSpace(result.Stderr) != "" {
+		return nil, fmt.Errorf("journalctl failed: %s\n%s", err, result.Stderr)
+	}
+	return ParseJournal([]byte(result.Stdout))
+}
+
+// UnitLogs returns the last lines a unit logged during the current boot
+func (j *Journal) UnitLogs(ctx context.Context, unit string, lines int) ([]JournalEntry, error) {
+	return j.Read(ctx, JournalQuery{Unit: unit, CurrentBoot: true, Lines: lines})
+}
+
+// KernelMessages returns kernel messages from the current boot matching
+// pattern at maxPriority or more severe
+func (j *Journal) KernelMessages(ctx context.Context, pattern string, maxPriority int) ([]JournalEntry, error) {
+	return j.Read(ctx, JournalQuery{Kernel: true, CurrentBoot: true, Grep: pattern, MaxPriority: maxPriority})
+}
+
+// UnitError is returned when a unit fails to change state. It carries the
+// unit's recent journal so callers can show why.
+type UnitError struct {
+	Unit    string
+	Err     error
+	Journal []JournalEntry
+}
+
+func (e *UnitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UnitError) Unwrap() error {
+	return e.Err
+}
+
+// unitError attaches the unit's recent journal to a failed operation
+func unitError(ctx context.Context, unit string, err error) error {
+	if err == nil {
+		return nil
+	}
+	journal, _ := NewJournal().UnitLogs(ctx, unit, DiagnosticLines)
+	return &UnitError{Unit: unit, Err: err, Journal: journal}
+}
@@ -0,0 +1,117 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseJournal(t *testing.T) {
+	tests := []struct {
+		file string
+		want []JournalEntry
+	}{
+		{"journal-unit.json", []JournalEntry{
+			{Time: time.UnixMicro(1760868000000000), Unit: "init.scope", Identifier: "systemd", PID: 1, Priority: 6, Message: "Starting LXD - main daemon..."},
+			{Time: time.UnixMicro(1760868000212860), Unit: "lxd.service", Identifier: "lxd", PID: 1432, Priority: PriorityErr,
+				Message: `Error: Failed to initialize storage pool "default": Required tool 'zpool' is missing`},
+			{Time: time.UnixMicro(1760868000220011), Unit: "init.scope", Identifier: "systemd", PID: 1, Priority: 5,
+				Message: "lxd.service: Main process exited, code=exited, status=1/FAILURE"},
+		}},
+		{"journal-kernel.json", []JournalEntry{
+			{Time: time.UnixMicro(1760867999189674), Identifier: "kernel", Priority: PriorityErr,
+				Message: "amdgpu 0000:c5:00.0: amdgpu: [gfxhub] page fault (src_id:0 ring:40 vmid:3 pasid:32771)"},
+			{Time: time.UnixMicro(1760867999189900), Identifier: "kernel", Priority: PriorityWarning,
+				Message: "ice 0000:c3:00.0: Tx timeout on queue 5"},
+		}},
+		{"journal-binary.json", []JournalEntry{
+			// Invalid UTF-8 is replaced; escape sequences are kept
+			{Time: time.UnixMicro(1760868001000000), Unit: "ollama.service", Identifier: "ollama", PID: 2210, Priority: PriorityWarning, Message: "loaded model ? ok"},
+			{Time: time.UnixMicro(1760868001000100), Unit: "ollama.service", Identifier: "ollama", PID: 2210, Priority: PriorityInfo, Message: "\x1b[31mprogress\x1b[0m"},
+			// Fields too large for journalctl are null
+			{Time: time.UnixMicro(1760868001000200), Unit: "ollama.service", Identifier: "ollama", PID: 2210, Priority: PriorityInfo},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseJournal(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got:\n%+v\nwant:\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJournalPriorities(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "journal-priorities.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ParseJournal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{
+		"emergency":      0,
+		"debug":          7,
+		"no priority":    PriorityInfo,
+		"empty priority": PriorityInfo,
+		"named priority": PriorityInfo,
+		"out of range":   PriorityInfo,
+		"negative":       PriorityInfo,
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(want))
+	}
+	for _, e := range entries {
+		if p, ok := want[e.Message]; !ok || e.Priority != p {
+			t.Errorf("%q: priority %d, want %d", e.Message, e.Priority, p)
+		}
+	}
+}
+
+func TestParseJournalInvalid(t *testing.T) {
+	data := []byte("\n{\"MESSAGE\":\"first\"}\n\n{not json}\n{\"MESSAGE\":\"never read\"}\n")
+	entries, err := ParseJournal(data)
+	if err == nil || !strings.Contains(err.Error(), "invalid journal record") {
+		t.Errorf("err = %v, want an invalid record error", err)
+	}
+	if len(entries) != 1 || entries[0].Message != "first" {
+		t.Errorf("entries before the bad record = %+v", entries)
+	}
+}
+
+func TestJournalEntryString(t *testing.T) {
+	at := time.Date(2026, 10, 19, 9, 5, 3, 0, time.Local)
+	tests := []struct {
+		entry JournalEntry
+		want  string
+	}{
+		{JournalEntry{Time: at, Unit: "lxd.service", Identifier: "lxd", PID: 1432, Message: "ready"}, "Oct 19 09:05:03 lxd[1432]: ready"},
+		{JournalEntry{Time: at, Unit: "lxd.service", Message: "ready"}, "Oct 19 09:05:03 lxd.service: ready"},
+		{JournalEntry{Time: at, Identifier: "kernel", Message: "oops"}, "Oct 19 09:05:03 kernel: oops"},
+	}
+	for _, tt := range tests {
+		if got := tt.entry.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestJournalQueryArgs(t *testing.T) {
+	q := JournalQuery{Unit: "lxd.service", Kernel: true, CurrentBoot: true, Grep: "amdgpu|ice", MaxPriority: PriorityWarning, Lines: 15}
+	want := []string{"-o", "json", "--no-pager", "-q", "-u", "lxd.service", "-k", "-b", "0", "--grep", "amdgpu|ice", "-p", "4", "-n", "15"}
+	if got := q.args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("args() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,73 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MemoryInfo describes host memory and how much of it the GPU can claim.
+// On an APU the GPU has no memory of its own: VRAM is a carve-out reserved
+// by the firmware and GTT is system RAM the GPU maps on demand.
+type MemoryInfo struct {
+	Total uint64 // RAM available to the OS (MemTotal)
+	VRAM  uint64 // firmware carve-out, not part of Total
+	GTT   uint64 // GPU-mappable system RAM, part of Total
+}
+
+// Physical returns installed RAM: what the OS sees plus the VRAM carve-out
+func (m MemoryInfo) Physical() uint64 {
+	return m.Total + m.VRAM
+}
+
+// ReadMemoryInfo reads host RAM from /proc/meminfo and the amdgpu VRAM and
+// GTT sizes from sysfs. GPU sizes are zero when no amdgpu device is found.
+func ReadMemoryInfo() (MemoryInfo, error) {
+	var info MemoryInfo
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return info, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return info, fmt.Errorf("invalid MemTotal: %v", err)
+			}
+			info.Total = kb * 1024
+		}
+	}
+	if info.Total == 0 {
+		return info, fmt.Errorf("MemTotal not found in /proc/meminfo")
+	}
+
+	// Use the largest amdgpu device; Strix Halo has exactly one
+	cards, _ := filepath.Glob("/sys/class/drm/card[0-9]*/device/mem_info_gtt_total")
+	for _, gttPath := range cards {
+		gtt := readSysfsUint(gttPath)
+		if gtt > info.GTT {
+			info.GTT = gtt
+			info.VRAM = readSysfsUint(filepath.Join(filepath.Dir(gttPath), "mem_info_vram_total"))
+		}
+	}
+
+	// Before amdgpu loads, the TTM page limit is what GTT will be sized to
+	if info.GTT == 0 {
+		if pages := readSysfsUint("/sys/module/ttm/parameters/pages_limit"); pages > 0 {
+			info.GTT = pages * uint64(os.Getpagesize())
+		}
+	}
+	return info, nil
+}
+
+// readSysfsUint reads a single number from a sysfs attribute, or 0
+func readSysfsUint(path string) uint64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return n
+}
@@ -0,0 +1,214 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OCILabel marks containers created by strixforge under podman or docker
+const OCILabel = "io.strixforge.managed"
+
+// OCIRuntime runs workspace containers with the podman or docker CLI. The
+// two accept the same commands for everything used here; only GPU group
+// handling differs.
+type OCIRuntime struct {
+	command string
+}
+
+// NewPodman creates a runtime using the podman CLI
+func NewPodman() *OCIRuntime {
+	return &OCIRuntime{command: "podman"}
+}
+
+// NewDocker creates a runtime using the docker CLI
+func NewDocker() *OCIRuntime {
+	return &OCIRuntime{command: "docker"}
+}
+
+// Name returns the CLI the runtime uses
+func (o *OCIRuntime) Name() string {
+	return o.command
+}
+
+// Ping checks that the CLI is installed and can reach its engine
+func (o *OCIRuntime) Ping(ctx context.Context) error {
+	if !CheckCommand(o.command) {
+		return fmt.Errorf("%s is not installed", o.command)
+	}
+	result, err := Exec(ctx, o.command, "info")
+	if err != nil {
+		return fmt.Errorf("%s info failed: %s\n%s", o.command, err, result.Stderr)
+	}
+	return nil
+}
+
+// List returns the containers carrying OCILabel
+func (o *OCIRuntime) List(ctx context.Context) ([]ContainerInfo, error) {
+	result, err := Exec(ctx, o.command, "ps", "--all", "--filter", "label="+OCILabel+"=true", "--format", "{{.Names}}\t{{.State}}\t{{.Image}}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s containers: %s\n%s", o.command, err, result.Stderr)
+	}
+	var containers []ContainerInfo
+	for _, line := range strings.Split(strings.TrimSpace(result.Stdout), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			continue
+		}
+		info := ContainerInfo{Name: fields[0], State: ContainerStopped, Image: fields[2]}
+		if strings.EqualFold(fields[1], "running") {
+			info.State = ContainerRunning
+		}
+		containers = append(containers, info)
+	}
+	return containers, nil
+}
+
+// State reports whether a container exists and is running
+func (o *OCIRuntime) State(ctx context.Context, name string) (ContainerState, error) {
+	result, err := Exec(ctx, o.command, "ps", "--all", "--filter", "name=^"+name+"$", "--format", "{{.State}}")
+	if err != nil {
+		return "", fmt.Errorf("failed to look up %s: %s\n%s", name, err, result.Stderr)
+	}
+	switch state := strings.TrimSpace(result.Stdout); {
+	case state == "":
+		return ContainerMissing, nil
+	case strings.EqualFold(state, "running"):
+		return ContainerRunning, nil
+	default:
+		return ContainerStopped, nil
+	}
+}
+
+// Create creates a container that idles until commands are run in it. Its
+// devices, ports and mounts cannot change afterwards.
+func (o *OCIRuntime) Create(ctx context.Context, cfg ContainerConfig) error {
+	args := []string{"create", "--name", cfg.Name, "--hostname", cfg.Name, "--init", "--label", OCILabel + "=true"}
+	if cfg.Description != "" {
+		args = append(args, "--label", "io.strixforge.description="+cfg.Description)
+	}
+	if cfg.GPU {
+		args = append(args, o.gpuArgs()...)
+	}
+	for key, value := range cfg.Environment {
+		args = append(args, "--env", key+"="+value)
+	}
+	for _, p := range cfg.Ports {
+		args = append(args, "--publish", fmt.Sprintf("127.0.0.1:%d:%d/%s", p.Host, p.Container, p.Protocol))
+	}
+	for _, m := range cfg.Mounts {
+		volume := m.Source + ":" + m.Path
+		if m.ReadOnly {
+			volume += ":ro"
+		}
+		args = append(args, "--volume", volume)
+	}
+	if cfg.CPU != "" {
+		if _, err := strconv.Atoi(cfg.CPU); err == nil {
+			args = append(args, "--cpus", cfg.CPU)
+		} else {
+			args = append(args, "--cpuset-cpus", cfg.CPU)
+		}
+	}
+	if cfg.Memory > 0 {
+		args = append(args, "--memory", strconv.FormatUint(cfg.Memory, 10))
+	}
+	if cfg.Processes > 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(cfg.Processes))
+	}
+	args = append(args, cfg.Image, "sleep", "infinity")
+
+	result, err := Exec(ctx, o.command, args...)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %s\n%s", cfg.Name, err, result.Stderr)
+	}
+	return nil
+}
+
+// gpuArgs passes the GPU device nodes through. Rootless podman keeps the
+// user's video and render groups; docker adds them by name.
+func (o *OCIRuntime) gpuArgs() []string {
+	args := []string{"--device", "/dev/dri", "--device", "/dev/kfd"}
+	if o.command == "podman" {
+		return append(args, "--group-add", "keep-groups")
+	}
+	return append(args, "--group-add", "video", "--group-add", "render")
+}
+
+// Start starts a stopped container
+func (o *OCIRuntime) Start(ctx context.Context, name string) error {
+	return o.run(ctx, "start", name)
+}
+
+// Stop stops a running container
+func (o *OCIRuntime) Stop(ctx context.Context, name string) error {
+	return o.run(ctx, "stop", name)
+}
+
+// Delete removes a container, running or not. Missing containers are not
+// an error.
+func (o *OCIRuntime) Delete(ctx context.Context, name string) error {
+	state, err := o.State(ctx, name)
+	if err != nil || state == ContainerMissing {
+		return err
+	}
+	return o.run(ctx, "rm", "--force", name)
+}
+
+// Exec runs a command in a running container
+func (o *OCIRuntime) Exec(ctx context.Context, name string, command ...string) (*ExecResult, error) {
+	return Exec(ctx, o.command, append([]string{"exec", name}, command...)...)
+}
+
+// Snapshot commits the container's filesystem to a local image, see
+// SnapshotImage
+func (o *OCIRuntime) Snapshot(ctx context.Context, name, snapshot string) error {
+	return o.run(ctx, "commit", name, SnapshotImage(name, snapshot))
+}
+
+// Snapshots returns the tags of a container's snapshot images, oldest
+// first
+func (o *OCIRuntime) Snapshots(ctx context.Context, name string) ([]string, error) {
+	result, err := Exec(ctx, o.command, "images", "--format", "{{.Repository}}:{{.Tag}}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s images: %s\n%s", o.command, err, result.Stderr)
+	}
+	// Podman lists them as localhost/strixforge/NAME, newest first
+	repository := strings.Split(SnapshotImage(name, ""), ":")[0]
+	var snapshots []string
+	for _, line := range strings.Split(strings.TrimSpace(result.Stdout), "\n") {
+		image, tag, ok := strings.Cut(line, ":")
+		if ok && (image == repository || strings.HasSuffix(image, "/"+repository)) {
+			snapshots = append([]string{tag}, snapshots...)
+		}
+	}
+	return snapshots, nil
+}
+
+// SnapshotImage is the local image a podman or docker snapshot is saved as
+func SnapshotImage(name, snapshot string) string {
+	return fmt.Sprintf("strixforge/%s:%s", name, snapshot)
+}
+
+// DeleteSnapshot removes a snapshot image
+func (o *OCIRuntime) DeleteSnapshot(ctx context.Context, name, snapshot string) error {
+	result, err := Exec(ctx, o.command, "rmi", SnapshotImage(name, snapshot))
+	if err == nil {
+		return nil
+	}
+	// podman says "image not known", docker "No such image"
+	if stderr := strings.ToLower(result.Stderr); strings.Contains(stderr, "not known") || strings.Contains(stderr, "no such image") {
+		return nil
+	}
+	return fmt.Errorf("failed to delete snapshot image %s: %s\n%s", SnapshotImage(name, snapshot), err, result.Stderr)
+}
+
+// run runs a CLI subcommand, returning its stderr on failure
+func (o *OCIRuntime) run(ctx context.Context, args ...string) error {
+	result, err := Exec(ctx, o.command, args...)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %s\n%s", o.command, strings.Join(args, " "), err, result.Stderr)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package system
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OfflineRepoName is the pacman repository name used for offline bundles
+const OfflineRepoName = "strixforge-local"
+
+// DefaultPackageCache is where pacman keeps downloaded packages
+const DefaultPackageCache = "/var/cache/pacman/pkg"
+
+// PackageFile is a package archive resolved from the sync databases
+type PackageFile struct {
+	Repo     string
+	Name     string
+	Version  string
+	Filename string
+}
+
+// ResolveDependencies returns packages plus their full dependency closure as a
+// freshly installed system would need them. Only the local sync databases are
+// read, so no network access is required.
+func (p *Pacman) ResolveDependencies(ctx context.Context, packages ...string) ([]PackageFile, error) {
+	// An empty local database makes pacman treat every dependency as missing,
+	// while the sync databases are shared with the host
+	dbPath, err := os.MkdirTemp("", "strixforge-db-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dbPath)
+
+	if err := os.MkdirAll(filepath.Join(dbPath, "local"), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.Symlink("/var/lib/pacman/sync", filepath.Join(dbPath, "sync")); err != nil {
+		return nil, err
+	}
+
+	args := append([]string{"-Sp", "--dbpath", dbPath, "--print-format", "%r %n %v %f"}, packages...)
+	result, err := Exec(ctx, "pacman", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve dependencies: %s\n%s", err, result.Stderr)
+	}
+
+	var files []PackageFile
+	scanner := bufio.NewScanner(strings.NewReader(result.Stdout))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 4 {
+			continue
+		}
+		files = append(files, PackageFile{
+			Repo:     fields[0],
+			Name:     fields[1],
+			Version:  fields[2],
+			Filename: fields[3],
+		})
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no packages resolved; are the sync databases present? (run pacman -Sy while online)")
+	}
+	return files, nil
+}
+
+// BuildOfflineRepo copies package files (and their signatures) from the first
+// source directory that has them into dir, then generates the repository
+// database. Packages found in no source are returned as missing.
+func BuildOfflineRepo(ctx context.Context, dir string, files []PackageFile, sources []string) ([]PackageFile, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var missing []PackageFile
+	var copied []string
+	for _, f := range files {
+		src := findPackageFile(f.Filename, sources)
+		if src == "" {
+			missing = append(missing, f)
+			continue
+		}
+
+		dst := filepath.Join(dir, f.Filename)
+		if err := CopyFile(src, dst); err != nil {
+			return nil, err
+		}
+		if FileExists(src + ".sig") {
+			if err := CopyFile(src+".sig", dst+".sig"); err != nil {
+				return nil, err
+			}
+		}
+		copied = append(copied, dst)
+	}
+
+	if len(missing) > 0 {
+		return missing, nil
+	}
+
+	dbFile := filepath.Join(dir, OfflineRepoName+".db.tar.gz")
+	args := append([]string{"-q", dbFile}, copied...)
+	result, err := Exec(ctx, "repo-add", args...)
+	if err != nil {
+		return nil, fmt.Errorf("repo-add failed: %s\n%s", err, result.Stderr)
+	}
+	return nil, nil
+}
+
+// findPackageFile returns the first source path containing filename
+func findPackageFile(filename string, sources []string) string {
+	for _, dir := range sources {
+		path := filepath.Join(dir, filename)
+		if FileExists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// OfflineRepo is a local package repository created by the bundle command
+type OfflineRepo struct {
+	Dir        string
+	ConfigPath string // temporary pacman.conf that only uses Dir
+}
+
+// activeOfflineRepo is picked up by new Pacman instances
+var activeOfflineRepo *OfflineRepo
+
+// EnableOfflineRepo writes a temporary copy of pacman.conf that keeps the
+// host's [options] but replaces every repository with the local bundle repo,
+// and makes it the default for new Pacman instances.
+func EnableOfflineRepo(dir string) (*OfflineRepo, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !FileExists(filepath.Join(dir, OfflineRepoName+".db")) {
+		return nil, fmt.Errorf("%s is not a strixforge bundle repository (missing %s.db)", dir, OfflineRepoName)
+	}
+
+	conf, err := LoadPacmanConf(DefaultPacmanConfPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, repo := range conf.Repos() {
+		conf.RemoveRepo(repo)
+	}
+	conf.appendRepo(PacmanRepo{
+		Name:     OfflineRepoName,
+		SigLevel: "Optional",
+		Servers:  []string{"file://" + dir},
+	})
+
+	f, err := os.CreateTemp("", "strixforge-pacman-*.conf")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(conf.Bytes()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	// pacman runs through sudo and must be able to read it
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	activeOfflineRepo = &OfflineRepo{Dir: dir, ConfigPath: f.Name()}
+	return activeOfflineRepo, nil
+}
+
+// Close removes the temporary pacman.conf and restores online operation
+func (r *OfflineRepo) Close() error {
+	if activeOfflineRepo == r {
+		activeOfflineRepo = nil
+	}
+	return os.Remove(r.ConfigPath)
+}
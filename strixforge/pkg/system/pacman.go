@@ -4,31 +4,88 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 )
 
 // Pacman provides package management operations
-type Pacman struct{}
+type Pacman struct {
+	lockTimeout  time.Duration
+	onLockWait   LockWaitFunc
+	confirmStale StaleLockFunc
+	offlineRepo  *OfflineRepo
+}
 
 // NewPacman creates a new Pacman instance
 func NewPacman() *Pacman {
-	return &Pacman{}
+	return &Pacman{
+		lockTimeout: DefaultLockTimeout,
+		offlineRepo: activeOfflineRepo,
+	}
+}
+
+// Offline reports whether packages come from a local bundle repository
+func (p *Pacman) Offline() bool {
+	return p.offlineRepo != nil
+}
+
+// SetLockTimeout sets how long to wait for the database lock
+func (p *Pacman) SetLockTimeout(timeout time.Duration) {
+	p.lockTimeout = timeout
+}
+
+// OnLockWait registers a callback invoked while waiting for the database lock
+func (p *Pacman) OnLockWait(fn LockWaitFunc) {
+	p.onLockWait = fn
+}
+
+// OnStaleLock registers a callback that approves removal of a stale lock.
+// Without one, a stale lock is reported as an error and left in place.
+func (p *Pacman) OnStaleLock(fn StaleLockFunc) {
+	p.confirmStale = fn
+}
+
+// run waits for the database lock and executes pacman with sudo
+func (p *Pacman) run(ctx context.Context, args ...string) (*ExecResult, error) {
+	if err := p.waitForLock(ctx); err != nil {
+		return nil, err
+	}
+
+	if p.offlineRepo != nil {
+		args = append([]string{"--config", p.offlineRepo.ConfigPath}, args...)
+	}
+
+	result, err := ExecSudo(ctx, "pacman", args...)
+	if err != nil && isLockFailure(result.Stderr) {
+		// Another process grabbed the lock between our check and pacman starting
+		return result, &LockError{Path: PacmanLockPath, Holders: FindLockHolders(PacmanLockPath)}
+	}
+	return result, err
 }
 
 // Install installs packages
 func (p *Pacman) Install(ctx context.Context, packages ...string) error {
 	args := append([]string{"-S", "--needed", "--noconfirm"}, packages...)
-	result, err := ExecSudo(ctx, "pacman", args...)
+	result, err := p.run(ctx, args...)
 	if err != nil {
-		return fmt.Errorf("pacman install failed: %s\n%s", err, result.Stderr)
+		return pacmanError("install", result, err)
 	}
 	return nil
 }
 
 // Update performs a full system update
 func (p *Pacman) Update(ctx context.Context) error {
-	result, err := ExecSudo(ctx, "pacman", "-Syu", "--noconfirm")
+	result, err := p.run(ctx, "-Syu", "--noconfirm")
+	if err != nil {
+		return pacmanError("update", result, err)
+	}
+	return nil
+}
+
+// SyncDatabases refreshes the sync databases, e.g. after adding a repository
+func (p *Pacman) SyncDatabases(ctx context.Context) error {
+	result, err := p.run(ctx, "-Sy")
 	if err != nil {
-		return fmt.Errorf("pacman update failed: %s\n%s", err, result.Stderr)
+		return pacmanError("sync", result, err)
 	}
 	return nil
 }
@@ -36,13 +93,21 @@ func (p *Pacman) Update(ctx context.Context) error {
 // Remove removes packages
 func (p *Pacman) Remove(ctx context.Context, packages ...string) error {
 	args := append([]string{"-Rns", "--noconfirm"}, packages...)
-	result, err := ExecSudo(ctx, "pacman", args...)
+	result, err := p.run(ctx, args...)
 	if err != nil {
-		return fmt.Errorf("pacman remove failed: %s\n%s", err, result.Stderr)
+		return pacmanError("remove", result, err)
 	}
 	return nil
 }
 
+// pacmanError formats a failed pacman invocation, passing lock errors through
+func pacmanError(op string, result *ExecResult, err error) error {
+	if _, ok := err.(*LockError); ok || result == nil {
+		return err
+	}
+	return fmt.Errorf("pacman %s failed: %s\n%s", op, err, result.Stderr)
+}
+
 // IsInstalled checks if a package is installed
 func (p *Pacman) IsInstalled(ctx context.Context, pkg string) bool {
 	result, err := Exec(ctx, "pacman", "-Q", pkg)
@@ -72,38 +137,16 @@ func (p *Pacman) CleanOrphans(ctx context.Context) error {
 	}
 
 	// Remove orphans
-	_, err = ExecShellSudo(ctx, "pacman -Rns --noconfirm $(pacman -Qtdq)")
+	args := append([]string{"-Rns", "--noconfirm"}, strings.Fields(orphans.Stdout)...)
+	_, err = p.run(ctx, args...)
 	return err
 }
 
 // CleanCache cleans the package cache
 func (p *Pacman) CleanCache(ctx context.Context) error {
+	if err := p.waitForLock(ctx); err != nil {
+		return err
+	}
 	_, err := ExecShellSudo(ctx, "echo y | pacman -Scc")
 	return err
 }
-
-// Yay provides AUR package management (runs as user, not root)
-type Yay struct {
-	user string
-}
-
-// NewYay creates a new Yay instance for the specified user
-func NewYay(user string) *Yay {
-	return &Yay{user: user}
-}
-
-// Install installs AUR packages
-func (y *Yay) Install(ctx context.Context, packages ...string) error {
-	args := append([]string{"-u", y.user, "yay", "-S", "--needed", "--noconfirm"}, packages...)
-	result, err := Exec(ctx, "sudo", args...)
-	if err != nil {
-		return fmt.Errorf("yay install failed: %s\n%s", err, result.Stderr)
-	}
-	return nil
-}
-
-// IsInstalled checks if a package is installed (works for AUR too)
-func (y *Yay) IsInstalled(ctx context.Context, pkg string) bool {
-	result, err := Exec(ctx, "pacman", "-Q", pkg)
-	return err == nil && result.ExitCode == 0
-}
@@ -0,0 +1,380 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// DefaultPacmanConfPath is the system pacman configuration
+const DefaultPacmanConfPath = "/etc/pacman.conf"
+
+var (
+	confSectionRe   = regexp.MustCompile(`^\s*\[([^\]]+)\]\s*$`)
+	confCommentedRe = regexp.MustCompile(`^\s*#\s*\[([^\]]+)\]\s*$`)
+	confDirectiveRe = regexp.MustCompile(`^\s*#\s*((Include|Server|SigLevel|Usage)\s*=.*)$`)
+)
+
+// PacmanRepo describes a repository section
+type PacmanRepo struct {
+	Name     string
+	SigLevel string
+	Include  string
+	Servers  []string
+}
+
+// lines renders the repository as pacman.conf lines
+func (r PacmanRepo) lines() []string {
+	lines := []string{fmt.Sprintf("[%s]", r.Name)}
+	if r.SigLevel != "" {
+		lines = append(lines, "SigLevel = "+r.SigLevel)
+	}
+	if r.Include != "" {
+		lines = append(lines, "Include = "+r.Include)
+	}
+	for _, server := range r.Servers {
+		lines = append(lines, "Server = "+server)
+	}
+	return lines
+}
+
+// PacmanConf is a parsed pacman.conf. Edits are made line by line so that
+// comments, ordering and formatting of untouched lines are preserved.
+type PacmanConf struct {
+	lines []string
+	eol   bool // the file ends with a newline
+}
+
+// confSection locates a section header and its body
+type confSection struct {
+	name      string
+	start     int // header line
+	end       int // next header line, or len(lines)
+	commented bool
+}
+
+// ParsePacmanConf parses pacman.conf content
+func ParsePacmanConf(data []byte) *PacmanConf {
+	text, eol := strings.CutSuffix(string(data), "\n")
+	return &PacmanConf{lines: strings.Split(text, "\n"), eol: eol}
+}
+
+// LoadPacmanConf reads and parses a pacman.conf file
+func LoadPacmanConf(path string) (*PacmanConf, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return ParsePacmanConf(data), nil
+}
+
+// Bytes renders the configuration
+func (c *PacmanConf) Bytes() []byte {
+	text := strings.Join(c.lines, "\n")
+	if c.eol {
+		text += "\n"
+	}
+	return []byte(text)
+}
+
+// sections returns active and commented-out section headers in file order
+func (c *PacmanConf) sections() []confSection {
+	var sections []confSection
+	for i, line := range c.lines {
+		var sec confSection
+		if m := confSectionRe.FindStringSubmatch(line); m != nil {
+			sec = confSection{name: m[1], start: i}
+		} else if m := confCommentedRe.FindStringSubmatch(line); m != nil {
+			sec = confSection{name: m[1], start: i, commented: true}
+		} else {
+			continue
+		}
+		if n := len(sections); n > 0 {
+			sections[n-1].end = i
+		}
+		sections = append(sections, sec)
+	}
+	if n := len(sections); n > 0 {
+		sections[n-1].end = len(c.lines)
+	}
+	return sections
+}
+
+// find returns the named section, active or commented
+func (c *PacmanConf) find(name string, commented bool) (confSection, bool) {
+	for _, sec := range c.sections() {
+		if sec.name == name && sec.commented == commented {
+			return sec, true
+		}
+	}
+	return confSection{}, false
+}
+
+// Repos returns the names of active repositories in priority order
+func (c *PacmanConf) Repos() []string {
+	var repos []string
+	for _, sec := range c.sections() {
+		if !sec.commented && sec.name != "options" {
+			repos = append(repos, sec.name)
+		}
+	}
+	return repos
+}
+
+// HasRepo checks if a repository is active
+func (c *PacmanConf) HasRepo(name string) bool {
+	_, ok := c.find(name, false)
+	return ok
+}
+
+// EnableRepo uncomments a disabled repository section such as the stock
+// "#[multilib]" block. Returns false if no commented section exists.
+func (c *PacmanConf) EnableRepo(name string) bool {
+	if c.HasRepo(name) {
+		return true
+	}
+	sec, ok := c.find(name, true)
+	if !ok {
+		return false
+	}
+
+	c.lines[sec.start] = fmt.Sprintf("[%s]", name)
+	for i := sec.start + 1; i < sec.end; i++ {
+		if strings.TrimSpace(c.lines[i]) == "" {
+			break
+		}
+		if m := confDirectiveRe.FindStringSubmatch(c.lines[i]); m != nil {
+			c.lines[i] = m[1]
+		}
+	}
+	return true
+}
+
+// AddRepoBefore inserts a repository ahead of another one, which gives it
+// higher priority. If before is not configured, the repo is appended.
+func (c *PacmanConf) AddRepoBefore(repo PacmanRepo, before string) {
+	if c.HasRepo(repo.Name) {
+		return
+	}
+	sec, ok := c.find(before, false)
+	if !ok {
+		c.appendRepo(repo)
+		return
+	}
+
+	// Keep the comment block describing the following section attached to it
+	at := sec.start
+	for at > 0 && strings.HasPrefix(strings.TrimSpace(c.lines[at-1]), "#") {
+		at--
+	}
+	c.insert(at, append(repo.lines(), ""))
+}
+
+// AddRepoAfter inserts a repository right after another one, which gives it
+// lower priority. If after is not configured, the repo is appended.
+func (c *PacmanConf) AddRepoAfter(repo PacmanRepo, after string) {
+	if c.HasRepo(repo.Name) {
+		return
+	}
+	sec, ok := c.find(after, false)
+	if !ok {
+		c.appendRepo(repo)
+		return
+	}
+	c.insert(c.lastDirective(sec), append([]string{""}, repo.lines()...))
+}
+
+// RemoveRepo removes an active repository section
+func (c *PacmanConf) RemoveRepo(name string) {
+	sec, ok := c.find(name, false)
+	if !ok {
+		return
+	}
+	end := c.lastDirective(sec)
+	// Swallow one separating blank line
+	if end < len(c.lines) && strings.TrimSpace(c.lines[end]) == "" {
+		end++
+	}
+	c.lines = append(c.lines[:sec.start], c.lines[end:]...)
+}
+
+// Option returns the value of an [options] directive
+func (c *PacmanConf) Option(key string) (string, bool) {
+	sec, ok := c.find("options", false)
+	if !ok {
+		return "", false
+	}
+	for i := sec.start + 1; i < sec.end; i++ {
+		if k, v, ok := parseDirective(c.lines[i]); ok && k == key {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// SetOption sets an [options] directive, replacing an existing or
+// commented-out line in place where possible
+func (c *PacmanConf) SetOption(key, value string) {
+	line := fmt.Sprintf("%s = %s", key, value)
+
+	sec, ok := c.find("options", false)
+	if !ok {
+		c.insert(0, []string{"[options]", line, ""})
+		return
+	}
+
+	commented := -1
+	for i := sec.start + 1; i < sec.end; i++ {
+		if k, _, ok := parseDirective(c.lines[i]); ok && k == key {
+			c.lines[i] = line
+			return
+		}
+		trimmed := strings.TrimSpace(c.lines[i])
+		if commented < 0 && strings.HasPrefix(trimmed, "#") {
+			if k, _, ok := parseDirective(strings.TrimPrefix(trimmed, "#")); ok && k == key {
+				commented = i
+			}
+		}
+	}
+	if commented >= 0 {
+		c.lines[commented] = line
+		return
+	}
+	c.insert(c.lastDirective(sec), []string{line})
+}
+
+// lastDirective returns the index just past the last non-comment line of a section
+func (c *PacmanConf) lastDirective(sec confSection) int {
+	for i := sec.end - 1; i > sec.start; i-- {
+		trimmed := strings.TrimSpace(c.lines[i])
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			return i + 1
+		}
+	}
+	return sec.start + 1
+}
+
+func (c *PacmanConf) appendRepo(repo PacmanRepo) {
+	if n := len(c.lines); n > 0 && strings.TrimSpace(c.lines[n-1]) != "" {
+		c.lines = append(c.lines, "")
+	}
+	c.lines = append(c.lines, repo.lines()...)
+}
+
+func (c *PacmanConf) insert(at int, lines []string) {
+	rest := append([]string{}, c.lines[at:]...)
+	c.lines = append(append(c.lines[:at], lines...), rest...)
+}
+
+// parseDirective splits "Key = Value" (or a bare "Key" flag)
+func parseDirective(line string) (string, string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "[") {
+		return "", "", false
+	}
+	key, value, found := strings.Cut(trimmed, "=")
+	if !found {
+		return trimmed, "", true
+	}
+	return strings.TrimSpace(key), strings.TrimSpace(value), true
+}
+
+// MultilibRepo is the stock Arch multilib repository (lib32-* packages)
+var MultilibRepo = PacmanRepo{Name: "multilib", Include: "/etc/pacman.d/mirrorlist"}
+
+// EnsureMultilib enables multilib, uncommenting the stock block if present
+// and otherwise adding it after [extra]. Returns true if the config changed.
+func (c *PacmanConf) EnsureMultilib() bool {
+	if c.HasRepo(MultilibRepo.Name) {
+		return false
+	}
+	if !c.EnableRepo(MultilibRepo.Name) {
+		c.AddRepoAfter(MultilibRepo, "extra")
+	}
+	return true
+}
+
+// CachyOSKeyID is the CachyOS repository signing key
+const CachyOSKeyID = "F3B607488DB35A47"
+
+// cachyosMirror serves every CachyOS repository
+const cachyosMirror = "https://mirror.cachyos.org/repo"
+
+// CachyOSRepos returns the CachyOS repositories for an x86-64 microarchitecture
+// level (1, 3 or 4), in the order they must appear ahead of [core]
+func CachyOSRepos(level int) []PacmanRepo {
+	var repos []PacmanRepo
+	if level >= 3 {
+		arch := fmt.Sprintf("x86_64_v%d", level)
+		suffix := fmt.Sprintf("-v%d", level)
+		for _, name := range []string{"cachyos" + suffix, "cachyos-core" + suffix, "cachyos-extra" + suffix} {
+			repos = append(repos, PacmanRepo{
+				Name:    name,
+				Servers: []string{fmt.Sprintf("%s/%s/$repo", cachyosMirror, arch)},
+			})
+		}
+	}
+	repos = append(repos, PacmanRepo{
+		Name:    "cachyos",
+		Servers: []string{cachyosMirror + "/$arch/$repo"},
+	})
+	return repos
+}
+
+// HasCachyOSRepos checks if any CachyOS repository is active
+func (c *PacmanConf) HasCachyOSRepos() bool {
+	for _, repo := range c.Repos() {
+		if strings.HasPrefix(repo, "cachyos") {
+			return true
+		}
+	}
+	return false
+}
+
+// AddCachyOSRepos adds the CachyOS repositories for level above [core] and
+// lets pacman accept the matching optimized package architecture
+func (c *PacmanConf) AddCachyOSRepos(level int) {
+	for _, repo := range CachyOSRepos(level) {
+		c.AddRepoBefore(repo, "core")
+	}
+
+	if level >= 3 {
+		arch := fmt.Sprintf("x86_64_v%d", level)
+		current, _ := c.Option("Architecture")
+		if current == "" || current == "auto" {
+			current = "x86_64"
+		}
+		if !containsString(strings.Fields(current), arch) {
+			c.SetOption("Architecture", current+" "+arch)
+		}
+	}
+}
+
+// SupportedArchLevel returns the highest x86-64 microarchitecture level
+// (1 to 4) the CPU supports, as reported by the dynamic loader
+func SupportedArchLevel(ctx context.Context) int {
+	result, err := Exec(ctx, "/lib/ld-linux-x86-64.so.2", "--help")
+	if err != nil && result == nil {
+		return 1
+	}
+	for level := 4; level >= 2; level-- {
+		if strings.Contains(result.Stdout, fmt.Sprintf("x86-64-v%d (supported", level)) {
+			return level
+		}
+	}
+	return 1
+}
+
+// WritePacmanConf backs up path and atomically replaces it with conf
+func WritePacmanConf(ctx context.Context, path string, conf *PacmanConf) (string, error) {
+	backup, err := BackupFileSudo(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if err := WriteFileSudo(ctx, path, conf.Bytes(), 0644); err != nil {
+		return backup, err
+	}
+	return backup, nil
+}
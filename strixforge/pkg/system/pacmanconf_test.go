@@ -0,0 +1,123 @@
+package system
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// checkGolden compares got with testdata/name.golden
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func loadTestPacmanConf(t *testing.T, file string) *PacmanConf {
+	t.Helper()
+	conf, err := LoadPacmanConf(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conf
+}
+
+func TestPacmanConfRoundTrip(t *testing.T) {
+	// pacman-minimal.conf has no newline at the end
+	for _, file := range []string{"pacman.conf", "pacman-minimal.conf"} {
+		t.Run(file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ParsePacmanConf(data).Bytes(); string(got) != string(data) {
+				t.Errorf("Bytes() changed an unedited file:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestPacmanConfRepos(t *testing.T) {
+	conf := loadTestPacmanConf(t, "pacman.conf")
+	if got, want := conf.Repos(), []string{"core", "extra"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Repos() = %v, want %v", got, want)
+	}
+	if v, ok := conf.Option("ParallelDownloads"); !ok || v != "5" {
+		t.Errorf("Option(ParallelDownloads) = %q, %v", v, ok)
+	}
+	if _, ok := conf.Option("Color"); ok {
+		t.Error("Option found the commented-out Color")
+	}
+}
+
+func TestEnsureMultilib(t *testing.T) {
+	tests := []struct {
+		file   string
+		golden string
+	}{
+		{"pacman.conf", "pacman.conf.multilib"},                 // uncomments the stock block
+		{"pacman-minimal.conf", "pacman-minimal.conf.multilib"}, // adds it after [extra]
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			conf := loadTestPacmanConf(t, tt.file)
+			if !conf.EnsureMultilib() {
+				t.Fatal("EnsureMultilib reported no change")
+			}
+			if got, want := conf.Repos(), []string{"core", "extra", "multilib"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("Repos() = %v, want %v", got, want)
+			}
+			checkGolden(t, tt.golden, conf.Bytes())
+
+			before := string(conf.Bytes())
+			if conf.EnsureMultilib() || string(conf.Bytes()) != before {
+				t.Error("second EnsureMultilib changed the file")
+			}
+		})
+	}
+}
+
+func TestAddCachyOSRepos(t *testing.T) {
+	tests := []struct {
+		file   string
+		level  int
+		golden string
+		repos  []string
+	}{
+		{"pacman.conf", 3, "pacman.conf.cachyos-v3",
+			[]string{"cachyos-v3", "cachyos-core-v3", "cachyos-extra-v3", "cachyos", "core", "extra"}},
+		{"pacman-minimal.conf", 1, "pacman-minimal.conf.cachyos",
+			[]string{"cachyos", "core", "extra"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			conf := loadTestPacmanConf(t, tt.file)
+			conf.AddCachyOSRepos(tt.level)
+			if !reflect.DeepEqual(conf.Repos(), tt.repos) {
+				t.Errorf("Repos() = %v, want %v", conf.Repos(), tt.repos)
+			}
+			checkGolden(t, tt.golden, conf.Bytes())
+
+			before := string(conf.Bytes())
+			conf.AddCachyOSRepos(tt.level)
+			if got := string(conf.Bytes()); got != before {
+				t.Errorf("second AddCachyOSRepos changed the file:\n%s", got)
+			}
+		})
+	}
+}
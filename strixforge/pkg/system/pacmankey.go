@@ -0,0 +1,77 @@
+package system
+
+import (
+	"context"
+	"fmt"
+)
+
+// DefaultKeyserver is used to fetch repository signing keys
+const DefaultKeyserver = "hkps://keyserver.ubuntu.com"
+
+// PacmanKey manages the pacman keyring
+type PacmanKey struct{}
+
+// NewPacmanKey creates a new PacmanKey instance
+func NewPacmanKey() *PacmanKey {
+	return &PacmanKey{}
+}
+
+// Init creates the keyring if it does not exist yet
+func (k *PacmanKey) Init(ctx context.Context) error {
+	if FileExists("/etc/pacman.d/gnupg/pubring.gpg") || FileExists("/etc/pacman.d/gnupg/pubring.kbx") {
+		return nil
+	}
+	result, err := ExecSudo(ctx, "pacman-key", "--init")
+	if err != nil {
+		return fmt.Errorf("pacman-key init failed: %s\n%s", err, result.Stderr)
+	}
+	return nil
+}
+
+// HasKey checks if a key is in the keyring
+func (k *PacmanKey) HasKey(ctx context.Context, keyID string) bool {
+	result, err := ExecSudo(ctx, "pacman-key", "--list-keys", keyID)
+	return err == nil && result.ExitCode == 0
+}
+
+// ImportKey fetches a key from keyserver and locally signs it so that
+// packages signed with it are trusted
+func (k *PacmanKey) ImportKey(ctx context.Context, keyID, keyserver string) error {
+	if keyserver == "" {
+		keyserver = DefaultKeyserver
+	}
+	result, err := ExecSudo(ctx, "pacman-key", "--recv-keys", keyID, "--keyserver", keyserver)
+	if err != nil {
+		return fmt.Errorf("failed to receive key %s: %s\n%s", keyID, err, result.Stderr)
+	}
+	result, err = ExecSudo(ctx, "pacman-key", "--lsign-key", keyID)
+	if err != nil {
+		return fmt.Errorf("failed to sign key %s: %s\n%s", keyID, err, result.Stderr)
+	}
+	return nil
+}
+
+// Populate adds the keys shipped by installed keyring packages (e.g. "archlinux")
+func (k *PacmanKey) Populate(ctx context.Context, keyrings ...string) error {
+	args := append([]string{"--populate"}, keyrings...)
+	result, err := ExecSudo(ctx, "pacman-key", args...)
+	if err != nil {
+		return fmt.Errorf("pacman-key populate failed: %s\n%s", err, result.Stderr)
+	}
+	return nil
+}
+
+// RefreshKeyrings upgrades the installed keyring packages first, so that a
+// stale keyring does not reject newer packages during a full update
+func (k *PacmanKey) RefreshKeyrings(ctx context.Context, pacman *Pacman) error {
+	keyrings := []string{"archlinux-keyring"}
+	if pacman.IsInstalled(ctx, "cachyos-keyring") {
+		keyrings = append(keyrings, "cachyos-keyring")
+	}
+	args := append([]string{"-Sy", "--needed", "--noconfirm"}, keyrings...)
+	result, err := pacman.run(ctx, args...)
+	if err != nil {
+		return pacmanError("keyring refresh", result, err)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PacmanLockPath is the database lock taken by every libalpm front-end
+const PacmanLockPath = "/var/lib/pacman/db.lck"
+
+// DefaultLockTimeout is how long Pacman waits for another package manager
+// to release the database lock before giving up
+var DefaultLockTimeout = 5 * time.Minute
+
+// lockPollInterval is how often the lock is re-checked while waiting
+const lockPollInterval = 2 * time.Second
+
+// lockNotifyInterval throttles LockWaitFunc callbacks
+const lockNotifyInterval = 10 * time.Second
+
+// packageManagerNames lists processes that may hold the pacman lock.
+// Any of these being alive means the lock must not be treated as stale.
+var packageManagerNames = []string{
+	"pacman",
+	"pamac",
+	"pamac-daemon",
+	"pamac-manager",
+	"packagekitd",
+	"yay",
+	"paru",
+	"pikaur",
+	"octopi",
+	"octopi-helper",
+}
+
+// LockHolder describes a process holding (or likely holding) the pacman lock
+type LockHolder struct {
+	PID       int
+	Command   string
+	Confirmed bool // true if the process has the lock file open
+}
+
+func (h LockHolder) String() string {
+	return fmt.Sprintf("%s (pid %d)", h.Command, h.PID)
+}
+
+// LockWaitFunc is called while Pacman waits for the database lock
+type LockWaitFunc func(holders []LockHolder, waited, limit time.Duration)
+
+// StaleLockFunc decides whether a stale lock file may be removed
+type StaleLockFunc func(lockPath string) bool
+
+// LockError is returned when the pacman database lock could not be acquired
+type LockError struct {
+	Path    string
+	Holders []LockHolder
+	Waited  time.Duration
+	Stale   bool
+}
+
+func (e *LockError) Error() string {
+	if e.Stale {
+		return fmt.Sprintf("pacman database is locked by a stale lock file (%s); no package manager is running, remove it with: sudo rm %s", e.Path, e.Path)
+	}
+	if len(e.Holders) == 0 {
+		return fmt.Sprintf("pacman database is locked (%s)", e.Path)
+	}
+	return fmt.Sprintf("pacman database still locked by %s after %v", formatHolders(e.Holders), e.Waited.Round(time.Second))
+}
+
+// IsPacmanLocked checks if the pacman database lock file exists
+func IsPacmanLocked() bool {
+	_, err := os.Stat(PacmanLockPath)
+	return err == nil
+}
+
+// FindLockHolders returns processes that hold the lock file open.
+// Without root, other users' file descriptors are unreadable, so any running
+// package manager is reported as a likely holder instead.
+func FindLockHolders(lockPath string) []LockHolder {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	var confirmed, likely []LockHolder
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		comm, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "comm"))
+		if err != nil {
+			continue
+		}
+		holder := LockHolder{PID: pid, Command: strings.TrimSpace(string(comm))}
+
+		if hasFileOpen(pid, lockPath) {
+			holder.Confirmed = true
+			confirmed = append(confirmed, holder)
+		} else if isPackageManager(holder.Command) {
+			likely = append(likely, holder)
+		}
+	}
+
+	if len(confirmed) > 0 {
+		return confirmed
+	}
+	return likely
+}
+
+// hasFileOpen checks the process's file descriptors for path
+func hasFileOpen(pid int, path string) bool {
+	fdDir := filepath.Join("/proc", strconv.Itoa(pid), "fd")
+	fds, err := os.ReadDir(fdDir)
+	if err != nil {
+		return false
+	}
+	for _, fd := range fds {
+		target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+		if err == nil && target == path {
+			return true
+		}
+	}
+	return false
+}
+
+func isPackageManager(command string) bool {
+	for _, name := range packageManagerNames {
+		if command == name {
+			return true
+		}
+	}
+	return false
+}
+
+func formatHolders(holders []LockHolder) string {
+	names := make([]string, len(holders))
+	for i, h := range holders {
+		names[i] = h.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// waitForLock blocks until the database lock is released, the timeout
+// expires or the context is cancelled. A lock with no live package manager
+// is only removed if the stale-lock callback approves it.
+func (p *Pacman) waitForLock(ctx context.Context) error {
+	if !IsPacmanLocked() {
+		return nil
+	}
+
+	start := time.Now()
+	var lastNotify time.Time
+
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+
+	for {
+		holders := FindLockHolders(PacmanLockPath)
+		waited := time.Since(start)
+
+		if len(holders) == 0 {
+			return p.clearStaleLock(ctx)
+		}
+
+		if waited >= p.lockTimeout {
+			return &LockError{Path: PacmanLockPath, Holders: holders, Waited: waited}
+		}
+
+		if p.onLockWait != nil && time.Since(lastNotify) >= lockNotifyInterval {
+			p.onLockWait(holders, waited, p.lockTimeout)
+			lastNotify = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if !IsPacmanLocked() {
+			return nil
+		}
+	}
+}
+
+// clearStaleLock removes a lock file left behind by a crashed package manager
+func (p *Pacman) clearStaleLock(ctx context.Context) error {
+	if p.confirmStale == nil || !p.confirmStale(PacmanLockPath) {
+		return &LockError{Path: PacmanLockPath, Stale: true}
+	}
+
+	// Re-check right before removal: a package manager may have started
+	// while the user was answering the prompt
+	if holders := FindLockHolders(PacmanLockPath); len(holders) > 0 {
+		return &LockError{Path: PacmanLockPath, Holders: holders}
+	}
+
+	result, err := ExecSudo(ctx, "rm", "-f", PacmanLockPath)
+	if err != nil {
+		return fmt.Errorf("failed to remove stale pacman lock: %s\n%s", err, result.Stderr)
+	}
+	return nil
+}
+
+// isLockFailure checks pacman stderr for a database lock error
+func isLockFailure(stderr string) bool {
+	return strings.Contains(stderr, "unable to lock database")
+}
@@ -0,0 +1,96 @@
+package system
+
+import (
+	"context"
+)
+
+// ContainerState is whether a container exists and is running
+type ContainerState string
+
+const (
+	ContainerMissing ContainerState = "missing"
+	ContainerStopped ContainerState = "stopped"
+	ContainerRunning ContainerState = "running"
+)
+
+// ContainerRuntime is the set of container operations the installer needs,
+// implemented by LXD (see workspace.LXDRuntime) and by the podman and
+// docker CLIs. Stages, snapshots, services and the container hub only use
+// this interface, so a fake runtime can stand in for a real one. Export,
+// fork and golden images need LXD and take its client instead.
+type ContainerRuntime interface {
+	// Name is the runtime as written in the config, e.g. "podman"
+	Name() string
+
+	// Ping checks that the runtime is installed and usable by this user
+	Ping(ctx context.Context) error
+
+	// List returns the containers the runtime manages for strixforge
+	List(ctx context.Context) ([]ContainerInfo, error)
+
+	State(ctx context.Context, name string) (ContainerState, error)
+	Create(ctx context.Context, cfg ContainerConfig) error
+	Start(ctx context.Context, name string) error
+	Stop(ctx context.Context, name string) error
+	Delete(ctx context.Context, name string) error
+
+	// Exec runs a command in a running container. Like Exec, a non-zero
+	// exit status is returned as an error together with the result.
+	Exec(ctx context.Context, name string, command ...string) (*ExecResult, error)
+
+	// Snapshot saves the container's current state under a name
+	Snapshot(ctx context.Context, name, snapshot string) error
+
+	// Snapshots returns the names of a container's snapshots, oldest first
+	Snapshots(ctx context.Context, name string) ([]string, error)
+
+	// DeleteSnapshot removes a snapshot. Missing snapshots are not an error.
+	DeleteSnapshot(ctx context.Context, name, snapshot string) error
+}
+
+// ContainerInfo is a container as listed by a runtime
+type ContainerInfo struct {
+	Name  string
+	State ContainerState
+	Image string
+}
+
+// ContainerConfig describes a container to create
+type ContainerConfig struct {
+	Name        string
+	Description string
+	Image       string
+	Environment map[string]string
+	GPU         bool
+	Ports       []PortMapping
+	Mounts      []Mount
+
+	// Limits; empty values leave the runtime default
+	CPU       string // CPU count, or a CPU set such as "0-7"
+	Memory    uint64 // bytes
+	Processes int
+
+	// LXD only, ignored by podman and docker: profiles after default,
+	// instance config and devices added to those above, and a callback for
+	// image download progress
+	Profiles []string
+	Config   map[string]string
+	Devices  map[string]map[string]string
+	Progress func(text string)
+}
+
+// PortMapping publishes a container port on the host's loopback address
+type PortMapping struct {
+	Host      int
+	Container int
+	Protocol  string // "tcp" or "udp"
+}
+
+// Mount maps a host directory, or a named volume when Volume is set, into
+// a container
+type Mount struct {
+	Source   string
+	Path     string
+	ReadOnly bool
+	Volume   bool
+}
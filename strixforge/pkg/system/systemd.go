@@ -1,33 +1,184 @@
 package system
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"os"
+	"os/user"
+	"strconv"
 	"strings"
+	"sync"
 )
 
-// Systemd provides service management operations
-type Systemd struct{}
+// ServiceManager controls systemd units
+type ServiceManager interface {
+	// Enable enables a unit (starts on boot)
+	Enable(ctx context.Context, unit string) error
+
+	// Disable disables a unit
+	Disable(ctx context.Context, unit string) error
+
+	// Start starts a unit and waits for the job to finish
+	Start(ctx context.Context, unit string) error
+
+	// Stop stops a unit and waits for the job to finish
+	Stop(ctx context.Context, unit string) error
+
+	// Restart restarts a unit and waits for the job to finish
+	Restart(ctx context.Context, unit string) error
+
+	// EnableAndStart enables and starts a unit
+	EnableAndStart(ctx context.Context, unit string) error
+
+	// DisableAndStop disables and stops a unit
+	DisableAndStop(ctx context.Context, unit string) error
+
+	// IsActive checks if a unit is running
+	IsActive(ctx context.Context, unit string) bool
+
+	// IsEnabled checks if a unit is enabled
+	IsEnabled(ctx context.Context, unit string) bool
+
+	// State returns the unit's load, active and sub state
+	State(ctx context.Context, unit string) (*UnitState, error)
+
+	// DaemonReload reloads unit files
+	DaemonReload(ctx context.Context) error
+}
+
+// UnitState describes a unit as systemd sees it
+type UnitState struct {
+	Name          string
+	LoadState     string // loaded, not-found, masked, ...
+	ActiveState   string // active, inactive, failed, ...
+	SubState      string // running, exited, dead, ...
+	UnitFileState string // enabled, disabled, static, ...
+	Result        string // service result: success, exit-code, signal, timeout, ...
+	ExitStatus    int    // main process exit status
+}
+
+// Exists returns true if systemd found a unit file
+func (s *UnitState) Exists() bool {
+	return s.LoadState != "" && s.LoadState != "not-found"
+}
+
+// Failed returns true if the unit is in the failed state
+func (s *UnitState) Failed() bool {
+	return s.ActiveState == "failed"
+}
+
+// FailureReason describes why a failed unit failed
+func (s *UnitState) FailureReason() string {
+	if !s.Failed() {
+		return ""
+	}
+	switch s.Result {
+	case "exit-code":
+		return fmt.Sprintf("main process exited with status %d", s.ExitStatus)
+	case "", "success":
+		return s.SubState
+	default:
+		return s.Result
+	}
+}
+
+// String formats the state like systemctl status
+func (s *UnitState) String() string {
+	state := fmt.Sprintf("%s (%s)", s.ActiveState, s.SubState)
+	if reason := s.FailureReason(); reason != "" {
+		state += ": " + reason
+	}
+	return state
+}
+
+var (
+	systemBusOnce   sync.Once
+	systemBusClient *SystemdDBus
+)
+
+// NewServiceManager returns a client for system units. It talks to systemd
+// over D-Bus when running as root and falls back to systemctl through sudo.
+// The D-Bus connection is shared by all callers.
+func NewServiceManager() ServiceManager {
+	if os.Geteuid() == 0 {
+		systemBusOnce.Do(func() {
+			systemBusClient, _ = NewSystemdDBus()
+		})
+		if systemBusClient != nil {
+			return systemBusClient
+		}
+	}
+	return NewSystemd()
+}
+
+// NewUserServiceManager returns a client for username's user units. D-Bus is
+// only used when running as that user; otherwise systemctl reaches the
+// user manager through --machine.
+func NewUserServiceManager(username string) ServiceManager {
+	if current, err := user.Current(); err == nil && current.Username == username {
+		if client, err := NewUserSystemdDBus(current.Uid); err == nil {
+			return client
+		}
+	}
+	return NewUserSystemd(username)
+}
+
+// Systemd provides service management operations via systemctl
+type Systemd struct {
+	user string // user scope target; empty for system units
+}
 
 // NewSystemd creates a new Systemd instance
 func NewSystemd() *Systemd {
 	return &Systemd{}
 }
 
+// NewUserSystemd creates a Systemd instance for a user's units
+func NewUserSystemd(username string) *Systemd {
+	return &Systemd{user: username}
+}
+
+// systemctl runs systemctl for the configured scope
+func (s *Systemd) systemctl(ctx context.Context, args ...string) (*ExecResult, error) {
+	if s.user != "" {
+		args = append([]string{"--user", "--machine", s.user + "@.host"}, args...)
+	}
+	return ExecSudo(ctx, "systemctl", args...)
+}
+
+// query runs a read-only systemctl command; system queries need no sudo
+func (s *Systemd) query(ctx context.Context, args ...string) (*ExecResult, error) {
+	if s.user != "" {
+		return s.systemctl(ctx, args...)
+	}
+	return Exec(ctx, "systemctl", args...)
+}
+
 // Enable enables a service (starts on boot)
 func (s *Systemd) Enable(ctx context.Context, service string) error {
-	result, err := ExecSudo(ctx, "systemctl", "enable", service)
+	result, err := s.systemctl(ctx, "enable", service)
 	if err != nil {
 		return fmt.Errorf("failed to enable %s: %s\n%s", service, err, result.Stderr)
 	}
 	return nil
 }
 
-// Start starts a service
+// Start starts a service. On failure the error is a *UnitError carrying
+// the unit's journal.
 func (s *Systemd) Start(ctx context.Context, service string) error {
-	result, err := ExecSudo(ctx, "systemctl", "start", service)
+	result, err := s.systemctl(ctx, "start", service)
+	if err != nil {
+		return unitError(ctx, service, fmt.Errorf("failed to start %s: %s\n%s", service, err, result.Stderr))
+	}
+	return nil
+}
+
+// Restart restarts a service
+func (s *Systemd) Restart(ctx context.Context, service string) error {
+	result, err := s.systemctl(ctx, "restart", service)
 	if err != nil {
-		return fmt.Errorf("failed to start %s: %s\n%s", service, err, result.Stderr)
+		return unitError(ctx, service, fmt.Errorf("failed to restart %s: %s\n%s", service, err, result.Stderr))
 	}
 	return nil
 }
@@ -40,9 +191,17 @@ func (s *Systemd) EnableAndStart(ctx context.Context, service string) error {
 	return s.Start(ctx, service)
 }
 
+// DisableAndStop disables and stops a service
+func (s *Systemd) DisableAndStop(ctx context.Context, service string) error {
+	if err := s.Disable(ctx, service); err != nil {
+		return err
+	}
+	return s.Stop(ctx, service)
+}
+
 // Stop stops a service
 func (s *Systemd) Stop(ctx context.Context, service string) error {
-	result, err := ExecSudo(ctx, "systemctl", "stop", service)
+	result, err := s.systemctl(ctx, "stop", service)
 	if err != nil {
 		return fmt.Errorf("failed to stop %s: %s\n%s", service, err, result.Stderr)
 	}
@@ -51,7 +210,7 @@ func (s *Systemd) Stop(ctx context.Context, service string) error {
 
 // Disable disables a service
 func (s *Systemd) Disable(ctx context.Context, service string) error {
-	result, err := ExecSudo(ctx, "systemctl", "disable", service)
+	result, err := s.systemctl(ctx, "disable", service)
 	if err != nil {
 		return fmt.Errorf("failed to disable %s: %s\n%s", service, err, result.Stderr)
 	}
@@ -60,19 +219,29 @@ func (s *Systemd) Disable(ctx context.Context, service string) error {
 
 // IsActive checks if a service is running
 func (s *Systemd) IsActive(ctx context.Context, service string) bool {
-	result, _ := Exec(ctx, "systemctl", "is-active", service)
-	return strings.TrimSpace(result.Stdout) == "active"
+	state, err := s.State(ctx, service)
+	return err == nil && state.ActiveState == "active"
 }
 
 // IsEnabled checks if a service is enabled
 func (s *Systemd) IsEnabled(ctx context.Context, service string) bool {
-	result, _ := Exec(ctx, "systemctl", "is-enabled", service)
-	return strings.TrimSpace(result.Stdout) == "enabled"
+	state, err := s.State(ctx, service)
+	return err == nil && state.UnitFileState == "enabled"
+}
+
+// State returns the unit state from `systemctl show`
+func (s *Systemd) State(ctx context.Context, service string) (*UnitState, error) {
+	result, err := s.query(ctx, "show", service,
+		"--property=LoadState,ActiveState,SubState,UnitFileState,Result,ExecMainStatus")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %s\n%s", service, err, result.Stderr)
+	}
+	return parseUnitState(service, result.Stdout), nil
 }
 
 // Status returns the status of a service
 func (s *Systemd) Status(ctx context.Context, service string) (string, error) {
-	result, err := Exec(ctx, "systemctl", "status", service)
+	result, err := s.query(ctx, "status", service)
 	if err != nil && result.ExitCode != 3 { // Exit 3 means service is stopped (valid)
 		return "", err
 	}
@@ -81,6 +250,36 @@ func (s *Systemd) Status(ctx context.Context, service string) (string, error) {
 
 // DaemonReload reloads systemd configuration
 func (s *Systemd) DaemonReload(ctx context.Context) error {
-	_, err := ExecSudo(ctx, "systemctl", "daemon-reload")
-	return err
+	result, err := s.systemctl(ctx, "daemon-reload")
+	if err != nil {
+		return fmt.Errorf("daemon-reload failed: %s\n%s", err, result.Stderr)
+	}
+	return nil
+}
+
+// parseUnitState parses Key=Value lines from `systemctl show`
+func parseUnitState(unit, output string) *UnitState {
+	state := &UnitState{Name: unit}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "LoadState":
+			state.LoadState = value
+		case "ActiveState":
+			state.ActiveState = value
+		case "SubState":
+			state.SubState = value
+		case "UnitFileState":
+			state.UnitFileState = value
+		case "Result":
+			state.Result = value
+		case "ExecMainStatus":
+			state.ExitStatus, _ = strconv.Atoi(value)
+		}
+	}
+	return state
 }
@@ -0,0 +1,253 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	systemdDest    = "org.freedesktop.systemd1"
+	systemdPath    = dbus.ObjectPath("/org/freedesktop/systemd1")
+	systemdManager = "org.freedesktop.systemd1.Manager"
+	systemdUnit    = "org.freedesktop.systemd1.Unit"
+	systemdService = "org.freedesktop.systemd1.Service"
+)
+
+// unitFileChange is one entry of the a(sss) list returned by
+// EnableUnitFiles and DisableUnitFiles
+type unitFileChange struct {
+	Type        string
+	Filename    string
+	Destination string
+}
+
+// unitTypes are the suffixes systemd recognises on unit names
+var unitTypes = []string{
+	".service", ".socket", ".device", ".mount", ".automount", ".swap",
+	".target", ".path", ".timer", ".slice", ".scope",
+}
+
+// unitName adds ".service" to a name without a unit type suffix. systemctl
+// does this on the client side; the bus API rejects bare names.
+func unitName(unit string) string {
+	for _, suffix := range unitTypes {
+		if strings.HasSuffix(unit, suffix) && len(unit) > len(suffix) {
+			return unit
+		}
+	}
+	return unit + ".service"
+}
+
+// SystemdDBus manages units through the systemd D-Bus API. Unlike the
+// systemctl wrapper, start, stop and restart wait for the queued job to
+// finish and report why it failed.
+type SystemdDBus struct {
+	conn *dbus.Conn
+}
+
+// NewSystemdDBus connects to the system manager
+func NewSystemdDBus() (*SystemdDBus, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to system bus: %v", err)
+	}
+	return newSystemdDBus(conn)
+}
+
+// NewUserSystemdDBus connects to the user manager of uid. The caller must
+// be running as that user.
+func NewUserSystemdDBus(uid string) (*SystemdDBus, error) {
+	conn, err := dbus.Connect(fmt.Sprintf("unix:path=/run/user/%s/bus", uid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to user bus: %v", err)
+	}
+	return newSystemdDBus(conn)
+}
+
+func newSystemdDBus(conn *dbus.Conn) (*SystemdDBus, error) {
+	s := &SystemdDBus{conn: conn}
+	// systemd only emits job signals to subscribed clients
+	if err := s.call(context.Background(), "Subscribe").Err; err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to subscribe to systemd: %v", err)
+	}
+	return s, nil
+}
+
+// Close closes the bus connection
+func (s *SystemdDBus) Close() error {
+	return s.conn.Close()
+}
+
+// call invokes a manager method
+func (s *SystemdDBus) call(ctx context.Context, method string, args ...interface{}) *dbus.Call {
+	return s.conn.Object(systemdDest, systemdPath).CallWithContext(ctx, systemdManager+"."+method, 0, args...)
+}
+
+// runJob queues a unit job and waits for systemd to report its result
+func (s *SystemdDBus) runJob(ctx context.Context, verb, method, unit string) error {
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(systemdPath),
+		dbus.WithMatchInterface(systemdManager),
+		dbus.WithMatchMember("JobRemoved"),
+	}
+	if err := s.conn.AddMatchSignalContext(ctx, match...); err != nil {
+		return fmt.Errorf("failed to %s %s: %v", verb, unit, err)
+	}
+	defer s.conn.RemoveMatchSignal(match...)
+
+	// Listen before queueing so a fast job cannot finish unseen
+	signals := make(chan *dbus.Signal, 16)
+	s.conn.Signal(signals)
+	defer s.conn.RemoveSignal(signals)
+
+	var job dbus.ObjectPath
+	if err := s.call(ctx, method, unit, "replace").Store(&job); err != nil {
+		return fmt.Errorf("failed to %s %s: %v", verb, unit, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to %s %s: %v", verb, unit, ctx.Err())
+		case sig, ok := <-signals:
+			if !ok {
+				return fmt.Errorf("failed to %s %s: bus connection closed", verb, unit)
+			}
+			if sig.Name != systemdManager+".JobRemoved" || len(sig.Body) < 4 {
+				continue
+			}
+			if path, _ := sig.Body[1].(dbus.ObjectPath); path != job {
+				continue
+			}
+			result, _ := sig.Body[3].(string)
+			if result == "done" {
+				return nil
+			}
+			return s.jobError(ctx, verb, unit, result)
+		}
+	}
+}
+
+// jobError describes a failed job with the unit's resulting state
+func (s *SystemdDBus) jobError(ctx context.Context, verb, unit, result string) error {
+	state, err := s.State(ctx, unit)
+	if err != nil || !state.Exists() {
+		return fmt.Errorf("failed to %s %s: job %s", verb, unit, result)
+	}
+	return fmt.Errorf("failed to %s %s: job %s, unit is %s", verb, unit, result, state)
+}
+
+// Enable enables a unit and reloads the manager, as systemctl does
+func (s *SystemdDBus) Enable(ctx context.Context, unit string) error {
+	unit = unitName(unit)
+	var carriesInstallInfo bool
+	var changes []unitFileChange
+	if err := s.call(ctx, "EnableUnitFiles", []string{unit}, false, false).Store(&carriesInstallInfo, &changes); err != nil {
+		return fmt.Errorf("failed to enable %s: %v", unit, err)
+	}
+	return s.DaemonReload(ctx)
+}
+
+// Disable disables a unit
+func (s *SystemdDBus) Disable(ctx context.Context, unit string) error {
+	unit = unitName(unit)
+	var changes []unitFileChange
+	if err := s.call(ctx, "DisableUnitFiles", []string{unit}, false).Store(&changes); err != nil {
+		return fmt.Errorf("failed to disable %s: %v", unit, err)
+	}
+	return s.DaemonReload(ctx)
+}
+
+// Start starts a unit and waits for the job. On failure the error is a
+// *UnitError carrying the unit's journal.
+func (s *SystemdDBus) Start(ctx context.Context, unit string) error {
+	unit = unitName(unit)
+	return unitError(ctx, unit, s.runJob(ctx, "start", "StartUnit", unit))
+}
+
+// Stop stops a unit and waits for the job
+func (s *SystemdDBus) Stop(ctx context.Context, unit string) error {
+	unit = unitName(unit)
+	return s.runJob(ctx, "stop", "StopUnit", unit)
+}
+
+// Restart restarts a unit and waits for the job
+func (s *SystemdDBus) Restart(ctx context.Context, unit string) error {
+	unit = unitName(unit)
+	return unitError(ctx, unit, s.runJob(ctx, "restart", "RestartUnit", unit))
+}
+
+// EnableAndStart enables and starts a unit
+func (s *SystemdDBus) EnableAndStart(ctx context.Context, unit string) error {
+	if err := s.Enable(ctx, unit); err != nil {
+		return err
+	}
+	return s.Start(ctx, unit)
+}
+
+// DisableAndStop disables and stops a unit
+func (s *SystemdDBus) DisableAndStop(ctx context.Context, unit string) error {
+	if err := s.Disable(ctx, unit); err != nil {
+		return err
+	}
+	return s.Stop(ctx, unit)
+}
+
+// IsActive checks if a unit is running
+func (s *SystemdDBus) IsActive(ctx context.Context, unit string) bool {
+	state, err := s.State(ctx, unit)
+	return err == nil && state.ActiveState == "active"
+}
+
+// IsEnabled checks if a unit is enabled
+func (s *SystemdDBus) IsEnabled(ctx context.Context, unit string) bool {
+	state, err := s.State(ctx, unit)
+	return err == nil && state.UnitFileState == "enabled"
+}
+
+// State loads a unit and reads its state properties
+func (s *SystemdDBus) State(ctx context.Context, unit string) (*UnitState, error) {
+	unit = unitName(unit)
+	var path dbus.ObjectPath
+	if err := s.call(ctx, "LoadUnit", unit).Store(&path); err != nil {
+		return nil, fmt.Errorf("failed to query %s: %v", unit, err)
+	}
+	obj := s.conn.Object(systemdDest, path)
+
+	state := &UnitState{Name: unit}
+	for prop, dst := range map[string]*string{
+		"LoadState":     &state.LoadState,
+		"ActiveState":   &state.ActiveState,
+		"SubState":      &state.SubState,
+		"UnitFileState": &state.UnitFileState,
+	} {
+		v, err := obj.GetProperty(systemdUnit + "." + prop)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s of %s: %v", prop, unit, err)
+		}
+		*dst, _ = v.Value().(string)
+	}
+
+	if strings.HasSuffix(unit, ".service") && state.Exists() {
+		if v, err := obj.GetProperty(systemdService + ".Result"); err == nil {
+			state.Result, _ = v.Value().(string)
+		}
+		if v, err := obj.GetProperty(systemdService + ".ExecMainStatus"); err == nil {
+			status, _ := v.Value().(int32)
+			state.ExitStatus = int(status)
+		}
+	}
+	return state, nil
+}
+
+// DaemonReload reloads unit files
+func (s *SystemdDBus) DaemonReload(ctx context.Context) error {
+	if err := s.call(ctx, "Reload").Err; err != nil {
+		return fmt.Errorf("daemon-reload failed: %v", err)
+	}
+	return nil
+}
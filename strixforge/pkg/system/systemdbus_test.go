@@ -0,0 +1,23 @@
+package system
+
+import "testing"
+
+func TestUnitName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"nordvpnd", "nordvpnd.service"},
+		{"nordvpnd.service", "nordvpnd.service"},
+		{"strixforge-snapshots.timer", "strixforge-snapshots.timer"},
+		{"lxd.socket", "lxd.socket"},
+		{"multi-user.target", "multi-user.target"},
+		{"getty@tty1", "getty@tty1.service"},
+		{"org.freedesktop.thermald", "org.freedesktop.thermald.service"}, // dots, but no unit type
+		{"power-profiles-daemon.serv", "power-profiles-daemon.serv.service"},
+	}
+	for _, tt := range tests {
+		if got := unitName(tt.in); got != tt.want {
+			t.Errorf("unitName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// ManagedMarker is the first line of every file the installer owns. Files
+// without it are never overwritten or removed.
+const ManagedMarker = "# Managed by strixforge; local changes will be overwritten"
+
+// Where managed files are installed
+const (
+	SystemUnitDir = "/etc/systemd/system"
+	TmpfilesDir   = "/etc/tmpfiles.d"
+)
+
+// ManagedFileKind tells the unit manager how to activate a file
+type ManagedFileKind int
+
+const (
+	KindUnit ManagedFileKind = iota
+	KindDropIn
+	KindTmpfiles
+)
+
+// ManagedFile is a systemd unit, drop-in or tmpfiles.d snippet rendered
+// from a Go template
+type ManagedFile struct {
+	Kind     ManagedFileKind
+	Path     string
+	Template string
+	Data     interface{}
+
+	// Enable enables and starts a unit (or timer) after it is installed
+	Enable bool
+}
+
+// Unit returns a unit file such as "ollama.service" or "strixforge-resume.timer"
+func Unit(name, tmpl string, data interface{}) ManagedFile {
+	return ManagedFile{Kind: KindUnit, Path: filepath.Join(SystemUnitDir, name), Template: tmpl, Data: data}
+}
+
+// DropIn returns a drop-in that overrides parts of an existing unit
+func DropIn(unit, name, tmpl string, data interface{}) ManagedFile {
+	return ManagedFile{Kind: KindDropIn, Path: filepath.Join(SystemUnitDir, unit+".d", name+".conf"), Template: tmpl, Data: data}
+}
+
+// Tmpfiles returns a tmpfiles.d snippet
+func Tmpfiles(name, tmpl string, data interface{}) ManagedFile {
+	return ManagedFile{Kind: KindTmpfiles, Path: filepath.Join(TmpfilesDir, name+".conf"), Template: tmpl, Data: data}
+}
+
+// Name returns the unit name for unit files
+func (f ManagedFile) Name() string {
+	return filepath.Base(f.Path)
+}
+
+// Render executes the template and prepends the ownership marker
+func (f ManagedFile) Render() ([]byte, error) {
+	tmpl, err := template.New(f.Name()).Option("missingkey=error").Parse(f.Template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template for %s: %v", f.Path, err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(ManagedMarker + "\n")
+	if err := tmpl.Execute(&buf, f.Data); err != nil {
+		return nil, fmt.Errorf("failed to render %s: %v", f.Path, err)
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// ChangeAction is what installing a managed file would do
+type ChangeAction string
+
+const (
+	ChangeCreate    ChangeAction = "create"
+	ChangeUpdate    ChangeAction = "update"
+	ChangeUnchanged ChangeAction = "unchanged"
+	ChangeConflict  ChangeAction = "conflict" // exists but is not ours
+)
+
+// FileChange compares a rendered file with what is installed
+type FileChange struct {
+	File    ManagedFile
+	Action  ChangeAction
+	Diff    string // line diff of installed vs rendered content
+	content []byte
+}
+
+// UnitManager installs and removes managed files
+type UnitManager struct {
+	services ServiceManager
+}
+
+// NewUnitManager creates a unit manager that activates units through services
+func NewUnitManager(services ServiceManager) *UnitManager {
+	return &UnitManager{services: services}
+}
+
+// Diff renders files and compares them with the installed copies
+func (m *UnitManager) Diff(files ...ManagedFile) ([]FileChange, error) {
+	changes := make([]FileChange, 0, len(files))
+	for _, f := range files {
+		content, err := f.Render()
+		if err != nil {
+			return nil, err
+		}
+
+		change := FileChange{File: f, content: content}
+		existing, err := os.ReadFile(f.Path)
+		switch {
+		case os.IsNotExist(err):
+			change.Action = ChangeCreate
+			change.Diff = lineDiff("", string(content))
+		case err != nil:
+			return nil, fmt.Errorf("failed to read %s: %v", f.Path, err)
+		case !isManaged(existing):
+			change.Action = ChangeConflict
+		case bytes.Equal(existing, content):
+			change.Action = ChangeUnchanged
+		default:
+			change.Action = ChangeUpdate
+			change.Diff = lineDiff(string(existing), string(content))
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// Install writes changed files atomically, reloads systemd and applies
+// tmpfiles snippets as needed, then enables units marked Enable. Files
+// that exist without the ownership marker are left alone and reported as
+// conflicts.
+func (m *UnitManager) Install(ctx context.Context, files ...ManagedFile) ([]FileChange, error) {
+	changes, err := m.Diff(files...)
+	if err != nil {
+		return nil, err
+	}
+
+	reload := false
+	var conflicts []string
+	for _, c := range changes {
+		switch c.Action {
+		case ChangeConflict:
+			conflicts = append(conflicts, c.File.Path)
+			continue
+		case ChangeUnchanged:
+			continue
+		}
+
+		if result, err := ExecSudo(ctx, "mkdir", "-p", filepath.Dir(c.File.Path)); err != nil {
+			return changes, fmt.Errorf("failed to create %s: %s\n%s", filepath.Dir(c.File.Path), err, result.Stderr)
+		}
+		if err := WriteFileSudo(ctx, c.File.Path, c.content, 0644); err != nil {
+			return changes, err
+		}
+
+		switch c.File.Kind {
+		case KindUnit, KindDropIn:
+			reload = true
+		case KindTmpfiles:
+			if result, err := ExecSudo(ctx, "systemd-tmpfiles", "--create", c.File.Path); err != nil {
+				return changes, fmt.Errorf("failed to apply %s: %s\n%s", c.File.Path, err, result.Stderr)
+			}
+		}
+	}
+
+	if reload {
+		if err := m.services.DaemonReload(ctx); err != nil {
+			return changes, err
+		}
+	}
+
+	for _, c := range changes {
+		if c.File.Kind == KindUnit && c.File.Enable && c.Action != ChangeConflict {
+			if err := m.services.EnableAndStart(ctx, c.File.Name()); err != nil {
+				return changes, err
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return changes, fmt.Errorf("not overwriting files that strixforge does not manage: %s", strings.Join(conflicts, ", "))
+	}
+	return changes, nil
+}
+
+// Remove disables and deletes managed files. Files without the ownership
+// marker are skipped; emptied drop-in directories are removed too.
+func (m *UnitManager) Remove(ctx context.Context, files ...ManagedFile) error {
+	reload := false
+	for _, f := range files {
+		existing, err := os.ReadFile(f.Path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", f.Path, err)
+		}
+		if !isManaged(existing) {
+			continue
+		}
+
+		if f.Kind == KindUnit && f.Enable {
+			if err := m.services.DisableAndStop(ctx, f.Name()); err != nil {
+				return err
+			}
+		}
+
+		if result, err := ExecSudo(ctx, "rm", "-f", f.Path); err != nil {
+			return fmt.Errorf("failed to remove %s: %s\n%s", f.Path, err, result.Stderr)
+		}
+		if f.Kind == KindDropIn {
+			// Only succeeds if no other drop-ins remain
+			_, _ = ExecSudo(ctx, "rmdir", filepath.Dir(f.Path))
+		}
+		if f.Kind != KindTmpfiles {
+			reload = true
+		}
+	}
+
+	if reload {
+		return m.services.DaemonReload(ctx)
+	}
+	return nil
+}
+
+// isManaged checks for the ownership marker
+func isManaged(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(ManagedMarker+"\n"))
+}
+
+// lineDiff returns a minimal line diff, prefixing removed lines with "-",
+// added lines with "+" and common lines with " "
+func lineDiff(a, b string) string {
+	x := splitLines(a)
+	y := splitLines(b)
+
+	// Longest common subsequence table, filled from the end
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			sb.WriteString(" " + x[i] + "\n")
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("-" + x[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+" + y[j] + "\n")
+			j++
+		}
+	}
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,267 @@
+package workspace
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/lxd"
+)
+
+// keyHubImages lists the hub images installed into a container, as
+// "toolbox=image" pairs
+const keyHubImages = "user.strixforge.hub-images"
+
+// manifestVersion is bumped when the manifest format changes incompatibly
+const manifestVersion = 1
+
+// HubImage is a Container Hub image installed into a container
+type HubImage struct {
+	Toolbox string `yaml:"toolbox"`
+	Image   string `yaml:"image"`
+}
+
+// Manifest describes an exported container. It is written next to the
+// backup tarball so the container can be understood, or rebuilt from its
+// spec, without importing it.
+type Manifest struct {
+	Version          int                   `yaml:"version"`
+	Container        string                `yaml:"container"`
+	Exported         time.Time             `yaml:"exported"`
+	Backup           string                `yaml:"backup"`
+	SHA256           string                `yaml:"sha256"`
+	Size             int64                 `yaml:"size"`
+	StorageDriver    string                `yaml:"storage-driver"`
+	OptimizedStorage bool                  `yaml:"optimized-storage"`
+	Spec             *config.ContainerSpec `yaml:"spec,omitempty"`
+	Packages         []string              `yaml:"packages,omitempty"`
+	HubImages        []HubImage            `yaml:"hub-images,omitempty"`
+}
+
+// RecordHubImage notes a hub image install in the container's config so
+// exports can list it
+func RecordHubImage(ctx context.Context, client *lxd.Client, instance, toolbox, image string) error {
+	current, err := client.Instance(ctx, instance)
+	if err != nil {
+		return err
+	}
+	entry := toolbox + "=" + image
+	installed := splitList(current.Config[keyHubImages])
+	for _, existing := range installed {
+		if existing == entry {
+			return nil
+		}
+	}
+	put := current.Writable()
+	put.Config = copyConfig(put.Config)
+	put.Config[keyHubImages] = strings.Join(append(installed, entry), ",")
+	return client.UpdateInstance(ctx, instance, put)
+}
+
+// hubImages reads the hub images recorded on an instance
+func hubImages(instance *lxd.Instance) []HubImage {
+	var images []HubImage
+	for _, entry := range splitList(instance.Config[keyHubImages]) {
+		toolbox, image, _ := strings.Cut(entry, "=")
+		images = append(images, HubImage{Toolbox: toolbox, Image: image})
+	}
+	return images
+}
+
+// Exporter writes container backups to a directory and reads them back
+type Exporter struct {
+	client *lxd.Client
+}
+
+// NewExporter creates an exporter using an LXD client
+func NewExporter(client *lxd.Client) *Exporter {
+	return &Exporter{client: client}
+}
+
+// Export backs a container up into dir as NAME-TIMESTAMP.tar.gz plus a
+// NAME-TIMESTAMP.yaml manifest, and returns the manifest path. Optimized
+// storage is used when the container's pool supports it. spec may be nil
+// for containers not declared in the config.
+func (e *Exporter) Export(ctx context.Context, name, dir string, spec *config.ContainerSpec, progress lxd.ProgressFunc) (string, *Manifest, error) {
+	instance, err := e.client.Instance(ctx, name)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to look up %s: %v", name, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", nil, err
+	}
+
+	manifest := &Manifest{
+		Version:   manifestVersion,
+		Container: name,
+		Exported:  time.Now().UTC().Truncate(time.Second),
+		Spec:      spec,
+		HubImages: hubImages(instance),
+	}
+	if pool := instance.ExpandedDevices["root"]["pool"]; pool != "" {
+		if p, err := e.client.StoragePool(ctx, pool); err == nil {
+			manifest.StorageDriver = p.Driver
+			manifest.OptimizedStorage = p.OptimizedBackups()
+		}
+	}
+	if instance.Running() {
+		// Explicitly installed packages, to rebuild from if the tarball is lost
+		if result, err := e.client.Exec(ctx, name, "pacman", "-Qqe"); err == nil {
+			manifest.Packages = strings.Fields(result.Stdout)
+		}
+	}
+
+	// The server-side backup is only needed until it has been downloaded
+	backup := "strixforge-export-" + manifest.Exported.Format(snapshotTimeFormat)
+	expires := time.Now().Add(24 * time.Hour)
+	err = e.client.CreateBackup(ctx, name, lxd.BackupsPost{
+		Name:                 backup,
+		ExpiresAt:            &expires,
+		OptimizedStorage:     manifest.OptimizedStorage,
+		CompressionAlgorithm: "gzip",
+	}, progress)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create backup of %s: %v", name, err)
+	}
+	defer e.client.DeleteBackup(context.WithoutCancel(ctx), name, backup)
+
+	base := name + "-" + manifest.Exported.Format(snapshotTimeFormat)
+	manifest.Backup = base + ".tar.gz"
+	tarball := filepath.Join(dir, manifest.Backup)
+	tmp := tarball + ".partial"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", nil, err
+	}
+	hash := sha256.New()
+	manifest.Size, err = e.client.ExportBackup(ctx, name, backup, io.MultiWriter(f, hash))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", nil, fmt.Errorf("failed to download backup of %s: %v", name, err)
+	}
+	if err := os.Rename(tmp, tarball); err != nil {
+		return "", nil, err
+	}
+	manifest.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		return "", nil, err
+	}
+	manifestPath := filepath.Join(dir, base+".yaml")
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		return "", nil, err
+	}
+	return manifestPath, manifest, nil
+}
+
+// LoadManifest reads an export manifest
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if m.Version > manifestVersion {
+		return nil, fmt.Errorf("%s was written by a newer strixforge (manifest version %d)", path, m.Version)
+	}
+	if m.Spec != nil {
+		m.Spec.Name = m.Container
+	}
+	return &m, nil
+}
+
+// Import restores an export from its manifest, verifying the tarball
+// against the manifest's checksum first unless verify is false; a manifest
+// without a checksum is refused when verifying. If a container with the original name exists, the import gets a
+// new name: as if given, otherwise NAME-restored, NAME-restored-2 and so on.
+// Returns the name of the restored container.
+func (e *Exporter) Import(ctx context.Context, manifestPath, as string, verify bool, progress lxd.ProgressFunc) (string, error) {
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		return "", err
+	}
+	tarball := filepath.Join(filepath.Dir(manifestPath), manifest.Backup)
+	if verify {
+		if err := verifyChecksum(tarball, manifest.SHA256); err != nil {
+			return "", err
+		}
+	}
+
+	name := as
+	if name == "" {
+		if name, err = e.freeName(ctx, manifest.Container); err != nil {
+			return "", err
+		}
+	} else if exists, err := e.client.InstanceExists(ctx, name); err != nil {
+		return "", err
+	} else if exists {
+		return "", fmt.Errorf("container %s already exists", name)
+	}
+
+	f, err := os.Open(tarball)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := e.client.ImportBackup(ctx, f, name, "", progress); err != nil {
+		if manifest.OptimizedStorage {
+			return "", fmt.Errorf("failed to import %s: %v (optimized %s backups only import into a %s pool)", tarball, err, manifest.StorageDriver, manifest.StorageDriver)
+		}
+		return "", fmt.Errorf("failed to import %s: %v", tarball, err)
+	}
+	return name, nil
+}
+
+// freeName returns name, or the first NAME-restored[-N] not in use
+func (e *Exporter) freeName(ctx context.Context, name string) (string, error) {
+	candidate := name
+	for i := 1; ; i++ {
+		exists, err := e.client.InstanceExists(ctx, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+		candidate = name + "-restored"
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-restored-%d", name, i)
+		}
+	}
+}
+
+// verifyChecksum checks a file against the SHA-256 in its manifest
+func verifyChecksum(path, want string) error {
+	if want == "" {
+		return fmt.Errorf("the manifest for %s has no sha256 checksum; use --no-verify to import it unchecked", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return fmt.Errorf("%s is corrupt: checksum %s does not match manifest", path, got)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package workspace
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/core"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+)
+
+// keyUserData is the instance config key LXD hands to cloud-init
+const keyUserData = "cloud-init.user-data"
+
+// envFile holds the spec's environment for login shells. LXD's
+// environment.* keys only reach processes started through LXD.
+const envFile = "/etc/profile.d/strixforge-env.sh"
+
+// cloudInitDeadline allows for package installs during first boot; the
+// ROCm stack alone is several gigabytes
+const cloudInitDeadline = 45 * time.Minute
+
+// cloudConfig is the subset of cloud-config the specs use
+type cloudConfig struct {
+	PackageUpdate  bool             `yaml:"package_update,omitempty"`
+	PackageUpgrade bool             `yaml:"package_upgrade,omitempty"`
+	Packages       []string         `yaml:"packages,omitempty"`
+	Users          []interface{}    `yaml:"users,omitempty"`
+	WriteFiles     []cloudWriteFile `yaml:"write_files,omitempty"`
+	Runcmd         []string         `yaml:"runcmd,omitempty"`
+}
+
+type cloudUser struct {
+	Name              string   `yaml:"name"`
+	Groups            string   `yaml:"groups,omitempty"`
+	Shell             string   `yaml:"shell,omitempty"`
+	Sudo              string   `yaml:"sudo,omitempty"`
+	SSHAuthorizedKeys []string `yaml:"ssh_authorized_keys,omitempty"`
+}
+
+type cloudWriteFile struct {
+	Path        string `yaml:"path"`
+	Content     string `yaml:"content"`
+	Permissions string `yaml:"permissions,omitempty"`
+	Owner       string `yaml:"owner,omitempty"`
+	Defer       bool   `yaml:"defer,omitempty"`
+}
+
+// RenderUserData renders a spec as cloud-config user-data: users, packages,
+// files (including the environment) and setup commands
+func RenderUserData(spec config.ContainerSpec) (string, error) {
+	// On Arch, refreshing the database without upgrading before installing
+	// is a partial upgrade, so both are set, like pacman -Syu in the exec path
+	cc := cloudConfig{
+		PackageUpdate:  len(spec.Packages) > 0,
+		PackageUpgrade: len(spec.Packages) > 0,
+		Packages:       spec.Packages,
+		Runcmd:         spec.Setup,
+	}
+	if len(spec.Users) > 0 {
+		cc.Users = append(cc.Users, "default")
+	}
+	for _, u := range spec.Users {
+		user := cloudUser{Name: u.Name, Groups: strings.Join(u.Groups, ","), Shell: u.Shell, SSHAuthorizedKeys: u.SSHKeys}
+		if u.Sudo {
+			user.Sudo = "ALL=(ALL) NOPASSWD:ALL"
+		}
+		cc.Users = append(cc.Users, user)
+	}
+	for _, f := range allFiles(spec) {
+		// Files owned by spec users must wait until the users exist
+		cc.WriteFiles = append(cc.WriteFiles, cloudWriteFile{
+			Path: f.Path, Content: f.Content, Permissions: f.Permissions, Owner: f.Owner,
+			Defer: f.Owner != "" && !strings.HasPrefix(f.Owner, "root"),
+		})
+	}
+
+	data, err := yaml.Marshal(cc)
+	if err != nil {
+		return "", err
+	}
+	return "#cloud-config\n" + string(data), nil
+}
+
+// allFiles returns the spec's files plus the environment file
+func allFiles(spec config.ContainerSpec) []config.FileSpec {
+	files := append([]config.FileSpec(nil), spec.Files...)
+	if len(spec.Environment) == 0 {
+		return files
+	}
+	keys := make([]string, 0, len(spec.Environment))
+	for key := range spec.Environment {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "export %s=%s\n", key, shellQuote(spec.Environment[key]))
+	}
+	return append(files, config.FileSpec{Path: envFile, Content: b.String(), Permissions: "0644"})
+}
+
+// shellQuote single-quotes a value for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// cloudInitDiagnostics collects cloud-init's status and the end of its
+// output log, to explain a failed first boot
+func cloudInitDiagnostics(ctx context.Context, runtime system.ContainerRuntime, name string) []core.LogEntry {
+	var logs []core.LogEntry
+	add := func(text string) {
+		for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+			logs = append(logs, core.LogEntry{Time: time.Now(), Level: core.LogError, Message: line})
+		}
+	}
+	if result, _ := runtime.Exec(ctx, name, "cloud-init", "status", "--long"); result != nil {
+		add(result.Stdout)
+	}
+	if result, _ := runtime.Exec(ctx, name, "tail", "-n", "30", "/var/log/cloud-init-output.log"); result != nil {
+		add(result.Stdout)
+	}
+	return logs
+}
+
+// createUsers adds the spec's users by exec, when cloud-init is not used
+func (r *Reconciler) createUsers(ctx context.Context, ui core.UI, spec config.ContainerSpec) bool {
+	ok := true
+	for _, u := range spec.Users {
+		script := fmt.Sprintf("id -u %[1]s >/dev/null 2>&1 || useradd -m %[1]s", u.Name)
+		if u.Shell != "" {
+			script += fmt.Sprintf("; usermod -s %s %s", shellQuote(u.Shell), u.Name)
+		}
+		if len(u.Groups) > 0 {
+			script += fmt.Sprintf("; usermod -aG %s %s", shellQuote(strings.Join(u.Groups, ",")), u.Name)
+		}
+		if u.Sudo {
+			script += fmt.Sprintf("; echo '%[1]s ALL=(ALL) NOPASSWD:ALL' > /etc/sudoers.d/90-%[1]s", u.Name)
+		}
+		if len(u.SSHKeys) > 0 {
+			keys := base64.StdEncoding.EncodeToString([]byte(strings.Join(u.SSHKeys, "\n") + "\n"))
+			script += fmt.Sprintf("; install -d -m 700 -o %[1]s ~%[1]s/.ssh && echo %[2]s | base64 -d > ~%[1]s/.ssh/authorized_keys && chown %[1]s: ~%[1]s/.ssh/authorized_keys", u.Name, keys)
+		}
+		if _, err := r.runtime.Exec(ctx, spec.Name, "sh", "-c", script); err != nil {
+			ok = false
+			ui.Log(core.LogWarn, fmt.Sprintf("Failed to create user %s: %v", u.Name, err))
+		}
+	}
+	return ok
+}
+
+// writeFiles writes the spec's files by exec, when cloud-init is not used
+func (r *Reconciler) writeFiles(ctx context.Context, ui core.UI, spec config.ContainerSpec) bool {
+	ok := true
+	for _, f := range allFiles(spec) {
+		content := base64.StdEncoding.EncodeToString([]byte(f.Content))
+		script := fmt.Sprintf("mkdir -p \"$(dirname %[1]s)\" && echo %[2]s | base64 -d > %[1]s", shellQuote(f.Path), content)
+		if f.Permissions != "" {
+			script += fmt.Sprintf(" && chmod %s %s", shellQuote(f.Permissions), shellQuote(f.Path))
+		}
+		if f.Owner != "" {
+			script += fmt.Sprintf(" && chown %s %s", shellQuote(f.Owner), shellQuote(f.Path))
+		}
+		if _, err := r.runtime.Exec(ctx, spec.Name, "sh", "-c", script); err != nil {
+			ok = false
+			ui.Log(core.LogWarn, fmt.Sprintf("Failed to write %s: %v", f.Path, err))
+		}
+	}
+	return ok
+}
@@ -0,0 +1,286 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/core"
+	"github.com/daveweinstein1/strixforge/pkg/lxd"
+)
+
+// keyForkOf records the container a fork was copied from
+const keyForkOf = "user.strixforge.fork-of"
+
+// PortRemap is a forwarded host port moved to avoid a clash
+type PortRemap struct {
+	Device string `json:"device"`
+	From   int    `json:"from"`
+	To     int    `json:"to"`
+}
+
+// ForkResult describes a new fork
+type ForkResult struct {
+	Name     string      `json:"name"`
+	Source   string      `json:"source"`
+	Snapshot string      `json:"snapshot"` // the source state the fork was copied from
+	Ports    []PortRemap `json:"ports,omitempty"`
+}
+
+// ForkOf returns the container an instance was forked from, or "" if it is
+// not a fork
+func ForkOf(instance *lxd.Instance) string {
+	return instance.Config[keyForkOf]
+}
+
+// Fork copies a container under a new name for experiments. The copy is
+// taken from a fresh snapshot of the source, which on ZFS and Btrfs pools
+// makes it a copy-on-write clone. It gets the same profiles and volumes, a
+// new MAC address and new host ports for its proxy devices, and is started
+// if the source is running.
+func Fork(ctx context.Context, ui core.UI, client *lxd.Client, source, name string) (*ForkResult, error) {
+	if exists, err := client.InstanceExists(ctx, name); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, fmt.Errorf("container %s already exists", name)
+	}
+	src, err := client.Instance(ctx, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %v", source, err)
+	}
+	taken, err := usedProxyPorts(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := NewSnapshotter(clientRuntime(client)).Take(ctx, source, SnapshotBeforeChange, "before forking to "+name)
+	if err != nil {
+		return nil, err
+	}
+	result := &ForkResult{Name: name, Source: source, Snapshot: snapshot}
+
+	// Volatile keys hold the MAC address and idmap; LXD regenerates them
+	cfg := make(map[string]string)
+	for key, value := range src.Config {
+		if !strings.HasPrefix(key, "volatile.") {
+			cfg[key] = value
+		}
+	}
+	cfg[keyForkOf] = source
+
+	devices := copyDevices(src.Devices)
+	for device, d := range devices {
+		if d["type"] != "proxy" {
+			continue
+		}
+		port, err := parseListen(d["listen"])
+		if err != nil {
+			return nil, fmt.Errorf("%s device %s: %v", source, device, err)
+		}
+		from := port.Host
+		port.Host = freePort(port, taken)
+		taken[proxyPortKey(port)] = true
+		d["listen"] = withListenPort(d["listen"], port.Host)
+		result.Ports = append(result.Ports, PortRemap{Device: device, From: from, To: port.Host})
+	}
+
+	description := fmt.Sprintf("Fork of %s", source)
+	if src.Description != "" {
+		description = fmt.Sprintf("%s (fork of %s)", src.Description, source)
+	}
+	ui.Log(core.LogInfo, fmt.Sprintf("Copying %s/%s to %s...", source, snapshot, name))
+	err = client.CreateInstance(ctx, lxd.InstancesPost{
+		Name:        name,
+		Description: description,
+		Source:      lxd.InstanceSource{Type: "copy", Source: source + "/" + snapshot, InstanceOnly: true},
+		Config:      cfg,
+		Devices:     devices,
+		Profiles:    src.Profiles,
+	}, func(p lxd.Progress) {
+		ui.Log(core.LogDebug, "  "+p.Text)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy %s to %s: %v", source, name, err)
+	}
+	if src.Running() {
+		if err := client.StartInstance(ctx, name); err != nil {
+			return result, fmt.Errorf("failed to start %s: %v", name, err)
+		}
+	}
+	return result, nil
+}
+
+// PreviousName is what Promote renames the replaced container to
+func PreviousName(name string) string {
+	return name + "-previous"
+}
+
+// Promote swaps a fork into the name of the container it was forked from.
+// The original is kept, stopped, as NAME-previous, itself marked as a fork
+// so it can be discarded or promoted back. The two swap host ports, so the
+// promoted container serves on the original's ports. The promoted container
+// is started if either was running. Returns the original's new name.
+func Promote(ctx context.Context, ui core.UI, client *lxd.Client, fork string) (string, error) {
+	f, err := client.Instance(ctx, fork)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up %s: %v", fork, err)
+	}
+	name := ForkOf(f)
+	if name == "" {
+		return "", fmt.Errorf("%s is not a fork", fork)
+	}
+	previous := PreviousName(name)
+	if exists, err := client.InstanceExists(ctx, previous); err != nil {
+		return "", err
+	} else if exists && previous != fork {
+		return "", fmt.Errorf("%s already exists; discard it first", previous)
+	}
+
+	original, err := client.Instance(ctx, name)
+	if err != nil && !lxd.IsNotFound(err) {
+		return "", fmt.Errorf("failed to look up %s: %v", name, err)
+	}
+	running := f.Running()
+	if f.Running() {
+		if err := client.StopInstance(ctx, fork, false); err != nil {
+			return "", fmt.Errorf("failed to stop %s: %v", fork, err)
+		}
+	}
+
+	forkPut := f.Writable()
+	forkPut.Config = copyConfig(forkPut.Config)
+	forkPut.Devices = copyDevices(forkPut.Devices)
+	delete(forkPut.Config, keyForkOf)
+
+	// Promoting NAME-previous back parks the current NAME under a temporary
+	// name until the fork has moved out of the way
+	parking := previous
+	if previous == fork {
+		parking = name + "-swap"
+	}
+	if original != nil {
+		running = running || original.Running()
+		if original.Running() {
+			if err := client.StopInstance(ctx, name, false); err != nil {
+				return "", fmt.Errorf("failed to stop %s: %v", name, err)
+			}
+		}
+		ui.Log(core.LogInfo, fmt.Sprintf("Renaming %s to %s...", name, previous))
+		if err := client.RenameInstance(ctx, name, parking); err != nil {
+			return "", fmt.Errorf("failed to rename %s: %v", name, err)
+		}
+
+		origPut := original.Writable()
+		origPut.Config = copyConfig(origPut.Config)
+		origPut.Devices = copyDevices(origPut.Devices)
+		origPut.Config[keyForkOf] = name
+		swapProxyPorts(forkPut.Devices, origPut.Devices)
+		if err := client.UpdateInstance(ctx, parking, origPut); err != nil {
+			return parking, fmt.Errorf("failed to update %s: %v", parking, err)
+		}
+	}
+
+	ui.Log(core.LogInfo, fmt.Sprintf("Renaming %s to %s...", fork, name))
+	if err := client.RenameInstance(ctx, fork, name); err != nil {
+		return parking, fmt.Errorf("failed to rename %s: %v", fork, err)
+	}
+	if err := client.UpdateInstance(ctx, name, forkPut); err != nil {
+		return parking, fmt.Errorf("failed to update %s: %v", name, err)
+	}
+	if original != nil && parking != previous {
+		if err := client.RenameInstance(ctx, parking, previous); err != nil {
+			return parking, fmt.Errorf("failed to rename %s: %v", parking, err)
+		}
+	}
+	if running {
+		if err := client.StartInstance(ctx, name); err != nil {
+			return previous, fmt.Errorf("failed to start %s: %v", name, err)
+		}
+	}
+	return previous, nil
+}
+
+// Discard deletes a fork, or the previous version kept by Promote.
+// Containers that are not forks are refused.
+func Discard(ctx context.Context, client *lxd.Client, fork string) error {
+	f, err := client.Instance(ctx, fork)
+	if err != nil {
+		return fmt.Errorf("failed to look up %s: %v", fork, err)
+	}
+	if ForkOf(f) == "" {
+		return fmt.Errorf("%s is not a fork; delete it with recreate or lxc delete", fork)
+	}
+	return client.DeleteInstance(ctx, fork, true)
+}
+
+// swapProxyPorts exchanges the listen addresses of proxy devices the two
+// device sets share by name
+func swapProxyPorts(a, b lxd.Devices) {
+	for name, da := range a {
+		db, ok := b[name]
+		if ok && da["type"] == "proxy" && db["type"] == "proxy" {
+			da["listen"], db["listen"] = db["listen"], da["listen"]
+		}
+	}
+}
+
+// usedProxyPorts collects the host ports every instance's proxy devices
+// listen on, running or not, so a fork does not take a stopped
+// container's port
+func usedProxyPorts(ctx context.Context, client *lxd.Client) (map[string]bool, error) {
+	instances, err := client.Instances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool)
+	for _, instance := range instances {
+		for _, d := range instance.ExpandedDevices {
+			if d["type"] != "proxy" {
+				continue
+			}
+			if port, err := parseListen(d["listen"]); err == nil {
+				used[proxyPortKey(port)] = true
+			}
+		}
+	}
+	return used, nil
+}
+
+// freePort returns the first host port above port.Host that no instance
+// uses and nothing on the host holds
+func freePort(port config.PortSpec, taken map[string]bool) int {
+	for p := port.Host + 1; p <= 65535; p++ {
+		candidate := port
+		candidate.Host = p
+		if !taken[proxyPortKey(candidate)] && !PortInUse(candidate) {
+			return p
+		}
+	}
+	return port.Host
+}
+
+func proxyPortKey(port config.PortSpec) string {
+	return fmt.Sprintf("%s/%d", port.Protocol, port.Host)
+}
+
+// parseListen reads a proxy device's "tcp:127.0.0.1:8188" listen address
+func parseListen(listen string) (config.PortSpec, error) {
+	proto, rest, ok := strings.Cut(listen, ":")
+	i := strings.LastIndex(rest, ":")
+	if !ok || i < 0 {
+		return config.PortSpec{}, fmt.Errorf("unrecognised listen address %q", listen)
+	}
+	port, err := strconv.Atoi(rest[i+1:])
+	if err != nil {
+		return config.PortSpec{}, fmt.Errorf("unrecognised listen address %q", listen)
+	}
+	return config.PortSpec{Host: port, Protocol: proto}, nil
+}
+
+// withListenPort replaces the port of a listen address, keeping its
+// protocol and address
+func withListenPort(listen string, port int) string {
+	return listen[:strings.LastIndex(listen, ":")+1] + strconv.Itoa(port)
+}
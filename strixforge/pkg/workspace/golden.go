@@ -0,0 +1,190 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daveweinstein1/strixforge/pkg/core"
+	"github.com/daveweinstein1/strixforge/pkg/lxd"
+)
+
+// Image properties recorded on golden images by Publish
+const (
+	propSource    = "strixforge.source"
+	propPublished = "strixforge.published"
+)
+
+// keyGolden records the golden image a container was launched from
+const keyGolden = "user.strixforge.golden"
+
+// provisionKeys are the instance config keys holding provisioning hashes.
+// Publish copies them onto the image so containers launched from it only
+// rerun steps whose hash has changed since.
+var provisionKeys = []string{keyUsers, keyPackages, keyFiles, keySetup}
+
+// GoldenImage is a provisioned container published as a local image
+type GoldenImage struct {
+	Ref         string            `json:"ref"`
+	Fingerprint string            `json:"fingerprint"`
+	Source      string            `json:"source"`
+	Description string            `json:"description,omitempty"`
+	Published   time.Time         `json:"published"`
+	Size        int64             `json:"size"`
+	Provisioned map[string]string `json:"-"` // instance config key -> hash
+}
+
+// propertyKey is the image property an instance provisioning key is saved as
+func propertyKey(key string) string {
+	return strings.TrimPrefix(key, "user.")
+}
+
+// goldenImage reads a golden image from an image's properties, or returns
+// false for images strixforge did not publish
+func goldenImage(ref string, image lxd.Image) (GoldenImage, bool) {
+	source := image.Properties[propSource]
+	if source == "" {
+		return GoldenImage{}, false
+	}
+	golden := GoldenImage{
+		Ref:         ref,
+		Fingerprint: image.Fingerprint,
+		Source:      source,
+		Description: image.Properties["description"],
+		Size:        image.Size,
+		Provisioned: make(map[string]string),
+	}
+	golden.Published, _ = time.Parse(time.RFC3339, image.Properties[propPublished])
+	for _, key := range provisionKeys {
+		if hash := image.Properties[propertyKey(key)]; hash != "" {
+			golden.Provisioned[key] = hash
+		}
+	}
+	return golden, true
+}
+
+// FindGolden looks up a golden image by reference. It returns nil if no
+// local image has that alias.
+func FindGolden(ctx context.Context, client *lxd.Client, ref string) (*GoldenImage, error) {
+	alias, err := client.ImageAlias(ctx, ref)
+	if lxd.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up image %s: %v", ref, err)
+	}
+	image, err := client.Image(ctx, alias.Target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up image %s: %v", ref, err)
+	}
+	golden, ok := goldenImage(ref, *image)
+	if !ok {
+		return nil, fmt.Errorf("image %s was not published by strixforge", ref)
+	}
+	return &golden, nil
+}
+
+// GoldenImages lists the published golden images by reference
+func GoldenImages(ctx context.Context, client *lxd.Client) ([]GoldenImage, error) {
+	images, err := client.Images(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var goldens []GoldenImage
+	for _, image := range images {
+		for _, alias := range image.Aliases {
+			if golden, ok := goldenImage(alias.Name, image); ok {
+				goldens = append(goldens, golden)
+			}
+		}
+	}
+	sort.Slice(goldens, func(i, j int) bool { return goldens[i].Ref < goldens[j].Ref })
+	return goldens, nil
+}
+
+// Publish saves a container as a local image under ref, together with its
+// provisioning hashes. The container keeps running: a temporary snapshot
+// is published instead. If ref already names an image it is moved to the
+// new one, and the old image is deleted once nothing else refers to it.
+func Publish(ctx context.Context, ui core.UI, client *lxd.Client, name, ref, description string) (*GoldenImage, error) {
+	instance, err := client.Instance(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %v", name, err)
+	}
+	if description == "" {
+		description = fmt.Sprintf("%s published from %s", ref, name)
+	}
+
+	now := time.Now().UTC()
+	properties := map[string]string{
+		"description": description,
+		propSource:    name,
+		propPublished: now.Format(time.RFC3339),
+	}
+	for _, key := range []string{"os", "release", "architecture", "variant"} {
+		if value := instance.Config["image."+key]; value != "" {
+			properties[key] = value
+		}
+	}
+	for _, key := range provisionKeys {
+		if hash := instance.Config[key]; hash != "" {
+			properties[propertyKey(key)] = hash
+		}
+	}
+
+	snapshot := SnapshotName("publish", now)
+	if err := client.CreateSnapshot(ctx, name, lxd.SnapshotsPost{Name: snapshot}); err != nil {
+		return nil, fmt.Errorf("failed to snapshot %s: %v", name, err)
+	}
+	defer func() {
+		if err := client.DeleteSnapshot(ctx, name, snapshot); err != nil {
+			ui.Log(core.LogWarn, fmt.Sprintf("Could not delete temporary snapshot %s/%s: %v", name, snapshot, err))
+		}
+	}()
+
+	ui.Log(core.LogInfo, fmt.Sprintf("Publishing %s as %s...", name, ref))
+	fingerprint, err := client.PublishImage(ctx, lxd.ImagesPost{
+		Source:     lxd.ImageSource{Type: "snapshot", Name: name + "/" + snapshot},
+		Properties: properties,
+	}, func(p lxd.Progress) {
+		ui.Log(core.LogDebug, "  "+p.Text)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish %s: %v", name, err)
+	}
+
+	alias := lxd.ImageAlias{Name: ref, Description: description, Target: fingerprint}
+	previous, err := client.ImageAlias(ctx, ref)
+	switch {
+	case lxd.IsNotFound(err):
+		err = client.CreateImageAlias(ctx, alias)
+	case err == nil:
+		err = client.UpdateImageAlias(ctx, alias)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to name image %s: %v", ref, err)
+	}
+	if previous != nil && previous.Target != fingerprint {
+		deleteUnreferenced(ctx, ui, client, previous.Target)
+	}
+
+	image, err := client.Image(ctx, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	golden, _ := goldenImage(ref, *image)
+	return &golden, nil
+}
+
+// deleteUnreferenced removes an image whose last alias was moved away
+func deleteUnreferenced(ctx context.Context, ui core.UI, client *lxd.Client, fingerprint string) {
+	image, err := client.Image(ctx, fingerprint)
+	if err != nil || len(image.Aliases) > 0 {
+		return
+	}
+	if err := client.DeleteImage(ctx, fingerprint); err != nil {
+		ui.Log(core.LogWarn, fmt.Sprintf("Could not delete replaced image %.12s: %v", fingerprint, err))
+	}
+}
@@ -0,0 +1,156 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+)
+
+// ContainerStatus describes a container for `strixforge containers`
+type ContainerStatus struct {
+	Name        string                `json:"name"`
+	Runtime     string                `json:"runtime"`
+	State       system.ContainerState `json:"state"`
+	Image       string                `json:"image,omitempty"`
+	Declared    bool                  `json:"declared"` // has a spec in the config
+	Description string                `json:"description,omitempty"`
+	Snapshots   []string              `json:"snapshots"`
+	Services    map[string]string     `json:"services,omitempty"` // name -> URL
+}
+
+// ContainerStatuses reports the runtime's containers together with
+// declared containers that do not exist yet, sorted by name
+func ContainerStatuses(ctx context.Context, runtime system.ContainerRuntime, specs config.ContainerSpecs) ([]ContainerStatus, error) {
+	containers, err := runtime.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*ContainerStatus)
+	for _, c := range containers {
+		byName[c.Name] = &ContainerStatus{Name: c.Name, Runtime: runtime.Name(), State: c.State, Image: c.Image}
+	}
+	for _, spec := range specs {
+		status, ok := byName[spec.Name]
+		if !ok {
+			status = &ContainerStatus{Name: spec.Name, Runtime: runtime.Name(), State: system.ContainerMissing}
+			byName[spec.Name] = status
+		}
+		status.Declared = true
+		status.Description = spec.Description
+		for _, svc := range spec.Services {
+			if status.Services == nil {
+				status.Services = make(map[string]string)
+			}
+			status.Services[svc.Name] = svc.URL()
+		}
+	}
+
+	statuses := make([]ContainerStatus, 0, len(byName))
+	for _, status := range byName {
+		status.Snapshots = []string{}
+		if status.State != system.ContainerMissing {
+			if status.Snapshots, err = runtime.Snapshots(ctx, status.Name); err != nil {
+				return nil, fmt.Errorf("failed to list snapshots of %s: %v", status.Name, err)
+			}
+		}
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses, nil
+}
+
+// Restore rolls a container back to a snapshot, first snapshotting its
+// current state so the restore can be undone; that snapshot's name is
+// returned. LXD restores in place. Podman and docker recreate the
+// container from the snapshot image, which needs its spec.
+func Restore(ctx context.Context, runtime system.ContainerRuntime, spec *config.ContainerSpec, name, snapshot string) (string, error) {
+	snapshots, err := runtime.Snapshots(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to list snapshots of %s: %v", name, err)
+	}
+	found := false
+	for _, s := range snapshots {
+		found = found || s == snapshot
+	}
+	if !found {
+		return "", fmt.Errorf("%s has no snapshot %s", name, snapshot)
+	}
+
+	lxdRuntime, isLXD := runtime.(*LXDRuntime)
+	if !isLXD && spec == nil {
+		return "", fmt.Errorf("%s is not declared in the config; %s restores recreate the container from its spec", name, runtime.Name())
+	}
+
+	safety, err := NewSnapshotter(runtime).Take(ctx, name, SnapshotBeforeChange, "before restoring "+snapshot)
+	if err != nil {
+		return "", err
+	}
+
+	if isLXD {
+		client, err := lxdRuntime.Client()
+		if err != nil {
+			return safety, err
+		}
+		if err := client.RestoreSnapshot(ctx, name, snapshot); err != nil {
+			return safety, fmt.Errorf("failed to restore %s to %s: %v", name, snapshot, err)
+		}
+		return safety, clearSnapshotReason(ctx, client, name)
+	}
+
+	state, err := runtime.State(ctx, name)
+	if err != nil {
+		return safety, err
+	}
+	cfg, err := containerConfig(*spec, runtime.Name())
+	if err != nil {
+		return safety, err
+	}
+	cfg.Image = system.SnapshotImage(name, snapshot)
+	if err := runtime.Delete(ctx, name); err != nil {
+		return safety, err
+	}
+	if err := runtime.Create(ctx, cfg); err != nil {
+		// Put back the state just saved rather than leave no container
+		cfg.Image = system.SnapshotImage(name, safety)
+		if undoErr := runtime.Create(ctx, cfg); undoErr != nil {
+			return safety, fmt.Errorf("failed to recreate %s from %s: %v; recreating it from %s also failed: %v", name, snapshot, err, safety, undoErr)
+		}
+		if state == system.ContainerRunning {
+			_ = runtime.Start(ctx, name)
+		}
+		return safety, fmt.Errorf("failed to recreate %s from %s: %v; it was put back as it was", name, snapshot, err)
+	}
+	if state == system.ContainerRunning {
+		return safety, runtime.Start(ctx, name)
+	}
+	return safety, nil
+}
+
+// ShellCommand returns the command line for an interactive login shell in a
+// container, as user when set. For LXD, a non-empty socket is passed to the
+// lxc client as LXD_DIR, which only works for sockets named unix.socket.
+func ShellCommand(runtime, name, user, socket string) ([]string, error) {
+	if runtime == config.RuntimeLXD {
+		var args []string
+		if socket != "" {
+			if filepath.Base(socket) != "unix.socket" {
+				return nil, fmt.Errorf("the lxc client only finds sockets named unix.socket (via LXD_DIR), not %s", socket)
+			}
+			args = append(args, "env", "LXD_DIR="+filepath.Dir(socket))
+		}
+		args = append(args, "lxc", "exec", name, "--")
+		if user != "" {
+			return append(args, "su", "-l", user), nil
+		}
+		return append(args, "bash", "-l"), nil
+	}
+	args := []string{runtime, "exec", "-it"}
+	if user != "" {
+		args = append(args, "--user", user)
+	}
+	return append(args, name, "bash", "-l"), nil
+}
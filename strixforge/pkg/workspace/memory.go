@@ -0,0 +1,69 @@
+package workspace
+
+import (
+	"fmt"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+)
+
+// ContainerMemory is a container's memory limit in the plan
+type ContainerMemory struct {
+	Name  string
+	Limit uint64 // bytes; 0 when the container has no limit
+}
+
+// MemoryPlan adds up what containers and the GPU may claim so it can be
+// compared with the RAM actually installed
+type MemoryPlan struct {
+	Host       system.MemoryInfo
+	Containers []ContainerMemory
+}
+
+// PlanMemory builds a plan from container specs and host memory
+func PlanMemory(specs config.ContainerSpecs, host system.MemoryInfo) MemoryPlan {
+	plan := MemoryPlan{Host: host}
+	for _, spec := range specs {
+		c := ContainerMemory{Name: spec.Name}
+		if spec.Limits.Memory != "" {
+			bytes, percent, err := config.ParseMemoryLimit(spec.Limits.Memory)
+			if err == nil && percent > 0 {
+				bytes = uint64(float64(host.Total) * percent / 100)
+			}
+			c.Limit = bytes
+		}
+		plan.Containers = append(plan.Containers, c)
+	}
+	return plan
+}
+
+// Committed returns the container limits plus VRAM and GTT
+func (p MemoryPlan) Committed() uint64 {
+	total := p.Host.VRAM + p.Host.GTT
+	for _, c := range p.Containers {
+		total += c.Limit
+	}
+	return total
+}
+
+// Warnings explains how the plan can run the host out of memory: containers
+// without a limit, and limits plus GPU memory exceeding physical RAM
+func (p MemoryPlan) Warnings() []string {
+	var warnings []string
+	for _, c := range p.Containers {
+		if c.Limit == 0 {
+			warnings = append(warnings, fmt.Sprintf("%s has no memory limit and can use all host RAM", c.Name))
+		}
+	}
+	if committed, physical := p.Committed(), p.Host.Physical(); committed > physical {
+		warnings = append(warnings, fmt.Sprintf(
+			"container limits (%s) + GPU VRAM (%s) + GTT (%s) = %s exceeds physical RAM (%s)",
+			formatGiB(committed-p.Host.VRAM-p.Host.GTT), formatGiB(p.Host.VRAM), formatGiB(p.Host.GTT),
+			formatGiB(committed), formatGiB(physical)))
+	}
+	return warnings
+}
+
+func formatGiB(bytes uint64) string {
+	return fmt.Sprintf("%.1f GiB", float64(bytes)/(1<<30))
+}
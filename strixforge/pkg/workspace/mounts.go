@@ -0,0 +1,169 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/core"
+	"github.com/daveweinstein1/strixforge/pkg/lxd"
+)
+
+// hostInfo is what host mounts need to know about the host
+type hostInfo struct {
+	idmapped bool // disk devices support shift=true
+	uid, gid string
+	home     string
+}
+
+// hostUser returns the user running the installer, looking through sudo
+func hostUser() (*user.User, error) {
+	if name := os.Getenv("SUDO_USER"); name != "" && os.Geteuid() == 0 {
+		return user.Lookup(name)
+	}
+	return user.Current()
+}
+
+// hostInfo loads host details on first use
+func (r *Reconciler) hostInfo(ctx context.Context) (*hostInfo, error) {
+	if r.host != nil {
+		return r.host, nil
+	}
+	server, err := r.client.Server(ctx)
+	if err != nil {
+		return nil, err
+	}
+	u, err := hostUser()
+	if err != nil {
+		return nil, fmt.Errorf("could not determine current user: %v", err)
+	}
+	r.host = &hostInfo{idmapped: server.IdmappedMounts(), uid: u.Uid, gid: u.Gid, home: u.HomeDir}
+	return r.host, nil
+}
+
+// hasHostMounts reports whether a spec maps host directories in
+func hasHostMounts(spec config.ContainerSpec) bool {
+	return len(spec.Mounts) > 0 || spec.Home != config.HomeNone
+}
+
+// mountState returns the disk devices for a spec's mounts, volumes and home
+// directory, plus any config they need. Host files are owned by the user's
+// UID, which an unprivileged container would show as nobody: with idmapped
+// mounts the devices shift ownership, otherwise the user's UID and GID are
+// mapped straight through with raw.idmap.
+func mountState(spec config.ContainerSpec, host *hostInfo, volumes map[string]config.VolumeSpec) (map[string]string, lxd.Devices) {
+	cfg := make(map[string]string)
+	devices := make(lxd.Devices)
+
+	hostDisk := func(source, path string, readonly bool) map[string]string {
+		device := map[string]string{"type": "disk", "source": source, "path": path}
+		if readonly {
+			device["readonly"] = "true"
+		}
+		if host.idmapped {
+			device["shift"] = "true"
+		}
+		return device
+	}
+
+	for _, m := range spec.Mounts {
+		devices[mountDeviceName(m.Path)] = hostDisk(m.Source, m.Path, m.ReadOnly)
+	}
+	if spec.Home != config.HomeNone {
+		devices["home"] = hostDisk(host.home, config.HomeMountPath, spec.Home == config.HomeReadOnly)
+	}
+	if hasHostMounts(spec) && !host.idmapped {
+		cfg["raw.idmap"] = fmt.Sprintf("uid %s %s\ngid %s %s", host.uid, host.uid, host.gid, host.gid)
+	}
+
+	for _, v := range spec.Volumes {
+		device := map[string]string{"type": "disk", "pool": volumePool(volumes, v.Name), "source": v.Name, "path": v.Path}
+		if v.ReadOnly {
+			device["readonly"] = "true"
+		}
+		devices["vol-"+v.Name] = device
+	}
+	return cfg, devices
+}
+
+// mountDeviceName derives a device name from a mount path, e.g.
+// /srv/datasets becomes mount-srv-datasets
+func mountDeviceName(path string) string {
+	return "mount-" + strings.ReplaceAll(strings.Trim(path, "/"), "/", "-")
+}
+
+// volumePool returns the pool a volume lives in
+func volumePool(volumes map[string]config.VolumeSpec, name string) string {
+	if pool := volumes[name].Pool; pool != "" {
+		return pool
+	}
+	return config.DefaultVolumePool
+}
+
+// ensureVolumes creates the custom volumes a spec uses and keeps their
+// size and description in line with the config
+func (r *Reconciler) ensureVolumes(ctx context.Context, ui core.UI, spec config.ContainerSpec) error {
+	for _, v := range spec.Volumes {
+		want := r.volumes[v.Name]
+		pool := volumePool(r.volumes, v.Name)
+
+		current, err := r.client.StorageVolume(ctx, pool, v.Name)
+		if lxd.IsNotFound(err) {
+			vol := lxd.StorageVolume{Name: v.Name, Description: want.Description, Config: map[string]string{}}
+			if want.Size != "" {
+				vol.Config["size"] = want.Size
+			}
+			if err := r.client.CreateStorageVolume(ctx, pool, vol); err != nil {
+				return fmt.Errorf("failed to create volume %s/%s: %v", pool, v.Name, err)
+			}
+			ui.Log(core.LogInfo, fmt.Sprintf("✓ Created shared volume %s in pool %s", v.Name, pool))
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to look up volume %s/%s: %v", pool, v.Name, err)
+		}
+
+		if (want.Size == "" || current.Config["size"] == want.Size) &&
+			(want.Description == "" || current.Description == want.Description) {
+			continue
+		}
+		update := *current
+		update.Config = copyConfig(current.Config)
+		if want.Size != "" {
+			update.Config["size"] = want.Size
+		}
+		if want.Description != "" {
+			update.Description = want.Description
+		}
+		if err := r.client.UpdateStorageVolume(ctx, pool, v.Name, update); err != nil {
+			return fmt.Errorf("failed to update volume %s/%s: %v", pool, v.Name, err)
+		}
+		ui.Log(core.LogInfo, fmt.Sprintf("✓ Updated shared volume %s", v.Name))
+	}
+	return nil
+}
+
+// checkSubIDs warns when raw.idmap needs /etc/subuid and /etc/subgid
+// entries that are missing; LXD refuses to start the container without them
+func checkSubIDs(ui core.UI, host *hostInfo) {
+	for _, file := range []struct{ path, id string }{{"/etc/subuid", host.uid}, {"/etc/subgid", host.gid}} {
+		entry := "root:" + file.id + ":1"
+		data, err := os.ReadFile(file.path)
+		if err == nil && containsLine(string(data), entry) {
+			continue
+		}
+		ui.Log(core.LogWarn, fmt.Sprintf("Host mounts need '%s' in %s (idmapped mounts unsupported); add it and restart LXD", entry, file.path))
+	}
+}
+
+func containsLine(data, line string) bool {
+	for _, l := range strings.Split(data, "\n") {
+		if strings.TrimSpace(l) == line {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,224 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/core"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+)
+
+// Probe is a readiness check against a running container. Check is retried
+// with backoff until it passes, returns a permanent error, or Deadline
+// passes.
+type Probe struct {
+	Name     string
+	Deadline time.Duration
+	Check    func(ctx context.Context) error
+}
+
+// Probe retry timing. Each attempt is also capped at probeAttemptTimeout
+// so a hung command does not eat the whole deadline.
+const (
+	probeInitialBackoff = 500 * time.Millisecond
+	probeMaxBackoff     = 5 * time.Second
+	probeAttemptTimeout = 30 * time.Second
+	defaultProbeTimeout = 2 * time.Minute
+)
+
+// errSkipProbe passes a probe that does not apply to the container, such as
+// cloud-init in an image without it
+var errSkipProbe = errors.New("not applicable")
+
+// permanentError stops a probe from retrying
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// permanent marks err as not worth retrying
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// RunProbe retries a probe with exponential backoff until it passes or its
+// deadline expires, logging each failed attempt at debug level
+func RunProbe(ctx context.Context, ui core.UI, probe Probe) error {
+	deadline := probe.Deadline
+	if deadline == 0 {
+		deadline = defaultProbeTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, deadline)
+	defer cancel()
+
+	start := time.Now()
+	backoff := probeInitialBackoff
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancelAttempt := context.WithTimeout(ctx, probeAttemptTimeout)
+		err := probe.Check(attemptCtx)
+		cancelAttempt()
+
+		switch {
+		case err == nil:
+			ui.Log(core.LogInfo, fmt.Sprintf("✓ %s (%s)", probe.Name, time.Since(start).Round(100*time.Millisecond)))
+			return nil
+		case errors.Is(err, errSkipProbe):
+			ui.Log(core.LogDebug, fmt.Sprintf("%s: skipped, %v", probe.Name, err))
+			return nil
+		}
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return fmt.Errorf("%s: %v", probe.Name, err)
+		}
+		ui.Log(core.LogDebug, fmt.Sprintf("%s: attempt %d: %v", probe.Name, attempt, err))
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%s: not ready after %s: %v", probe.Name, deadline, err)
+			}
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, probeMaxBackoff)
+	}
+}
+
+// WaitReady runs probes in order, reporting which one is being waited on
+func WaitReady(ctx context.Context, ui core.UI, probes []Probe) error {
+	for _, probe := range probes {
+		ui.Log(core.LogInfo, fmt.Sprintf("Waiting for %s...", probe.Name))
+		if err := RunProbe(ctx, ui, probe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BootProbes check that a container has finished booting and can reach
+// the network and its package mirror, ready for package installs. Only LXD
+// containers boot an init system; podman and docker ones are ready as soon
+// as they start.
+func BootProbes(runtime system.ContainerRuntime, name string) []Probe {
+	var probes []Probe
+	if runtime.Name() == config.RuntimeLXD {
+		probes = append(probes,
+			Probe{Name: "systemd boot", Deadline: 2 * time.Minute, Check: systemdRunning(runtime, name)},
+			Probe{Name: "cloud-init", Deadline: 5 * time.Minute, Check: cloudInitDone(runtime, name)},
+		)
+	}
+	return append(probes,
+		Probe{Name: "DNS resolution", Deadline: time.Minute, Check: dnsResolves(runtime, name, "archlinux.org")},
+		Probe{Name: "package mirror", Deadline: time.Minute, Check: mirrorReachable(runtime, name)},
+	)
+}
+
+// SpecProbes turns a spec's custom probes into probes
+func SpecProbes(runtime system.ContainerRuntime, spec config.ContainerSpec) []Probe {
+	var probes []Probe
+	for i, p := range spec.Probes {
+		label := p.Name
+		if label == "" {
+			label = fmt.Sprintf("probe %d", i+1)
+		}
+		command := p.Command
+		probes = append(probes, Probe{
+			Name:     fmt.Sprintf("%s in %s", label, spec.Name),
+			Deadline: time.Duration(p.Timeout),
+			Check: func(ctx context.Context) error {
+				_, err := runtime.Exec(ctx, spec.Name, "sh", "-c", command)
+				return err
+			},
+		})
+	}
+	return probes
+}
+
+// systemdRunning waits for boot to finish. A degraded system still counts
+// as booted; a failed unit should not block provisioning.
+func systemdRunning(runtime system.ContainerRuntime, name string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		result, err := runtime.Exec(ctx, name, "systemctl", "is-system-running", "--wait")
+		if result == nil {
+			return err
+		}
+		switch state := strings.TrimSpace(result.Stdout); state {
+		case "running", "degraded":
+			return nil
+		case "":
+			return err
+		default:
+			return fmt.Errorf("system is %s", state)
+		}
+	}
+}
+
+// cloudInitDone waits for cloud-init to finish, if the image has it
+func cloudInitDone(runtime system.ContainerRuntime, name string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if _, err := runtime.Exec(ctx, name, "sh", "-c", "command -v cloud-init"); err != nil {
+			return fmt.Errorf("%w: image has no cloud-init", errSkipProbe)
+		}
+		result, err := runtime.Exec(ctx, name, "cloud-init", "status")
+		if result == nil {
+			return err
+		}
+		status := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(result.Stdout), "status:"))
+		switch status {
+		case "done", "disabled":
+			return nil
+		case "error":
+			return permanent(fmt.Errorf("cloud-init failed, see 'cloud-init status --long' in %s", name))
+		default:
+			return fmt.Errorf("cloud-init is %s", status)
+		}
+	}
+}
+
+// dnsResolves checks name resolution without relying on ICMP
+func dnsResolves(runtime system.ContainerRuntime, name, host string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if _, err := runtime.Exec(ctx, name, "getent", "hosts", host); err != nil {
+			return fmt.Errorf("cannot resolve %s", host)
+		}
+		return nil
+	}
+}
+
+// mirrorReachable fetches the core database from the first mirror in the
+// container's pacman mirrorlist
+func mirrorReachable(runtime system.ContainerRuntime, name string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		result, err := runtime.Exec(ctx, name, "cat", "/etc/pacman.d/mirrorlist")
+		if err != nil {
+			return fmt.Errorf("%w: no pacman mirrorlist", errSkipProbe)
+		}
+		url := firstMirror(result.Stdout)
+		if url == "" {
+			return permanent(fmt.Errorf("no Server line in /etc/pacman.d/mirrorlist"))
+		}
+		if _, err := runtime.Exec(ctx, name, "curl", "-fsS", "-o", "/dev/null", "--max-time", "20", url+"/core.db"); err != nil {
+			return fmt.Errorf("%s unreachable: %v", url, err)
+		}
+		return nil
+	}
+}
+
+// firstMirror returns the first uncommented Server URL from a mirrorlist,
+// expanded for the core repository
+func firstMirror(mirrorlist string) string {
+	for _, line := range strings.Split(mirrorlist, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || strings.TrimSpace(key) != "Server" {
+			continue
+		}
+		url := strings.TrimSpace(value)
+		url = strings.ReplaceAll(url, "$repo", "core")
+		return strings.ReplaceAll(url, "$arch", "x86_64")
+	}
+	return ""
+}
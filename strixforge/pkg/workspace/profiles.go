@@ -0,0 +1,131 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/daveweinstein1/strixforge/pkg/lxd"
+)
+
+// Profiles the installer manages. Containers attach them according to their
+// spec instead of everything inheriting GPU access and nesting from the
+// default profile.
+const (
+	ProfileGPU     = "strix-gpu"
+	ProfileNesting = "strix-nesting"
+)
+
+// GIDs of the video and render groups in Arch containers. The filesystem
+// package assigns them statically, so they hold in every Arch image even
+// when the host, whose groups may have been created differently, uses
+// other IDs.
+const (
+	archVideoGID  = "985"
+	archRenderGID = "989"
+)
+
+// driDir holds the host's GPU device nodes
+var driDir = "/dev/dri"
+
+// staleDevice reports whether a device in a managed profile was put there
+// by an earlier run and should go unless still wanted: DRI nodes, which can
+// be renumbered, and the single gpu device that gave render nodes the video
+// GID
+func staleDevice(profile, device string) bool {
+	return profile == ProfileGPU && (device == "gpu" || strings.HasPrefix(device, "dri-"))
+}
+
+// ManagedProfiles returns the profiles the installer maintains. Each DRI
+// node is passed through on its own so card* gets the video GID and
+// renderD* the render GID, as ROCm and Mesa expect.
+func ManagedProfiles() ([]lxd.Profile, error) {
+	nodes, err := filepath.Glob(filepath.Join(driDir, "*"))
+	if err != nil {
+		return nil, err
+	}
+	devices := lxd.Devices{
+		"kfd": {"type": "unix-char", "source": "/dev/kfd", "path": "/dev/kfd", "gid": archRenderGID, "mode": "0660"},
+	}
+	found := false
+	for _, node := range nodes {
+		name := filepath.Base(node)
+		gid := archVideoGID
+		switch {
+		case strings.HasPrefix(name, "renderD"):
+			gid = archRenderGID
+		case strings.HasPrefix(name, "card"):
+		default:
+			continue // by-path symlinks
+		}
+		devices["dri-"+name] = map[string]string{"type": "unix-char", "source": node, "path": node, "gid": gid, "mode": "0660"}
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("no GPU device nodes found in %s; is the amdgpu driver loaded?", driDir)
+	}
+
+	gpu := lxd.Profile{
+		Name:        ProfileGPU,
+		Description: "GPU access for ROCm, Vulkan and OpenGL (managed by strixforge)",
+		Config:      map[string]string{},
+		Devices:     devices,
+	}
+
+	nesting := lxd.Profile{
+		Name:        ProfileNesting,
+		Description: "Nested containers such as Docker (managed by strixforge)",
+		Config: map[string]string{
+			"security.nesting":                     "true",
+			"security.syscalls.intercept.mknod":    "true",
+			"security.syscalls.intercept.setxattr": "true",
+		},
+		Devices: lxd.Devices{},
+	}
+
+	return []lxd.Profile{gpu, nesting}, nil
+}
+
+// EnsureProfile creates a profile or brings the config keys and devices it
+// declares back in line. Keys and devices added by the user are kept.
+func EnsureProfile(ctx context.Context, client *lxd.Client, want lxd.Profile) (Action, error) {
+	current, err := client.Profile(ctx, want.Name)
+	if lxd.IsNotFound(err) {
+		if err := client.CreateProfile(ctx, want); err != nil {
+			return "", fmt.Errorf("failed to create profile %s: %v", want.Name, err)
+		}
+		return ActionCreated, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read profile %s: %v", want.Name, err)
+	}
+
+	put := lxd.ProfilePut{
+		Description: want.Description,
+		Config:      copyConfig(current.Config),
+		Devices:     copyDevices(current.Devices),
+	}
+	for key, value := range want.Config {
+		put.Config[key] = value
+	}
+	for name := range put.Devices {
+		if staleDevice(want.Name, name) {
+			delete(put.Devices, name)
+		}
+	}
+	for name, device := range want.Devices {
+		put.Devices[name] = device
+	}
+
+	if put.Description == current.Description &&
+		reflect.DeepEqual(put.Config, copyConfig(current.Config)) &&
+		reflect.DeepEqual(put.Devices, copyDevices(current.Devices)) {
+		return ActionUnchanged, nil
+	}
+	if err := client.UpdateProfile(ctx, want.Name, put); err != nil {
+		return "", fmt.Errorf("failed to update profile %s: %v", want.Name, err)
+	}
+	return ActionUpdated, nil
+}
@@ -0,0 +1,565 @@
+// Package workspace creates and updates workspace containers from their
+// declarative specs in strixhalo.yaml.
+package workspace
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/core"
+	"github.com/daveweinstein1/strixforge/pkg/lxd"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+)
+
+// Instance config keys where the reconciler records what it manages, so
+// that later runs can remove what a spec no longer declares
+const (
+	keyManagedConfig  = "user.strixforge.config"
+	keyManagedDevices = "user.strixforge.devices"
+	keyPackages       = "user.strixforge.packages"
+	keyUsers          = "user.strixforge.users"
+	keyFiles          = "user.strixforge.files"
+	keySetup          = "user.strixforge.setup"
+)
+
+// Action is what reconciling a container did
+type Action string
+
+const (
+	ActionCreated   Action = "created"
+	ActionUpdated   Action = "updated"
+	ActionUnchanged Action = "unchanged"
+)
+
+// Result describes the outcome of reconciling one container
+type Result struct {
+	Name    string
+	Action  Action
+	Changes []string
+}
+
+// Reconciler makes containers match their specs
+type Reconciler struct {
+	runtime system.ContainerRuntime
+	client  *lxd.Client // set when runtime is LXD
+	volumes map[string]config.VolumeSpec
+	host    *hostInfo
+}
+
+// NewReconciler creates a reconciler for a container runtime. volumes
+// configures the shared custom volumes specs may attach.
+func NewReconciler(runtime system.ContainerRuntime, volumes map[string]config.VolumeSpec) *Reconciler {
+	return &Reconciler{runtime: runtime, volumes: volumes}
+}
+
+// Reconcile creates the container if it is missing, otherwise brings its
+// config, devices and profiles in line with the spec and installs packages
+// or reruns setup commands that changed. Under runtimes other than LXD
+// only provisioning is updated in existing containers.
+func (r *Reconciler) Reconcile(ctx context.Context, ui core.UI, spec config.ContainerSpec) (*Result, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	lxdRuntime, ok := r.runtime.(*LXDRuntime)
+	if !ok {
+		return r.reconcileRuntime(ctx, ui, spec)
+	}
+	if r.client == nil {
+		client, err := lxdRuntime.Client()
+		if err != nil {
+			return nil, fmt.Errorf("cannot reach LXD: %v", err)
+		}
+		r.client = client
+	}
+
+	if err := r.ensureVolumes(ctx, ui, spec); err != nil {
+		return nil, err
+	}
+	if hasHostMounts(spec) {
+		host, err := r.hostInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !host.idmapped {
+			checkSubIDs(ui, host)
+		}
+	}
+
+	instance, err := r.client.Instance(ctx, spec.Name)
+	if lxd.IsNotFound(err) {
+		return r.create(ctx, ui, spec)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %v", spec.Name, err)
+	}
+	return r.update(ctx, ui, spec, instance)
+}
+
+// create launches a new container and provisions it. A golden image is
+// used when the spec names one that exists; the container then starts with
+// the image's provisioning hashes and only changed steps run.
+func (r *Reconciler) create(ctx context.Context, ui core.UI, spec config.ContainerSpec) (*Result, error) {
+	image, source := spec.Image, spec.Image
+	config, devices, err := r.desiredState(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPorts(spec, nil); err != nil {
+		return nil, err
+	}
+
+	var golden *GoldenImage
+	if spec.Golden != "" {
+		if golden, err = FindGolden(ctx, r.client, spec.Golden); err != nil {
+			return nil, err
+		}
+		if golden == nil {
+			ui.Log(core.LogWarn, fmt.Sprintf("Golden image %s not found, building %s from %s", spec.Golden, spec.Name, spec.Image))
+		} else {
+			// The golden ref is a local alias even without a slash
+			image, source = spec.Golden, "local:"+spec.Golden
+			for key, hash := range golden.Provisioned {
+				config[key] = hash
+			}
+			config[keyGolden] = spec.Golden + "@" + golden.Fingerprint
+		}
+	}
+
+	ui.Log(core.LogInfo, fmt.Sprintf("Launching %s from %s...", spec.Name, image))
+	err = r.runtime.Create(ctx, system.ContainerConfig{
+		Name:        spec.Name,
+		Description: spec.Description,
+		Image:       source,
+		GPU:         spec.GPU,
+		Profiles:    desiredProfiles(spec),
+		Config:      config,
+		Devices:     devices,
+		Progress: func(text string) {
+			ui.Log(core.LogDebug, "  "+text)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := r.runtime.Start(ctx, spec.Name); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %v", spec.Name, err)
+	}
+
+	result := &Result{Name: spec.Name, Action: ActionCreated}
+	if golden != nil {
+		if pending := r.pendingSteps(ctx, ui, spec, golden.Provisioned); len(pending) > 0 {
+			if err := r.applySteps(ctx, ui, spec, pending, result); err != nil {
+				return result, err
+			}
+		}
+	} else if spec.UsesCloudInit() {
+		if err := r.awaitCloudInit(ctx, ui, spec); err != nil {
+			return result, err
+		}
+	} else if err := r.provision(ctx, ui, spec, nil, result); err != nil {
+		return result, err
+	}
+	if err := r.runSpecProbes(ctx, ui, spec); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// update reconciles an existing container
+func (r *Reconciler) update(ctx context.Context, ui core.UI, spec config.ContainerSpec, instance *lxd.Instance) (*Result, error) {
+	result := &Result{Name: spec.Name, Action: ActionUnchanged}
+
+	put := instance.Writable()
+	wantConfig, wantDevices, err := r.desiredState(ctx, spec)
+	if err != nil {
+		return result, err
+	}
+	if err := checkPorts(spec, put.Devices); err != nil {
+		return result, err
+	}
+	newConfig := copyConfig(put.Config)
+	newDevices := copyDevices(put.Devices)
+
+	// Drop what earlier runs added but the spec no longer declares
+	for _, key := range splitList(put.Config[keyManagedConfig]) {
+		if _, ok := wantConfig[key]; !ok {
+			delete(newConfig, key)
+			result.Changes = append(result.Changes, "-config "+key)
+		}
+	}
+	for _, name := range splitList(put.Config[keyManagedDevices]) {
+		if _, ok := wantDevices[name]; !ok {
+			delete(newDevices, name)
+			result.Changes = append(result.Changes, "-device "+name)
+		}
+	}
+
+	for key, value := range wantConfig {
+		if newConfig[key] != value {
+			if !strings.HasPrefix(key, "user.strixforge.") {
+				result.Changes = append(result.Changes, "config "+key)
+			}
+			newConfig[key] = value
+		}
+	}
+	for name, device := range wantDevices {
+		if !reflect.DeepEqual(newDevices[name], device) {
+			result.Changes = append(result.Changes, "device "+name)
+			newDevices[name] = device
+		}
+	}
+
+	profiles := desiredProfiles(spec)
+	if !reflect.DeepEqual(put.Profiles, profiles) {
+		result.Changes = append(result.Changes, "profiles "+strings.Join(profiles, ","))
+		put.Profiles = profiles
+	}
+	if spec.Description != "" && put.Description != spec.Description {
+		put.Description = spec.Description
+	}
+
+	if !reflect.DeepEqual(newConfig, put.Config) || !reflect.DeepEqual(newDevices, put.Devices) || len(result.Changes) > 0 {
+		put.Config = newConfig
+		put.Devices = newDevices
+		if err := r.client.UpdateInstance(ctx, spec.Name, put); err != nil {
+			return result, fmt.Errorf("failed to update %s: %v", spec.Name, err)
+		}
+	}
+
+	if err := r.provision(ctx, ui, spec, instance, result); err != nil {
+		return result, err
+	}
+	if err := r.runSpecProbes(ctx, ui, spec); err != nil {
+		return result, err
+	}
+	if len(result.Changes) > 0 {
+		result.Action = ActionUpdated
+	}
+	return result, nil
+}
+
+// provisionStep is one part of provisioning by exec. Each is recorded
+// under its own key once it succeeds, so only changed parts rerun.
+type provisionStep struct {
+	key   string
+	hash  string
+	label string
+	run   func() bool
+}
+
+// provisionSteps lists the steps a spec needs, in the order cloud-init
+// would apply them
+func (r *Reconciler) provisionSteps(ctx context.Context, ui core.UI, spec config.ContainerSpec) []provisionStep {
+	var steps []provisionStep
+	if len(spec.Users) > 0 {
+		steps = append(steps, provisionStep{keyUsers, valueHash(spec.Users), "users", func() bool {
+			ui.Log(core.LogInfo, fmt.Sprintf("Creating users in %s...", spec.Name))
+			return r.createUsers(ctx, ui, spec)
+		}})
+	}
+	if len(spec.Packages) > 0 {
+		steps = append(steps, provisionStep{keyPackages, listHash(spec.Packages), "packages", func() bool {
+			ui.Log(core.LogInfo, fmt.Sprintf("Installing %d packages in %s...", len(spec.Packages), spec.Name))
+			return r.installPackages(ctx, ui, spec)
+		}})
+	}
+	if files := allFiles(spec); len(files) > 0 {
+		steps = append(steps, provisionStep{keyFiles, valueHash(files), "files", func() bool {
+			ui.Log(core.LogInfo, fmt.Sprintf("Writing files in %s...", spec.Name))
+			return r.writeFiles(ctx, ui, spec)
+		}})
+	}
+	if len(spec.Setup) > 0 {
+		steps = append(steps, provisionStep{keySetup, listHash(spec.Setup), "setup", func() bool {
+			ui.Log(core.LogInfo, fmt.Sprintf("Running setup commands in %s...", spec.Name))
+			return r.runSetup(ctx, ui, spec)
+		}})
+	}
+	return steps
+}
+
+// provision applies users, packages, files and setup commands by exec
+// when they changed since the last successful run, then records what was
+// applied. Existing containers are snapshotted first.
+func (r *Reconciler) provision(ctx context.Context, ui core.UI, spec config.ContainerSpec, instance *lxd.Instance, result *Result) error {
+	var applied map[string]string
+	if instance != nil {
+		applied = instance.Config
+	}
+	pending := r.pendingSteps(ctx, ui, spec, applied)
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if instance != nil {
+		name, err := NewSnapshotter(r.runtime).BeforeChange(ctx, spec.Name, spec.Snapshots, "before updating packages and setup")
+		if err != nil {
+			return err
+		}
+		if name != "" {
+			ui.Log(core.LogInfo, fmt.Sprintf("✓ Snapshot %s/%s taken", spec.Name, name))
+		}
+		if !instance.Running() {
+			if err := r.client.StartInstance(ctx, spec.Name); err != nil {
+				return fmt.Errorf("failed to start %s: %v", spec.Name, err)
+			}
+		}
+	}
+	return r.applySteps(ctx, ui, spec, pending, result)
+}
+
+// pendingSteps returns the provisioning steps whose hash differs from the
+// one recorded in applied
+func (r *Reconciler) pendingSteps(ctx context.Context, ui core.UI, spec config.ContainerSpec, applied map[string]string) []provisionStep {
+	var pending []provisionStep
+	for _, step := range r.provisionSteps(ctx, ui, spec) {
+		if applied[step.key] != step.hash {
+			pending = append(pending, step)
+		}
+	}
+	return pending
+}
+
+// applySteps waits for the container to boot, runs the steps and records
+// those that succeeded
+func (r *Reconciler) applySteps(ctx context.Context, ui core.UI, spec config.ContainerSpec, pending []provisionStep, result *Result) error {
+	if err := WaitReady(ctx, ui, BootProbes(r.runtime, spec.Name)); err != nil {
+		return fmt.Errorf("%s is not ready for provisioning: %v", spec.Name, err)
+	}
+
+	record := make(map[string]string)
+	for _, step := range pending {
+		if step.run() {
+			record[step.key] = step.hash
+		}
+		result.Changes = append(result.Changes, step.label)
+	}
+	return r.recordApplied(ctx, spec.Name, record)
+}
+
+// awaitCloudInit waits for first-boot provisioning by cloud-init. On
+// failure its status and output log are attached to the error.
+func (r *Reconciler) awaitCloudInit(ctx context.Context, ui core.UI, spec config.ContainerSpec) error {
+	ui.Log(core.LogInfo, fmt.Sprintf("cloud-init is provisioning %s...", spec.Name))
+	probes := []Probe{
+		{Name: "systemd boot", Deadline: 2 * time.Minute, Check: systemdRunning(r.runtime, spec.Name)},
+		{Name: "cloud-init", Deadline: cloudInitDeadline, Check: cloudInitDone(r.runtime, spec.Name)},
+	}
+	if err := WaitReady(ctx, ui, probes); err != nil {
+		return &core.DiagnosticError{
+			Err:  fmt.Errorf("provisioning %s failed: %v", spec.Name, err),
+			Logs: cloudInitDiagnostics(ctx, r.runtime, spec.Name),
+		}
+	}
+
+	// cloud-init applied everything; record it so updates only redo changes
+	record := make(map[string]string)
+	for _, step := range r.provisionSteps(ctx, ui, spec) {
+		record[step.key] = step.hash
+	}
+	return r.recordApplied(ctx, spec.Name, record)
+}
+
+// recordApplied stores provisioning hashes on the instance, or in the
+// container under other runtimes
+func (r *Reconciler) recordApplied(ctx context.Context, name string, record map[string]string) error {
+	if len(record) == 0 {
+		return nil
+	}
+	if r.client == nil {
+		return writeApplied(ctx, r.runtime, name, record)
+	}
+	current, err := r.client.Instance(ctx, name)
+	if err != nil {
+		return err
+	}
+	put := current.Writable()
+	put.Config = copyConfig(put.Config)
+	for key, value := range record {
+		put.Config[key] = value
+	}
+	return r.client.UpdateInstance(ctx, name, put)
+}
+
+// runSetup runs the spec's setup commands, returning true if all passed
+func (r *Reconciler) runSetup(ctx context.Context, ui core.UI, spec config.ContainerSpec) bool {
+	ok := true
+	for _, cmd := range spec.Setup {
+		if res, err := r.runtime.Exec(ctx, spec.Name, "sh", "-c", cmd); err != nil {
+			ok = false
+			ui.Log(core.LogWarn, fmt.Sprintf("Setup command failed: %s: %v", cmd, err))
+			if res != nil && res.Stderr != "" {
+				ui.Log(core.LogDebug, "  "+strings.TrimSpace(res.Stderr))
+			}
+		}
+	}
+	return ok
+}
+
+// runSpecProbes runs the spec's custom probes if the container is running
+func (r *Reconciler) runSpecProbes(ctx context.Context, ui core.UI, spec config.ContainerSpec) error {
+	probes := SpecProbes(r.runtime, spec)
+	if len(probes) == 0 {
+		return nil
+	}
+	state, err := r.runtime.State(ctx, spec.Name)
+	if err != nil {
+		return err
+	}
+	if state != system.ContainerRunning {
+		ui.Log(core.LogInfo, fmt.Sprintf("%s is stopped, skipping its probes", spec.Name))
+		return nil
+	}
+	return WaitReady(ctx, ui, probes)
+}
+
+// installPackages installs everything in one transaction, falling back to
+// one package at a time so a single missing package does not block the rest.
+// Returns true if every package was installed.
+func (r *Reconciler) installPackages(ctx context.Context, ui core.UI, spec config.ContainerSpec) bool {
+	args := append([]string{"pacman", "-Syu", "--needed", "--noconfirm"}, spec.Packages...)
+	if _, err := r.runtime.Exec(ctx, spec.Name, args...); err == nil {
+		return true
+	}
+
+	ok := true
+	for _, pkg := range spec.Packages {
+		if _, err := r.runtime.Exec(ctx, spec.Name, "pacman", "-S", "--needed", "--noconfirm", pkg); err != nil {
+			ok = false
+			ui.Log(core.LogWarn, fmt.Sprintf("Failed to install %s: %v", pkg, err))
+		}
+	}
+	return ok
+}
+
+// desiredState renders the instance config and devices a spec declares,
+// including the bookkeeping keys listing them
+func (r *Reconciler) desiredState(ctx context.Context, spec config.ContainerSpec) (map[string]string, lxd.Devices, error) {
+	cfg := make(map[string]string)
+	for key, value := range spec.Environment {
+		cfg["environment."+key] = value
+	}
+	for key, value := range spec.Limits.LXDConfig() {
+		cfg[key] = value
+	}
+	if spec.UsesCloudInit() {
+		userData, err := RenderUserData(spec)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to render cloud-init user-data for %s: %v", spec.Name, err)
+		}
+		cfg[keyUserData] = userData
+	}
+
+	devices := make(lxd.Devices)
+	for _, port := range spec.Forwards() {
+		devices[ProxyDeviceName(port)] = map[string]string{
+			"type":    "proxy",
+			"listen":  fmt.Sprintf("%s:127.0.0.1:%d", port.Protocol, port.Host),
+			"connect": fmt.Sprintf("%s:127.0.0.1:%d", port.Protocol, port.Container),
+		}
+	}
+	if hasHostMounts(spec) || len(spec.Volumes) > 0 {
+		host := &hostInfo{}
+		if hasHostMounts(spec) {
+			var err error
+			if host, err = r.hostInfo(ctx); err != nil {
+				return nil, nil, err
+			}
+		}
+		mountConfig, mountDevices := mountState(spec, host, r.volumes)
+		for key, value := range mountConfig {
+			cfg[key] = value
+		}
+		for name, device := range mountDevices {
+			devices[name] = device
+		}
+	}
+	for name, device := range spec.Devices {
+		devices[name] = device
+	}
+
+	cfg[keyManagedConfig] = joinKeys(cfg)
+	cfg[keyManagedDevices] = joinKeys(devices)
+	return cfg, devices, nil
+}
+
+// ProxyDeviceName names the proxy device forwarding a port
+func ProxyDeviceName(port config.PortSpec) string {
+	return fmt.Sprintf("proxy-%s-%d", port.Protocol, port.Host)
+}
+
+// desiredProfiles returns the default profile, the managed profiles the
+// spec asks for, then the spec's own
+func desiredProfiles(spec config.ContainerSpec) []string {
+	profiles := []string{"default"}
+	if spec.GPU {
+		profiles = append(profiles, ProfileGPU)
+	}
+	if spec.Nesting {
+		profiles = append(profiles, ProfileNesting)
+	}
+	for _, p := range spec.Profiles {
+		if p != "default" && p != ProfileGPU && p != ProfileNesting {
+			profiles = append(profiles, p)
+		}
+	}
+	return profiles
+}
+
+// joinKeys returns the sorted keys of a map as a comma-separated list,
+// leaving out bookkeeping keys
+func joinKeys[V any](m map[string]V) string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		if !strings.HasPrefix(key, "user.strixforge.") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// listHash fingerprints a list so changes can be detected later
+func listHash(items []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(items, "\n")))
+	return hex.EncodeToString(sum[:6])
+}
+
+// valueHash fingerprints structured spec data such as users or files
+func valueHash(v interface{}) string {
+	data, _ := yaml.Marshal(v)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+func copyConfig(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func copyDevices(d lxd.Devices) lxd.Devices {
+	out := make(lxd.Devices, len(d))
+	for name, device := range d {
+		out[name] = copyConfig(device)
+	}
+	return out
+}
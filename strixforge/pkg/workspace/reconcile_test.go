@@ -0,0 +1,138 @@
+package workspace
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/core"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+)
+
+func testSpec() config.ContainerSpec {
+	return config.ContainerSpec{
+		Name:      "dev-lab",
+		Image:     "images:archlinux/current",
+		OCIImage:  "docker.io/library/archlinux:latest",
+		Packages:  []string{"git", "go"},
+		Setup:     []string{"go version"},
+		Snapshots: config.SnapshotPolicy{BeforeChange: 2},
+	}
+}
+
+// reconcile runs the reconciler once, failing the test on error
+func reconcile(t *testing.T, runtime system.ContainerRuntime, spec config.ContainerSpec) *Result {
+	t.Helper()
+	result, err := NewReconciler(runtime, nil).Reconcile(context.Background(), &core.NullUI{}, spec)
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	return result
+}
+
+const installCommand = "pacman -Syu --needed --noconfirm git go"
+
+func TestReconcileCreates(t *testing.T) {
+	runtime := newFakeRuntime()
+	spec := testSpec()
+
+	result := reconcile(t, runtime, spec)
+	if result.Action != ActionCreated {
+		t.Errorf("Action = %s, want %s", result.Action, ActionCreated)
+	}
+	if want := []string{"packages", "setup"}; !reflect.DeepEqual(result.Changes, want) {
+		t.Errorf("Changes = %v, want %v", result.Changes, want)
+	}
+
+	if len(runtime.created) != 1 {
+		t.Fatalf("created %d containers, want 1", len(runtime.created))
+	}
+	if cfg := runtime.created[0]; cfg.Name != "dev-lab" || cfg.Image != spec.OCIImage {
+		t.Errorf("created %+v", cfg)
+	}
+	if runtime.states["dev-lab"] != system.ContainerRunning {
+		t.Errorf("state = %s, want running", runtime.states["dev-lab"])
+	}
+	if !runtime.ran("dev-lab", installCommand) || !runtime.ran("dev-lab", "sh -c go version") {
+		t.Errorf("execs = %v", runtime.execs)
+	}
+
+	record := runtime.files["dev-lab"][appliedFile]
+	for _, key := range []string{keyPackages, keySetup} {
+		if !strings.Contains(record, key+"=") {
+			t.Errorf("record %q has no %s", record, key)
+		}
+	}
+	if len(runtime.snapshots["dev-lab"]) != 0 {
+		t.Errorf("new container was snapshotted: %v", runtime.snapshots["dev-lab"])
+	}
+}
+
+func TestReconcileUnchanged(t *testing.T) {
+	runtime := newFakeRuntime()
+	spec := testSpec()
+	reconcile(t, runtime, spec)
+	runtime.execs = nil
+
+	result := reconcile(t, runtime, spec)
+	if result.Action != ActionUnchanged || len(result.Changes) != 0 {
+		t.Errorf("result = %+v, want unchanged", result)
+	}
+	if want := []string{"dev-lab: cat " + appliedFile}; !reflect.DeepEqual(runtime.execs, want) {
+		t.Errorf("execs = %v, want %v", runtime.execs, want)
+	}
+	if len(runtime.created) != 1 || len(runtime.snapshots["dev-lab"]) != 0 {
+		t.Errorf("created %d containers and %d snapshots", len(runtime.created), len(runtime.snapshots["dev-lab"]))
+	}
+}
+
+func TestReconcileUpdatesChangedSteps(t *testing.T) {
+	runtime := newFakeRuntime()
+	spec := testSpec()
+	reconcile(t, runtime, spec)
+	runtime.execs = nil
+
+	spec.Setup = append(spec.Setup, "go env GOPATH")
+	result := reconcile(t, runtime, spec)
+	if result.Action != ActionUpdated {
+		t.Errorf("Action = %s, want %s", result.Action, ActionUpdated)
+	}
+	if want := []string{"setup"}; !reflect.DeepEqual(result.Changes, want) {
+		t.Errorf("Changes = %v, want %v", result.Changes, want)
+	}
+	if runtime.ran("dev-lab", installCommand) {
+		t.Error("unchanged packages were installed again")
+	}
+	if !runtime.ran("dev-lab", "sh -c go version") || !runtime.ran("dev-lab", "sh -c go env GOPATH") {
+		t.Errorf("setup did not rerun: %v", runtime.execs)
+	}
+	if snaps := runtime.snapshots["dev-lab"]; len(snaps) != 1 || !strings.HasPrefix(snaps[0], SnapshotPrefix+string(SnapshotBeforeChange)) {
+		t.Errorf("snapshots = %v, want one before-change snapshot", snaps)
+	}
+	if len(runtime.created) != 1 {
+		t.Errorf("created %d containers, want 1", len(runtime.created))
+	}
+
+	// The new setup is recorded, so a third run does nothing
+	if result := reconcile(t, runtime, spec); result.Action != ActionUnchanged {
+		t.Errorf("third run Action = %s, want %s", result.Action, ActionUnchanged)
+	}
+}
+
+func TestReconcileSkipsStoppedContainer(t *testing.T) {
+	runtime := newFakeRuntime()
+	spec := testSpec()
+	reconcile(t, runtime, spec)
+	runtime.Stop(context.Background(), spec.Name)
+	runtime.execs = nil
+
+	spec.Packages = append(spec.Packages, "rust")
+	if result := reconcile(t, runtime, spec); result.Action != ActionUnchanged {
+		t.Errorf("Action = %s, want %s", result.Action, ActionUnchanged)
+	}
+	if len(runtime.execs) != 0 || runtime.states[spec.Name] != system.ContainerStopped {
+		t.Errorf("stopped container was touched: %v", runtime.execs)
+	}
+}
@@ -0,0 +1,447 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/core"
+	"github.com/daveweinstein1/strixforge/pkg/lxd"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+)
+
+// NewRuntime returns the container runtime named in the config
+func NewRuntime(name string) (system.ContainerRuntime, error) {
+	switch name {
+	case config.RuntimeLXD, "":
+		return NewLXDRuntime(""), nil
+	case config.RuntimePodman:
+		return system.NewPodman(), nil
+	case config.RuntimeDocker:
+		return system.NewDocker(), nil
+	}
+	return nil, fmt.Errorf("unknown container runtime %q", name)
+}
+
+// LXDRuntime implements system.ContainerRuntime with the LXD REST client.
+// It connects on first use, so it can be created before LXD is installed.
+type LXDRuntime struct {
+	socket string
+	client *lxd.Client
+}
+
+// NewLXDRuntime creates a runtime for the daemon at socket, or the
+// discovered socket when empty
+func NewLXDRuntime(socket string) *LXDRuntime {
+	return &LXDRuntime{socket: socket}
+}
+
+// clientRuntime wraps an already connected client, for LXD-only code
+// that needs runtime helpers such as the snapshotter
+func clientRuntime(client *lxd.Client) *LXDRuntime {
+	return &LXDRuntime{client: client}
+}
+
+// Client returns the underlying LXD client, for operations outside the
+// runtime interface such as profiles and backups
+func (l *LXDRuntime) Client() (*lxd.Client, error) {
+	if l.client != nil {
+		return l.client, nil
+	}
+	client, err := lxd.Connect(l.socket)
+	if err != nil {
+		return nil, err
+	}
+	l.client = client
+	return client, nil
+}
+
+// Name returns "lxd"
+func (l *LXDRuntime) Name() string {
+	return config.RuntimeLXD
+}
+
+// Ping checks that the daemon answers
+func (l *LXDRuntime) Ping(ctx context.Context) error {
+	client, err := l.Client()
+	if err != nil {
+		return err
+	}
+	_, err = client.Server(ctx)
+	return err
+}
+
+// List returns every instance; LXD on a workstation is strixforge's
+func (l *LXDRuntime) List(ctx context.Context) ([]system.ContainerInfo, error) {
+	client, err := l.Client()
+	if err != nil {
+		return nil, err
+	}
+	instances, err := client.Instances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	containers := make([]system.ContainerInfo, 0, len(instances))
+	for _, instance := range instances {
+		info := system.ContainerInfo{Name: instance.Name, State: system.ContainerStopped, Image: instance.Config["image.description"]}
+		if instance.Running() {
+			info.State = system.ContainerRunning
+		}
+		containers = append(containers, info)
+	}
+	return containers, nil
+}
+
+// State reports whether an instance exists and is running
+func (l *LXDRuntime) State(ctx context.Context, name string) (system.ContainerState, error) {
+	client, err := l.Client()
+	if err != nil {
+		return "", err
+	}
+	instance, err := client.Instance(ctx, name)
+	switch {
+	case lxd.IsNotFound(err):
+		return system.ContainerMissing, nil
+	case err != nil:
+		return "", err
+	case instance.Running():
+		return system.ContainerRunning, nil
+	}
+	return system.ContainerStopped, nil
+}
+
+// Create launches an instance from an LXD image reference. Ports become
+// proxy devices and mounts disk devices; named volumes come from the
+// default pool. The LXD-only fields are applied last, so the reconciler
+// can pass the exact config and devices it manages.
+func (l *LXDRuntime) Create(ctx context.Context, cfg system.ContainerConfig) error {
+	client, err := l.Client()
+	if err != nil {
+		return err
+	}
+	source, err := lxd.ParseImage(cfg.Image)
+	if err != nil {
+		return err
+	}
+
+	req := lxd.InstancesPost{
+		Name:        cfg.Name,
+		Description: cfg.Description,
+		Source:      source,
+		Config:      make(map[string]string),
+		Devices:     make(lxd.Devices),
+		Profiles:    []string{"default"},
+	}
+	if cfg.GPU {
+		req.Profiles = append(req.Profiles, ProfileGPU)
+	}
+	for key, value := range cfg.Environment {
+		req.Config["environment."+key] = value
+	}
+	limits := config.Limits{CPU: cfg.CPU, Processes: cfg.Processes}
+	if cfg.Memory > 0 {
+		limits.Memory = fmt.Sprintf("%dB", cfg.Memory)
+	}
+	for key, value := range limits.LXDConfig() {
+		req.Config[key] = value
+	}
+	for _, p := range cfg.Ports {
+		port := config.PortSpec{Host: p.Host, Container: p.Container, Protocol: p.Protocol}
+		req.Devices[ProxyDeviceName(port)] = map[string]string{
+			"type":    "proxy",
+			"listen":  fmt.Sprintf("%s:127.0.0.1:%d", p.Protocol, p.Host),
+			"connect": fmt.Sprintf("%s:127.0.0.1:%d", p.Protocol, p.Container),
+		}
+	}
+	for _, m := range cfg.Mounts {
+		device := map[string]string{"type": "disk", "source": m.Source, "path": m.Path}
+		name := mountDeviceName(m.Path)
+		if m.Volume {
+			device["pool"] = config.DefaultVolumePool
+			name = "vol-" + m.Source
+		}
+		if m.ReadOnly {
+			device["readonly"] = "true"
+		}
+		req.Devices[name] = device
+	}
+
+	for key, value := range cfg.Config {
+		req.Config[key] = value
+	}
+	for name, device := range cfg.Devices {
+		req.Devices[name] = device
+	}
+	for _, p := range cfg.Profiles {
+		if !slices.Contains(req.Profiles, p) {
+			req.Profiles = append(req.Profiles, p)
+		}
+	}
+
+	var progress lxd.ProgressFunc
+	if cfg.Progress != nil {
+		progress = func(p lxd.Progress) { cfg.Progress(p.Text) }
+	}
+	if err := client.CreateInstance(ctx, req, progress); err != nil {
+		return fmt.Errorf("failed to create %s: %v", cfg.Name, err)
+	}
+	return nil
+}
+
+// Start starts an instance
+func (l *LXDRuntime) Start(ctx context.Context, name string) error {
+	client, err := l.Client()
+	if err != nil {
+		return err
+	}
+	return client.StartInstance(ctx, name)
+}
+
+// Stop stops an instance cleanly
+func (l *LXDRuntime) Stop(ctx context.Context, name string) error {
+	client, err := l.Client()
+	if err != nil {
+		return err
+	}
+	return client.StopInstance(ctx, name, false)
+}
+
+// Delete stops and deletes an instance. Missing instances are not an error.
+func (l *LXDRuntime) Delete(ctx context.Context, name string) error {
+	client, err := l.Client()
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteInstance(ctx, name, true); err != nil && !lxd.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// Exec runs a command in an instance
+func (l *LXDRuntime) Exec(ctx context.Context, name string, command ...string) (*system.ExecResult, error) {
+	client, err := l.Client()
+	if err != nil {
+		return nil, err
+	}
+	result, err := client.Exec(ctx, name, command...)
+	if result == nil {
+		return nil, err
+	}
+	return &system.ExecResult{
+		Command:  strings.Join(command, " "),
+		ExitCode: result.ExitCode,
+		Stdout:   result.Stdout,
+		Stderr:   result.Stderr,
+	}, err
+}
+
+// Snapshot takes an LXD snapshot
+func (l *LXDRuntime) Snapshot(ctx context.Context, name, snapshot string) error {
+	client, err := l.Client()
+	if err != nil {
+		return err
+	}
+	return client.CreateSnapshot(ctx, name, lxd.SnapshotsPost{Name: snapshot})
+}
+
+// Snapshots returns the names of an instance's snapshots, oldest first
+func (l *LXDRuntime) Snapshots(ctx context.Context, name string) ([]string, error) {
+	client, err := l.Client()
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := client.Snapshots(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(snapshots))
+	for i, snap := range snapshots {
+		names[i] = snap.Name
+	}
+	return names, nil
+}
+
+// DeleteSnapshot removes an LXD snapshot
+func (l *LXDRuntime) DeleteSnapshot(ctx context.Context, name, snapshot string) error {
+	client, err := l.Client()
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteSnapshot(ctx, name, snapshot); err != nil && !lxd.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// reconcileRuntime creates a missing container through a runtime other
+// than LXD and provisions it with exec. Existing containers get changed
+// users, packages, files and setup commands, but podman and docker fix
+// devices, ports and mounts at creation, so changes to those mean
+// recreating the container.
+func (r *Reconciler) reconcileRuntime(ctx context.Context, ui core.UI, spec config.ContainerSpec) (*Result, error) {
+	state, err := r.runtime.State(ctx, spec.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %v", spec.Name, err)
+	}
+	if state != system.ContainerMissing {
+		return r.updateRuntime(ctx, ui, spec, state)
+	}
+
+	cfg, err := containerConfig(spec, r.runtime.Name())
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPorts(spec, nil); err != nil {
+		return nil, err
+	}
+	ui.Log(core.LogInfo, fmt.Sprintf("Creating %s from %s with %s...", spec.Name, cfg.Image, r.runtime.Name()))
+	if err := r.runtime.Create(ctx, cfg); err != nil {
+		return nil, err
+	}
+	result := &Result{Name: spec.Name, Action: ActionCreated}
+	if err := r.runtime.Start(ctx, spec.Name); err != nil {
+		return result, fmt.Errorf("failed to start %s: %v", spec.Name, err)
+	}
+	if steps := r.provisionSteps(ctx, ui, spec); len(steps) > 0 {
+		if err := r.applySteps(ctx, ui, spec, steps, result); err != nil {
+			return result, err
+		}
+	}
+	return result, r.runSpecProbes(ctx, ui, spec)
+}
+
+// updateRuntime reruns the provisioning steps that changed since they were
+// recorded in the container, snapshotting it first. The record can only
+// be read from a running container, so stopped ones are left alone.
+func (r *Reconciler) updateRuntime(ctx context.Context, ui core.UI, spec config.ContainerSpec, state system.ContainerState) (*Result, error) {
+	result := &Result{Name: spec.Name, Action: ActionUnchanged}
+	ui.Log(core.LogDebug, fmt.Sprintf("%s containers keep their devices, ports and mounts; recreate %s to apply changes to those", r.runtime.Name(), spec.Name))
+	if state != system.ContainerRunning {
+		ui.Log(core.LogInfo, fmt.Sprintf("%s is stopped; start it and rerun to apply provisioning changes", spec.Name))
+		return result, nil
+	}
+
+	applied, err := readApplied(ctx, r.runtime, spec.Name)
+	if err != nil {
+		return result, err
+	}
+	pending := r.pendingSteps(ctx, ui, spec, applied)
+	if len(pending) > 0 {
+		name, err := NewSnapshotter(r.runtime).BeforeChange(ctx, spec.Name, spec.Snapshots, "before updating packages and setup")
+		if err != nil {
+			return result, err
+		}
+		if name != "" {
+			ui.Log(core.LogInfo, fmt.Sprintf("✓ Snapshot %s/%s taken", spec.Name, name))
+		}
+		if err := r.applySteps(ctx, ui, spec, pending, result); err != nil {
+			return result, err
+		}
+		result.Action = ActionUpdated
+	}
+	return result, r.runSpecProbes(ctx, ui, spec)
+}
+
+// appliedFile holds the provisioning hashes of a podman or docker
+// container, which has no instance config to keep them in, one key=hash
+// per line
+const appliedFile = "/var/lib/strixforge/provisioned"
+
+// writeFileScript writes its second argument to the file named by its first
+const writeFileScript = `mkdir -p "$(dirname "$1")" && printf '%s' "$2" > "$1"`
+
+// readApplied reads the provisioning hashes recorded in a running
+// container. A container without the file has none.
+func readApplied(ctx context.Context, runtime system.ContainerRuntime, name string) (map[string]string, error) {
+	result, err := runtime.Exec(ctx, name, "cat", appliedFile)
+	if err != nil {
+		if result != nil && strings.Contains(result.Stderr, "No such file") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s in %s: %v", appliedFile, name, err)
+	}
+	applied := make(map[string]string)
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		if key, hash, ok := strings.Cut(line, "="); ok {
+			applied[key] = hash
+		}
+	}
+	return applied, nil
+}
+
+// writeApplied adds provisioning hashes to the container's record
+func writeApplied(ctx context.Context, runtime system.ContainerRuntime, name string, record map[string]string) error {
+	applied, err := readApplied(ctx, runtime, name)
+	if err != nil {
+		return err
+	}
+	if applied == nil {
+		applied = make(map[string]string)
+	}
+	for key, hash := range record {
+		applied[key] = hash
+	}
+	keys := make([]string, 0, len(applied))
+	for key := range applied {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s=%s\n", key, applied[key])
+	}
+	if _, err := runtime.Exec(ctx, name, "sh", "-c", writeFileScript, "sh", appliedFile, b.String()); err != nil {
+		return fmt.Errorf("failed to record provisioning in %s: %v", name, err)
+	}
+	return nil
+}
+
+// containerConfig translates a spec for a runtime other than LXD.
+// Percentage memory limits are resolved against host RAM.
+func containerConfig(spec config.ContainerSpec, runtime string) (system.ContainerConfig, error) {
+	cfg := system.ContainerConfig{
+		Name:        spec.Name,
+		Description: spec.Description,
+		Image:       spec.ImageFor(runtime),
+		Environment: spec.Environment,
+		GPU:         spec.GPU,
+		CPU:         spec.Limits.CPU,
+		Processes:   spec.Limits.Processes,
+	}
+	for _, port := range spec.Forwards() {
+		cfg.Ports = append(cfg.Ports, system.PortMapping{Host: port.Host, Container: port.Container, Protocol: port.Protocol})
+	}
+	for _, m := range spec.Mounts {
+		cfg.Mounts = append(cfg.Mounts, system.Mount{Source: m.Source, Path: m.Path, ReadOnly: m.ReadOnly})
+	}
+	if spec.Home != config.HomeNone {
+		u, err := hostUser()
+		if err != nil {
+			return cfg, fmt.Errorf("could not determine current user: %v", err)
+		}
+		cfg.Mounts = append(cfg.Mounts, system.Mount{Source: u.HomeDir, Path: config.HomeMountPath, ReadOnly: spec.Home == config.HomeReadOnly})
+	}
+	for _, v := range spec.Volumes {
+		cfg.Mounts = append(cfg.Mounts, system.Mount{Source: v.Name, Path: v.Path, ReadOnly: v.ReadOnly, Volume: true})
+	}
+
+	if spec.Limits.Memory != "" {
+		bytes, percent, err := config.ParseMemoryLimit(spec.Limits.Memory)
+		if err != nil {
+			return cfg, err
+		}
+		if percent > 0 {
+			host, err := system.ReadMemoryInfo()
+			if err != nil {
+				return cfg, fmt.Errorf("cannot resolve memory limit %s: %v", spec.Limits.Memory, err)
+			}
+			bytes = uint64(float64(host.Total) * percent / 100)
+		}
+		cfg.Memory = bytes
+	}
+	return cfg, nil
+}
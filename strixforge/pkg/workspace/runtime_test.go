@@ -0,0 +1,126 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+)
+
+// fakeRuntime is an in-memory stand-in for podman. Every command run in a
+// running container succeeds, except cat of a missing file; files written
+// with writeFileScript can be read back with cat.
+type fakeRuntime struct {
+	states    map[string]system.ContainerState
+	files     map[string]map[string]string // by container, then path
+	snapshots map[string][]string
+	created   []system.ContainerConfig
+	execs     []string // "container: command line", in order
+}
+
+var _ system.ContainerRuntime = (*fakeRuntime)(nil)
+
+func newFakeRuntime() *fakeRuntime {
+	return &fakeRuntime{
+		states:    make(map[string]system.ContainerState),
+		files:     make(map[string]map[string]string),
+		snapshots: make(map[string][]string),
+	}
+}
+
+// ran reports whether a command line was run in a container since the
+// execs were last cleared
+func (f *fakeRuntime) ran(name, command string) bool {
+	return slices.Contains(f.execs, name+": "+command)
+}
+
+func (f *fakeRuntime) Name() string                   { return config.RuntimePodman }
+func (f *fakeRuntime) Ping(ctx context.Context) error { return nil }
+
+func (f *fakeRuntime) List(ctx context.Context) ([]system.ContainerInfo, error) {
+	var containers []system.ContainerInfo
+	for name, state := range f.states {
+		containers = append(containers, system.ContainerInfo{Name: name, State: state})
+	}
+	return containers, nil
+}
+
+func (f *fakeRuntime) State(ctx context.Context, name string) (system.ContainerState, error) {
+	if state, ok := f.states[name]; ok {
+		return state, nil
+	}
+	return system.ContainerMissing, nil
+}
+
+func (f *fakeRuntime) Create(ctx context.Context, cfg system.ContainerConfig) error {
+	if _, ok := f.states[cfg.Name]; ok {
+		return fmt.Errorf("container %s already exists", cfg.Name)
+	}
+	f.states[cfg.Name] = system.ContainerStopped
+	f.files[cfg.Name] = make(map[string]string)
+	f.created = append(f.created, cfg)
+	return nil
+}
+
+func (f *fakeRuntime) setState(name string, state system.ContainerState) error {
+	if _, ok := f.states[name]; !ok {
+		return fmt.Errorf("no container %s", name)
+	}
+	f.states[name] = state
+	return nil
+}
+
+func (f *fakeRuntime) Start(ctx context.Context, name string) error {
+	return f.setState(name, system.ContainerRunning)
+}
+
+func (f *fakeRuntime) Stop(ctx context.Context, name string) error {
+	return f.setState(name, system.ContainerStopped)
+}
+
+func (f *fakeRuntime) Delete(ctx context.Context, name string) error {
+	delete(f.states, name)
+	delete(f.files, name)
+	return nil
+}
+
+func (f *fakeRuntime) Exec(ctx context.Context, name string, command ...string) (*system.ExecResult, error) {
+	if f.states[name] != system.ContainerRunning {
+		return nil, fmt.Errorf("container %s is not running", name)
+	}
+	line := strings.Join(command, " ")
+	f.execs = append(f.execs, name+": "+line)
+	result := &system.ExecResult{Command: line}
+
+	switch {
+	case len(command) == 2 && command[0] == "cat":
+		content, ok := f.files[name][command[1]]
+		if !ok {
+			result.ExitCode = 1
+			result.Stderr = "cat: " + command[1] + ": No such file or directory\n"
+			return result, errors.New("exit status 1")
+		}
+		result.Stdout = content
+	case len(command) == 6 && command[0] == "sh" && command[2] == writeFileScript:
+		f.files[name][command[4]] = command[5]
+	}
+	return result, nil
+}
+
+func (f *fakeRuntime) Snapshot(ctx context.Context, name, snapshot string) error {
+	f.snapshots[name] = append(f.snapshots[name], snapshot)
+	return nil
+}
+
+func (f *fakeRuntime) Snapshots(ctx context.Context, name string) ([]string, error) {
+	return f.snapshots[name], nil
+}
+
+func (f *fakeRuntime) DeleteSnapshot(ctx context.Context, name, snapshot string) error {
+	f.snapshots[name] = slices.DeleteFunc(f.snapshots[name], func(s string) bool { return s == snapshot })
+	return nil
+}
@@ -0,0 +1,154 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/lxd"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+)
+
+// ServiceStatus is a declared service and whether it can be reached
+type ServiceStatus struct {
+	Container   string `json:"container"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url"`
+	Exists      bool   `json:"exists"`    // the container has been created
+	Running     bool   `json:"running"`   // the container is running
+	Forwarded   bool   `json:"forwarded"` // the proxy device exists
+	Listening   bool   `json:"listening"` // something listens on the container port
+}
+
+// ServiceStatuses reports every service declared in the specs. Podman and
+// docker publish ports when the container is created, so there they count
+// as forwarded whenever the container exists.
+func ServiceStatuses(ctx context.Context, runtime system.ContainerRuntime, specs config.ContainerSpecs) ([]ServiceStatus, error) {
+	var statuses []ServiceStatus
+	for _, spec := range specs {
+		if len(spec.Services) == 0 {
+			continue
+		}
+		state, err := runtime.State(ctx, spec.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up %s: %v", spec.Name, err)
+		}
+		forwarded, err := forwardedDevices(ctx, runtime, spec.Name, state)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up %s: %v", spec.Name, err)
+		}
+		listening := make(map[string]map[int]bool) // by protocol
+		for _, svc := range spec.Services {
+			status := ServiceStatus{
+				Container:   spec.Name,
+				Name:        svc.Name,
+				Description: svc.Description,
+				URL:         svc.URL(),
+				Exists:      state != system.ContainerMissing,
+				Running:     state == system.ContainerRunning,
+			}
+			status.Forwarded = status.Exists && (forwarded == nil || forwarded[ProxyDeviceName(svc.Port)] != nil)
+			if status.Running {
+				protocol := svc.Port.Protocol
+				if listening[protocol] == nil {
+					listening[protocol] = listeningPorts(ctx, runtime, spec.Name, protocol)
+				}
+				status.Listening = listening[protocol][svc.Port.Container]
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
+
+// forwardedDevices returns an LXD instance's devices, or nil under other
+// runtimes and for missing containers
+func forwardedDevices(ctx context.Context, runtime system.ContainerRuntime, name string, state system.ContainerState) (lxd.Devices, error) {
+	lxdRuntime, ok := runtime.(*LXDRuntime)
+	if !ok || state == system.ContainerMissing {
+		return nil, nil
+	}
+	client, err := lxdRuntime.Client()
+	if err != nil {
+		return nil, err
+	}
+	instance, err := client.Instance(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if instance.Devices == nil {
+		return lxd.Devices{}, nil
+	}
+	return instance.Devices, nil
+}
+
+// listeningPorts returns the TCP or UDP ports with a listening socket
+// inside a container, read from /proc/net/{tcp,udp}{,6}. Dialling the host
+// port says nothing, since the proxy device accepts connections either way.
+func listeningPorts(ctx context.Context, runtime system.ContainerRuntime, name, protocol string) map[int]bool {
+	ports := make(map[int]bool)
+	result, err := runtime.Exec(ctx, name, "cat", "/proc/net/"+protocol, "/proc/net/"+protocol+"6")
+	if err != nil {
+		return ports
+	}
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		// sl local_address rem_address st ...; TCP listens in state 0A
+		// (LISTEN), UDP in state 07 (CLOSE) with no remote address
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		switch {
+		case protocol == "tcp" && fields[3] == "0A":
+		case protocol == "udp" && fields[3] == "07" && strings.Trim(fields[2], "0:") == "":
+		default:
+			continue
+		}
+		_, hexPort, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+		if port, err := strconv.ParseUint(hexPort, 16, 16); err == nil {
+			ports[int(port)] = true
+		}
+	}
+	return ports
+}
+
+// PortInUse reports whether a host port on localhost is already bound
+func PortInUse(port config.PortSpec) bool {
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port.Host))
+	if port.Protocol == "udp" {
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return true
+		}
+		conn.Close()
+		return false
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return true
+	}
+	l.Close()
+	return false
+}
+
+// checkPorts fails if a proxy the spec needs would collide with a host port
+// that something else holds. Ports the container already forwards are
+// skipped, since its own proxy device is what holds them.
+func checkPorts(spec config.ContainerSpec, current lxd.Devices) error {
+	for _, port := range spec.Forwards() {
+		if existing, ok := current[ProxyDeviceName(port)]; ok && existing["type"] == "proxy" {
+			continue
+		}
+		if PortInUse(port) {
+			return fmt.Errorf("host port %d/%s for %s is already in use; free it or change the port in the config", port.Host, port.Protocol, spec.Name)
+		}
+	}
+	return nil
+}
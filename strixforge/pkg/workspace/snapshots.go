@@ -0,0 +1,348 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/lxd"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+)
+
+// SnapshotPrefix marks snapshots taken by strixforge. Retention only ever
+// deletes snapshots with this prefix, so manual snapshots are safe.
+const SnapshotPrefix = "sf-"
+
+// keySnapshotReason is set on the instance just before a snapshot is taken
+// so the snapshot's config records why it exists, and unset again after.
+// LXD cannot edit a snapshot's config once it is taken.
+const keySnapshotReason = "user.strixforge.snapshot.reason"
+
+// snapshotTimeFormat is the timestamp in snapshot names, always UTC
+const snapshotTimeFormat = "20060102-150405"
+
+// SnapshotKind is the schedule or event a snapshot was taken for
+type SnapshotKind string
+
+const (
+	SnapshotHourly       SnapshotKind = "hourly"
+	SnapshotDaily        SnapshotKind = "daily"
+	SnapshotWeekly       SnapshotKind = "weekly"
+	SnapshotBeforeChange SnapshotKind = "before-change"
+
+	// SnapshotManual is taken by `strixforge containers snapshot` and never
+	// pruned
+	SnapshotManual SnapshotKind = "manual"
+)
+
+// scheduledKinds are taken by the timer, shortest interval first
+var scheduledKinds = []SnapshotKind{SnapshotHourly, SnapshotDaily, SnapshotWeekly}
+
+var snapshotIntervals = map[SnapshotKind]time.Duration{
+	SnapshotHourly: time.Hour,
+	SnapshotDaily:  24 * time.Hour,
+	SnapshotWeekly: 7 * 24 * time.Hour,
+}
+
+// snapshotSlack lets a timer that fires a little early still count as due
+const snapshotSlack = 5 * time.Minute
+
+// AutoSnapshot is a snapshot taken by strixforge
+type AutoSnapshot struct {
+	Name      string
+	Kind      SnapshotKind
+	CreatedAt time.Time
+	Reason    string
+}
+
+// SnapshotName returns the name for a snapshot of a kind taken at t
+func SnapshotName(kind SnapshotKind, t time.Time) string {
+	return SnapshotPrefix + string(kind) + "-" + t.UTC().Format(snapshotTimeFormat)
+}
+
+// ParseSnapshot recognises a strixforge snapshot by its name
+func ParseSnapshot(name string) (AutoSnapshot, bool) {
+	rest, ok := strings.CutPrefix(name, SnapshotPrefix)
+	if !ok || len(rest) < len(snapshotTimeFormat)+2 {
+		return AutoSnapshot{}, false
+	}
+	kind := rest[:len(rest)-len(snapshotTimeFormat)-1]
+	stamp := rest[len(kind)+1:]
+	created, err := time.Parse(snapshotTimeFormat, stamp)
+	if err != nil || rest[len(kind)] != '-' {
+		return AutoSnapshot{}, false
+	}
+	return AutoSnapshot{
+		Name:      name,
+		Kind:      SnapshotKind(kind),
+		CreatedAt: created,
+	}, true
+}
+
+// keep returns how many snapshots of a kind a policy retains
+func keep(policy config.SnapshotPolicy, kind SnapshotKind) int {
+	switch kind {
+	case SnapshotHourly:
+		return policy.Hourly
+	case SnapshotDaily:
+		return policy.Daily
+	case SnapshotWeekly:
+		return policy.Weekly
+	case SnapshotBeforeChange:
+		return policy.BeforeChange
+	}
+	return 0
+}
+
+// DueSnapshots returns the scheduled kinds whose newest snapshot is older
+// than their interval
+func DueSnapshots(existing []AutoSnapshot, policy config.SnapshotPolicy, now time.Time) []SnapshotKind {
+	latest := make(map[SnapshotKind]time.Time)
+	for _, s := range existing {
+		if s.CreatedAt.After(latest[s.Kind]) {
+			latest[s.Kind] = s.CreatedAt
+		}
+	}
+
+	var due []SnapshotKind
+	for _, kind := range scheduledKinds {
+		if keep(policy, kind) == 0 {
+			continue
+		}
+		if now.Sub(latest[kind]) >= snapshotIntervals[kind]-snapshotSlack {
+			due = append(due, kind)
+		}
+	}
+	return due
+}
+
+// PlanPrune returns the snapshots a policy no longer keeps: those beyond
+// the count for their kind, and any older than the policy's max age.
+// Snapshots of unknown kinds are left alone.
+func PlanPrune(existing []AutoSnapshot, policy config.SnapshotPolicy, now time.Time) []AutoSnapshot {
+	sorted := append([]AutoSnapshot(nil), existing...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	maxAge := time.Duration(policy.MaxAge)
+	kept := make(map[SnapshotKind]int)
+	var prune []AutoSnapshot
+	for _, s := range sorted {
+		if _, known := snapshotIntervals[s.Kind]; !known && s.Kind != SnapshotBeforeChange {
+			continue
+		}
+		if kept[s.Kind] >= keep(policy, s.Kind) || (maxAge > 0 && now.Sub(s.CreatedAt) > maxAge) {
+			prune = append(prune, s)
+			continue
+		}
+		kept[s.Kind]++
+	}
+	return prune
+}
+
+// Snapshotter takes and prunes container snapshots according to policies.
+// On podman and docker snapshots are committed images; only LXD records
+// why a snapshot was taken.
+type Snapshotter struct {
+	runtime system.ContainerRuntime
+	now     func() time.Time
+}
+
+// NewSnapshotter creates a snapshotter for a container runtime
+func NewSnapshotter(runtime system.ContainerRuntime) *Snapshotter {
+	return &Snapshotter{runtime: runtime, now: time.Now}
+}
+
+// List returns a container's strixforge snapshots, oldest first
+func (s *Snapshotter) List(ctx context.Context, instance string) ([]AutoSnapshot, error) {
+	names, err := s.runtime.Snapshots(ctx, instance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots of %s: %v", instance, err)
+	}
+	reasons, err := s.reasons(ctx, instance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots of %s: %v", instance, err)
+	}
+	var auto []AutoSnapshot
+	for _, name := range names {
+		if a, ok := ParseSnapshot(name); ok {
+			a.Reason = reasons[name]
+			auto = append(auto, a)
+		}
+	}
+	sort.SliceStable(auto, func(i, j int) bool { return auto[i].CreatedAt.Before(auto[j].CreatedAt) })
+	return auto, nil
+}
+
+// reasons returns the recorded reason of each LXD snapshot by name
+func (s *Snapshotter) reasons(ctx context.Context, instance string) (map[string]string, error) {
+	lxdRuntime, ok := s.runtime.(*LXDRuntime)
+	if !ok {
+		return nil, nil
+	}
+	client, err := lxdRuntime.Client()
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := client.Snapshots(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+	reasons := make(map[string]string, len(snapshots))
+	for _, snap := range snapshots {
+		reasons[snap.Name] = snap.Config[keySnapshotReason]
+	}
+	return reasons, nil
+}
+
+// Take snapshots a container, on LXD recording the reason in the snapshot
+// config
+func (s *Snapshotter) Take(ctx context.Context, instance string, kind SnapshotKind, reason string) (string, error) {
+	name := SnapshotName(kind, s.now())
+	lxdRuntime, ok := s.runtime.(*LXDRuntime)
+	if !ok {
+		if err := s.runtime.Snapshot(ctx, instance, name); err != nil {
+			return "", fmt.Errorf("failed to snapshot %s: %v", instance, err)
+		}
+		return name, nil
+	}
+
+	client, err := lxdRuntime.Client()
+	if err != nil {
+		return "", err
+	}
+	current, err := client.Instance(ctx, instance)
+	if err != nil {
+		return "", err
+	}
+	put := current.Writable()
+	put.Config = copyConfig(put.Config)
+	put.Config[keySnapshotReason] = reason
+	if err := client.UpdateInstance(ctx, instance, put); err != nil {
+		return "", fmt.Errorf("failed to record snapshot reason on %s: %v", instance, err)
+	}
+	if err := client.CreateSnapshot(ctx, instance, lxd.SnapshotsPost{Name: name}); err != nil {
+		clearSnapshotReason(ctx, client, instance)
+		return "", fmt.Errorf("failed to snapshot %s: %v", instance, err)
+	}
+	return name, clearSnapshotReason(ctx, client, instance)
+}
+
+// clearSnapshotReason removes the reason from the instance config, where
+// it would otherwise linger until the next snapshot or come back with a
+// restore
+func clearSnapshotReason(ctx context.Context, client *lxd.Client, instance string) error {
+	current, err := client.Instance(ctx, instance)
+	if err != nil {
+		return err
+	}
+	if _, ok := current.Config[keySnapshotReason]; !ok {
+		return nil
+	}
+	put := current.Writable()
+	put.Config = copyConfig(put.Config)
+	delete(put.Config, keySnapshotReason)
+	if err := client.UpdateInstance(ctx, instance, put); err != nil {
+		return fmt.Errorf("failed to clear snapshot reason on %s: %v", instance, err)
+	}
+	return nil
+}
+
+// BeforeChange snapshots a container ahead of an install or update and
+// prunes older before-change snapshots. It does nothing if the policy
+// keeps none.
+func (s *Snapshotter) BeforeChange(ctx context.Context, instance string, policy config.SnapshotPolicy, reason string) (string, error) {
+	if policy.BeforeChange == 0 {
+		return "", nil
+	}
+	name, err := s.Take(ctx, instance, SnapshotBeforeChange, reason)
+	if err != nil {
+		return "", err
+	}
+	_, err = s.Prune(ctx, instance, policy, false)
+	return name, err
+}
+
+// Run takes whichever scheduled snapshots are due, then prunes. With
+// dryRun nothing is changed and the names that would be taken are returned.
+func (s *Snapshotter) Run(ctx context.Context, instance string, policy config.SnapshotPolicy, dryRun bool) (taken, pruned []string, err error) {
+	existing, err := s.List(ctx, instance)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := s.now()
+	for _, kind := range DueSnapshots(existing, policy, now) {
+		if dryRun {
+			taken = append(taken, SnapshotName(kind, now))
+			continue
+		}
+		name, err := s.Take(ctx, instance, kind, "scheduled "+string(kind)+" snapshot")
+		if err != nil {
+			return taken, nil, err
+		}
+		taken = append(taken, name)
+	}
+
+	pruned, err = s.Prune(ctx, instance, policy, dryRun)
+	return taken, pruned, err
+}
+
+// Prune deletes the snapshots a policy no longer keeps
+func (s *Snapshotter) Prune(ctx context.Context, instance string, policy config.SnapshotPolicy, dryRun bool) ([]string, error) {
+	existing, err := s.List(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []string
+	for _, snap := range PlanPrune(existing, policy, s.now()) {
+		if !dryRun {
+			if err := s.runtime.DeleteSnapshot(ctx, instance, snap.Name); err != nil {
+				return pruned, fmt.Errorf("failed to delete snapshot %s/%s: %v", instance, snap.Name, err)
+			}
+		}
+		pruned = append(pruned, snap.Name)
+	}
+	return pruned, nil
+}
+
+// snapshotService and snapshotTimer run `strixforge snapshots run` every hour
+const snapshotService = `[Unit]
+Description=Take and prune strixforge container snapshots
+After=lxd.socket
+Requires=lxd.socket
+
+[Service]
+Type=oneshot
+ExecStart={{.Binary}} snapshots run{{if .Config}} --config {{.Config}}{{end}}
+`
+
+const snapshotTimer = `[Unit]
+Description=Hourly strixforge container snapshots
+
+[Timer]
+OnCalendar=hourly
+RandomizedDelaySec=5m
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`
+
+// SnapshotUnits returns the service and timer that enforce snapshot
+// policies. binary is the strixforge executable and configPath the config
+// file it should read, or empty for the defaults.
+func SnapshotUnits(binary, configPath string) []system.ManagedFile {
+	data := map[string]string{"Binary": binary, "Config": configPath}
+	timer := system.Unit("strixforge-snapshots.timer", snapshotTimer, data)
+	timer.Enable = true
+	return []system.ManagedFile{
+		system.Unit("strixforge-snapshots.service", snapshotService, data),
+		timer,
+	}
+}
@@ -0,0 +1,139 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/core"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+)
+
+// upgradeCommand refreshes the keyring first so packages signed by new
+// keys do not fail the upgrade
+const upgradeCommand = "pacman -Sy --needed --noconfirm archlinux-keyring && pacman -Su --noconfirm"
+
+// defaultValidateTimeout is how long a validate check is retried; unlike
+// readiness probes they should pass straight away
+const defaultValidateTimeout = 30 * time.Second
+
+// UpdateResult describes an in-place update of one container
+type UpdateResult struct {
+	Name           string `json:"name"`
+	Snapshot       string `json:"snapshot"` // taken before upgrading
+	Validated      bool   `json:"validated"`
+	RolledBack     bool   `json:"rolled-back"`
+	SafetySnapshot string `json:"safety-snapshot,omitempty"` // the failed state, kept by the rollback
+	Error          string `json:"error,omitempty"`
+}
+
+// ValidationProbes turns a spec's validate checks into probes
+func ValidationProbes(runtime system.ContainerRuntime, spec config.ContainerSpec) []Probe {
+	var probes []Probe
+	for i, p := range spec.Validation {
+		label := p.Name
+		if label == "" {
+			label = fmt.Sprintf("check %d", i+1)
+		}
+		timeout := time.Duration(p.Timeout)
+		if timeout == 0 {
+			timeout = defaultValidateTimeout
+		}
+		command := p.Command
+		probes = append(probes, Probe{
+			Name:     fmt.Sprintf("%s in %s", label, spec.Name),
+			Deadline: timeout,
+			Check: func(ctx context.Context) error {
+				result, err := runtime.Exec(ctx, spec.Name, "sh", "-c", command)
+				if err != nil && result != nil && result.Stderr != "" {
+					return fmt.Errorf("%v: %s", err, lastLine(result.Stderr))
+				}
+				return err
+			},
+		})
+	}
+	return probes
+}
+
+// Update upgrades every package in an existing container. A snapshot is
+// taken first; if the upgrade or the spec's validate checks fail, the
+// container is restored to it and the error says so. A stopped container
+// is started for the update and stopped again afterwards.
+func Update(ctx context.Context, ui core.UI, runtime system.ContainerRuntime, spec config.ContainerSpec) (*UpdateResult, error) {
+	result := &UpdateResult{Name: spec.Name}
+	state, err := runtime.State(ctx, spec.Name)
+	if err != nil {
+		return result, err
+	}
+	if state == system.ContainerMissing {
+		return result, fmt.Errorf("container %s does not exist", spec.Name)
+	}
+
+	// The snapshot is taken even if the policy keeps none, since a failed
+	// update is rolled back to it
+	snapshotter := NewSnapshotter(runtime)
+	if result.Snapshot, err = snapshotter.Take(ctx, spec.Name, SnapshotBeforeChange, "before updating packages"); err != nil {
+		return result, err
+	}
+	ui.Log(core.LogInfo, fmt.Sprintf("✓ Snapshot %s/%s taken", spec.Name, result.Snapshot))
+	if spec.Snapshots.BeforeChange > 0 {
+		if _, err := snapshotter.Prune(ctx, spec.Name, spec.Snapshots, false); err != nil {
+			ui.Log(core.LogWarn, fmt.Sprintf("Could not prune snapshots of %s: %v", spec.Name, err))
+		}
+	}
+
+	if state != system.ContainerRunning {
+		if err := runtime.Start(ctx, spec.Name); err != nil {
+			return result, fmt.Errorf("failed to start %s: %v", spec.Name, err)
+		}
+		defer func() {
+			if err := runtime.Stop(ctx, spec.Name); err != nil {
+				ui.Log(core.LogWarn, fmt.Sprintf("Could not stop %s again: %v", spec.Name, err))
+			}
+		}()
+	}
+	if err := WaitReady(ctx, ui, BootProbes(runtime, spec.Name)); err != nil {
+		return result, fmt.Errorf("%s is not ready for updating: %v", spec.Name, err)
+	}
+
+	ui.Log(core.LogInfo, fmt.Sprintf("Upgrading packages in %s...", spec.Name))
+	if res, err := runtime.Exec(ctx, spec.Name, "sh", "-c", upgradeCommand); err != nil {
+		if res != nil && res.Stderr != "" {
+			err = fmt.Errorf("%v: %s", err, lastLine(res.Stderr))
+		}
+		return result, rollBack(ctx, ui, runtime, spec, result, fmt.Errorf("upgrade failed: %v", err))
+	}
+
+	probes := ValidationProbes(runtime, spec)
+	if len(probes) == 0 {
+		ui.Log(core.LogWarn, fmt.Sprintf("%s has no validate checks; the update was not verified", spec.Name))
+		return result, nil
+	}
+	if err := WaitReady(ctx, ui, probes); err != nil {
+		return result, rollBack(ctx, ui, runtime, spec, result, fmt.Errorf("validation failed: %v", err))
+	}
+	result.Validated = true
+	return result, nil
+}
+
+// rollBack restores the pre-update snapshot after cause, recording the
+// outcome in result. The returned error includes cause either way.
+func rollBack(ctx context.Context, ui core.UI, runtime system.ContainerRuntime, spec config.ContainerSpec, result *UpdateResult, cause error) error {
+	ui.Log(core.LogWarn, fmt.Sprintf("%s: %v; restoring %s", spec.Name, cause, result.Snapshot))
+	safety, err := Restore(ctx, runtime, &spec, spec.Name, result.Snapshot)
+	result.SafetySnapshot = safety
+	if err != nil {
+		return fmt.Errorf("%v, and restoring %s failed: %v", cause, result.Snapshot, err)
+	}
+	result.RolledBack = true
+	return fmt.Errorf("%v; rolled back to %s (failed state kept as %s)", cause, result.Snapshot, safety)
+}
+
+// lastLine returns the last non-empty line of command output, which for
+// pacman and python is usually the one that explains the failure
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
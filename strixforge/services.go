@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/workspace"
+)
+
+// runServices lists the services declared in container specs with their
+// host URLs
+func runServices(args []string) int {
+	fs := flag.NewFlagSet("services", flag.ExitOnError)
+	cfgPath := fs.String("config", config.DefaultPath, "Platform configuration file")
+	socket := fs.String("lxd-socket", "", "Path to the LXD unix socket (default: auto-detect)")
+	asJSON := fs.Bool("json", false, "Print JSON instead of a table")
+	fs.Parse(args)
+
+	cfg, err := loadConfig(*cfgPath)
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("Invalid configuration: %v", err)))
+		return 1
+	}
+	runtime, ok := connectRuntime(cfg, *socket)
+	if !ok {
+		return 1
+	}
+
+	statuses, err := workspace.ServiceStatuses(context.Background(), runtime, cfg.Containers)
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("✗ %v", err)))
+		return 1
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if statuses == nil {
+			statuses = []workspace.ServiceStatus{}
+		}
+		enc.Encode(statuses)
+		return 0
+	}
+
+	if len(statuses) == 0 {
+		fmt.Println("No services declared. Add 'services:' to a container in the config.")
+		return 0
+	}
+	fmt.Printf("%-12s %-10s %-30s %s\n", "CONTAINER", "SERVICE", "URL", "STATUS")
+	for _, s := range statuses {
+		fmt.Printf("%-12s %-10s %-30s %s\n", s.Container, s.Name, s.URL, serviceState(s))
+	}
+	return 0
+}
+
+// serviceState summarises a service for the table
+func serviceState(s workspace.ServiceStatus) string {
+	switch {
+	case !s.Exists:
+		return warnStyle.Render("missing (run the workspace stage)")
+	case !s.Running:
+		return warnStyle.Render("container stopped")
+	case !s.Forwarded:
+		return warnStyle.Render("not forwarded (run the workspace stage)")
+	case !s.Listening:
+		return warnStyle.Render("not started")
+	default:
+		return successStyle.Render("up")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/daveweinstein1/strixforge/pkg/config"
+	"github.com/daveweinstein1/strixforge/pkg/system"
+	"github.com/daveweinstein1/strixforge/pkg/workspace"
+)
+
+// runSnapshots enforces the snapshot policies in the config. The snapshot
+// timer calls `snapshots run` every hour.
+func runSnapshots(args []string) int {
+	if len(args) == 0 || (args[0] != "run" && args[0] != "prune" && args[0] != "list") {
+		fmt.Fprintln(os.Stderr, "Usage: strixforge snapshots <run|prune|list> [flags] [container...]")
+		fmt.Fprintln(os.Stderr, "  run    take snapshots that are due, then prune")
+		fmt.Fprintln(os.Stderr, "  prune  delete snapshots the retention policy no longer keeps")
+		fmt.Fprintln(os.Stderr, "  list   show strixforge snapshots and why they were taken")
+		return 2
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("snapshots "+action, flag.ExitOnError)
+	cfgPath := fs.String("config", config.DefaultPath, "Platform configuration file")
+	socket := fs.String("lxd-socket", "", "Path to the LXD unix socket (default: auto-detect)")
+	dry := fs.Bool("dry-run", false, "Show what would be taken and deleted without changing anything")
+	fs.Parse(args[1:])
+
+	cfg, err := loadConfig(*cfgPath)
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("Invalid configuration: %v", err)))
+		return 1
+	}
+	specs := cfg.Containers
+	if fs.NArg() > 0 {
+		specs = nil
+		for _, name := range fs.Args() {
+			spec, ok := cfg.Containers.Find(name)
+			if !ok {
+				// Containers without a spec still get pre-change snapshots
+				// from hub installs; prune those with the default policy
+				spec = config.ContainerSpec{Name: name, Snapshots: config.DefaultSnapshotPolicy()}
+			}
+			specs = append(specs, spec)
+		}
+	}
+
+	ctx := context.Background()
+	runtime, ok := connectRuntime(cfg, *socket)
+	if !ok {
+		return 1
+	}
+	snapshotter := workspace.NewSnapshotter(runtime)
+
+	failed := false
+	for _, spec := range specs {
+		if state, err := runtime.State(ctx, spec.Name); err != nil || state == system.ContainerMissing {
+			if err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("✗ %s: %v", spec.Name, err)))
+				failed = true
+			}
+			continue
+		}
+
+		switch action {
+		case "list":
+			snaps, err := snapshotter.List(ctx, spec.Name)
+			if err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("✗ %v", err)))
+				failed = true
+				continue
+			}
+			fmt.Println(infoStyle.Render(spec.Name))
+			for _, s := range snaps {
+				fmt.Printf("  %-36s %s  %s\n", s.Name, s.CreatedAt.Local().Format("2006-01-02 15:04"), s.Reason)
+			}
+		case "run", "prune":
+			var taken, pruned []string
+			if action == "run" {
+				taken, pruned, err = snapshotter.Run(ctx, spec.Name, spec.Snapshots, *dry)
+			} else {
+				pruned, err = snapshotter.Prune(ctx, spec.Name, spec.Snapshots, *dry)
+			}
+			for _, name := range taken {
+				fmt.Println(successStyle.Render(fmt.Sprintf("✓ %s: took %s", spec.Name, name)))
+			}
+			for _, name := range pruned {
+				fmt.Printf("  %s: deleted %s\n", spec.Name, name)
+			}
+			if err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("✗ %v", err)))
+				failed = true
+			}
+		}
+	}
+
+	if *dry && action != "list" {
+		fmt.Println(warnStyle.Render("Dry run: nothing was changed"))
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}